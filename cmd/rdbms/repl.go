@@ -1,138 +1,307 @@
-package main
-
-import (
-	"bufio"
-	"fmt"
-	"os"
-	"strings"
-
-	"github.com/benkivuva/my-rdbms/internal/executor"
-	"github.com/benkivuva/my-rdbms/internal/index"
-	"github.com/benkivuva/my-rdbms/internal/sql"
-	"github.com/benkivuva/my-rdbms/internal/storage"
-)
-
-// Engine holds the core database components.
-type Engine struct {
-	bp    *storage.BufferPool
-	dm    *storage.DiskManager
-	heap  *storage.TableHeap
-	btree *index.BTreeIndex
-}
-
-// initEngine initializes the database engine with the given file.
-func initEngine(dbName string) (*Engine, error) {
-	dm, err := storage.NewDiskManager(dbName)
-	if err != nil {
-		return nil, err
-	}
-	bp := storage.NewBufferPool(100, dm)
-
-	heap, err := storage.NewTableHeap(bp, storage.InvalidPageID)
-	if err != nil {
-		return nil, err
-	}
-
-	btree, err := index.NewBTreeIndex(bp, storage.InvalidPageID)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Engine{bp: bp, dm: dm, heap: heap, btree: btree}, nil
-}
-
-// Execute parses and executes a SQL statement, returning the result as a string.
-func (e *Engine) Execute(input string) string {
-	var out strings.Builder
-
-	l := sql.NewLexer(input)
-	p, err := sql.NewParser(l)
-	if err != nil {
-		return fmt.Sprintf("Parser Error: %v\n", err)
-	}
-
-	stmt, err := p.Parse()
-	if err != nil {
-		return fmt.Sprintf("Parse Error: %v\n", err)
-	}
-
-	switch s := stmt.(type) {
-	case *sql.InsertStatement:
-		exec := executor.NewInsertExecutor(e.btree, e.heap, s.Values)
-		if _, err := exec.Next(); err != nil {
-			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
-		} else {
-			out.WriteString("INSERT OK\n")
-		}
-
-	case *sql.SelectStatement:
-		var exec executor.Executor = executor.NewSeqScanExecutor(e.heap)
-
-		// Handle JOIN
-		if s.Join != nil {
-			exec = executor.NewNestedLoopJoinExecutor(exec, e.heap, s.Join.OnLeftField, s.Join.OnRightField)
-		}
-
-		if s.Where != nil {
-			exec = executor.NewFilterExecutor(exec, s.Where)
-		}
-
-		out.WriteString("----------------\n")
-		count := 0
-		for {
-			tuple, err := exec.Next()
-			if err != nil {
-				out.WriteString(fmt.Sprintf("Error: %v\n", err))
-				break
-			}
-			if tuple == nil {
-				break
-			}
-			out.WriteString(fmt.Sprintf("%v\n", tuple.Values))
-			count++
-		}
-		out.WriteString(fmt.Sprintf("(%d rows)\n", count))
-
-	case *sql.DeleteStatement:
-		exec := executor.NewDeleteExecutor(e.heap, e.btree, s.Where)
-		tuple, err := exec.Next()
-		if err != nil {
-			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
-		} else if tuple != nil {
-			out.WriteString(fmt.Sprintf("DELETE %v rows\n", tuple.Values[0]))
-		}
-
-	case *sql.UpdateStatement:
-		out.WriteString("UPDATE: Not fully implemented yet (use DELETE + INSERT)\n")
-
-	case *sql.CreateTableStatement:
-		out.WriteString("CREATE TABLE: Tables are implicit in this simple engine.\n")
-
-	default:
-		out.WriteString("Statement not fully supported yet\n")
-	}
-
-	return out.String()
-}
-
-// runREPL starts an interactive SQL shell.
-func runREPL(engine *Engine) {
-	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Println("Simple RDBMS REPL")
-	fmt.Println("Type 'exit' to quit.")
-
-	for {
-		fmt.Print("db> ")
-		if !scanner.Scan() {
-			break
-		}
-		input := strings.TrimSpace(scanner.Text())
-		if input == "exit" {
-			break
-		}
-		if input == "" {
-			continue
-		}
-		fmt.Print(engine.Execute(input))
-	}
-}
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/benkivuva/my-rdbms/internal/catalog"
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// Engine holds the core database components.
+type Engine struct {
+	bp      *storage.BufferPool
+	dm      *storage.DiskManager
+	catalog *catalog.Catalog
+}
+
+// initEngine initializes the database engine with the given file,
+// reloading tables recorded in its catalog.
+func initEngine(dbName string) (*Engine, error) {
+	dm, err := storage.NewDiskManager(dbName)
+	if err != nil {
+		return nil, err
+	}
+	bp := storage.NewBufferPool(100, dm)
+	if os.Getenv("RDBMS_DEBUG_PINS") != "" {
+		bp.TrackPins(true)
+	}
+
+	cat, err := catalog.NewCatalog(bp, dm)
+	if err != nil {
+		dm.Close()
+		return nil, err
+	}
+
+	return &Engine{bp: bp, dm: dm, catalog: cat}, nil
+}
+
+// sync persists the catalog and writes all dirty pages to disk.
+func (e *Engine) sync() error {
+	if err := e.catalog.Save(); err != nil {
+		return err
+	}
+	return e.bp.FlushAll()
+}
+
+// Close flushes all state to disk and closes the database file. With
+// RDBMS_DEBUG_PINS set, it also reports pages left pinned on stderr.
+func (e *Engine) Close() error {
+	if err := e.bp.LeakedPins(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if err := e.sync(); err != nil {
+		e.dm.Close()
+		return err
+	}
+	return e.dm.Close()
+}
+
+// closeReadOnly closes the database file without writing anything back,
+// for modes such as verify that must leave the file as they found it.
+// Like Close, it reports leaked pins with RDBMS_DEBUG_PINS set.
+func (e *Engine) closeReadOnly() error {
+	if err := e.bp.LeakedPins(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return e.dm.Close()
+}
+
+// Execute parses and executes a SQL statement, returning the result as a string.
+func (e *Engine) Execute(input string) string {
+	var out strings.Builder
+
+	l := sql.NewLexer(input)
+	p, err := sql.NewParser(l)
+	if err != nil {
+		return fmt.Sprintf("Parser Error: %v\n", err)
+	}
+
+	stmt, err := p.Parse()
+	if err != nil {
+		return fmt.Sprintf("Parse Error: %v\n", err)
+	}
+
+	switch s := stmt.(type) {
+	case *sql.InsertStatement:
+		table, err := e.catalog.GetTable(s.TableName)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		exec := executor.NewInsertExecutor(tableIndexes(table), table.Heap, table.Columns, s.Values)
+		if _, err := exec.Next(); err != nil {
+			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
+		} else if err := e.sync(); err != nil {
+			out.WriteString(fmt.Sprintf("Storage Error: %v\n", err))
+		} else {
+			out.WriteString("INSERT OK\n")
+		}
+
+	case *sql.SelectStatement:
+		table, err := e.catalog.GetTable(s.TableName)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		schema := executor.TableSchema(table.Name, table.Columns)
+
+		// Handle JOIN
+		var joinTable *catalog.TableInfo
+		var leftKey, rightKey int
+		if s.Join != nil {
+			joinTable, err = e.catalog.GetTable(s.Join.JoinTable)
+			if err != nil {
+				return fmt.Sprintf("Execution Error: %v\n", err)
+			}
+			joinSchema := executor.TableSchema(joinTable.Name, joinTable.Columns)
+			leftKey, rightKey, err = executor.BindJoinKeys(schema, joinSchema, s.Join.OnLeftField, s.Join.OnRightField)
+			if err != nil {
+				return fmt.Sprintf("Execution Error: %v\n", err)
+			}
+			schema = schema.Concat(joinSchema)
+		}
+
+		cond, err := executor.BindExpr(s.Where, schema)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		// The joined schema starts with the table's columns, so the
+		// WHERE clause can pick an index on the outer table.
+		exec, err := executor.PlanScan(table.Heap, table.Columns, tableIndexes(table), cond)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		if joinTable != nil {
+			exec = executor.NewNestedLoopJoinExecutor(exec, joinTable.Heap, joinTable.Columns, leftKey, rightKey)
+		}
+		if cond != nil {
+			exec = executor.NewFilterExecutor(exec, cond)
+		}
+
+		exprs, columns, err := executor.BindSelectList(s.Fields, schema)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		exec = executor.NewProjectionExecutor(exec, exprs, columns)
+
+		out.WriteString(strings.Join(columns, " ") + "\n")
+		out.WriteString("----------------\n")
+		count := 0
+		for {
+			tuple, err := exec.Next()
+			if err != nil {
+				out.WriteString(fmt.Sprintf("Error: %v\n", err))
+				break
+			}
+			if tuple == nil {
+				break
+			}
+			out.WriteString(formatRow(tuple.Values) + "\n")
+			count++
+		}
+		out.WriteString(fmt.Sprintf("(%d rows)\n", count))
+
+	case *sql.DeleteStatement:
+		table, err := e.catalog.GetTable(s.TableName)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		cond, err := executor.BindExpr(s.Where, executor.TableSchema(table.Name, table.Columns))
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		exec := executor.NewDeleteExecutor(table.Heap, tableIndexes(table), table.Columns, cond)
+		tuple, err := exec.Next()
+		if err != nil {
+			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
+		} else if err := e.sync(); err != nil {
+			out.WriteString(fmt.Sprintf("Storage Error: %v\n", err))
+		} else if tuple != nil {
+			out.WriteString(fmt.Sprintf("DELETE %v rows\n", tuple.Values[0]))
+		}
+
+	case *sql.UpdateStatement:
+		table, err := e.catalog.GetTable(s.TableName)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		schema := executor.TableSchema(table.Name, table.Columns)
+		assignments, err := executor.BindAssignments(s.Sets, schema)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		cond, err := executor.BindExpr(s.Where, schema)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		exec := executor.NewUpdateExecutor(table.Heap, tableIndexes(table), table.Columns, assignments, cond)
+		tuple, err := exec.Next()
+		if err != nil {
+			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
+		} else if err := e.sync(); err != nil {
+			out.WriteString(fmt.Sprintf("Storage Error: %v\n", err))
+		} else if tuple != nil {
+			out.WriteString(fmt.Sprintf("UPDATE %v rows\n", tuple.Values[0]))
+		}
+
+	case *sql.CreateTableStatement:
+		if _, err := e.catalog.CreateTable(s.TableName, s.Columns); err != nil {
+			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
+		} else if err := e.sync(); err != nil {
+			out.WriteString(fmt.Sprintf("Storage Error: %v\n", err))
+		} else {
+			out.WriteString("CREATE TABLE OK\n")
+		}
+
+	case *sql.CreateIndexStatement:
+		info, err := e.catalog.CreateIndex(s.IndexName, s.TableName, s.Columns, s.Unique)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		table, err := e.catalog.GetTable(s.TableName)
+		if err != nil {
+			return fmt.Sprintf("Execution Error: %v\n", err)
+		}
+		if err := executor.BuildIndex(table.Heap, table.Columns, tableIndex(table, info)); err != nil {
+			// Drop the half-built index so the statement has no effect.
+			if dropErr := e.catalog.DropIndex(s.IndexName); dropErr != nil {
+				err = fmt.Errorf("%v (and dropping the index failed: %v)", err, dropErr)
+			}
+			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
+		} else if err := e.sync(); err != nil {
+			out.WriteString(fmt.Sprintf("Storage Error: %v\n", err))
+		} else {
+			out.WriteString("CREATE INDEX OK\n")
+		}
+
+	case *sql.DropIndexStatement:
+		if err := e.catalog.DropIndex(s.IndexName); err != nil {
+			out.WriteString(fmt.Sprintf("Execution Error: %v\n", err))
+		} else if err := e.sync(); err != nil {
+			out.WriteString(fmt.Sprintf("Storage Error: %v\n", err))
+		} else {
+			out.WriteString("DROP INDEX OK\n")
+		}
+
+	default:
+		out.WriteString("Statement not fully supported yet\n")
+	}
+
+	return out.String()
+}
+
+// tableIndexes describes a table's indexes to the executors.
+func tableIndexes(table *catalog.TableInfo) []executor.TableIndex {
+	indexes := make([]executor.TableIndex, len(table.Indexes))
+	for i, info := range table.Indexes {
+		indexes[i] = tableIndex(table, info)
+	}
+	return indexes
+}
+
+func tableIndex(table *catalog.TableInfo, info *catalog.IndexInfo) executor.TableIndex {
+	columns := make([]int, len(info.Columns))
+	for i, name := range info.Columns {
+		columns[i] = table.ColumnIndex(name)
+	}
+	return executor.TableIndex{
+		Name:    info.Name,
+		Columns: columns,
+		Unique:  info.Unique,
+		Index:   info.Index,
+	}
+}
+
+// formatRow renders a result row as "[v1 v2 ...]".
+func formatRow(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = sql.FormatValue(v)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// runREPL starts an interactive SQL shell.
+func runREPL(engine *Engine) {
+	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Println("Simple RDBMS REPL")
+	fmt.Println("Type 'exit' to quit.")
+
+	for {
+		fmt.Print("db> ")
+		if !scanner.Scan() {
+			break
+		}
+		input := strings.TrimSpace(scanner.Text())
+		if input == "exit" {
+			break
+		}
+		if input == "" {
+			continue
+		}
+		fmt.Print(engine.Execute(input))
+	}
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/catalog"
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/index"
+)
+
+func newTestEngine(t *testing.T) (*Engine, string) {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "engine.db")
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("initEngine failed: %v", err)
+	}
+	return engine, fileName
+}
+
+// mustExecute runs a statement and fails the test if the output
+// does not contain want.
+func mustExecute(t *testing.T, engine *Engine, query, want string) string {
+	t.Helper()
+	out := engine.Execute(query)
+	if !strings.Contains(out, want) {
+		t.Fatalf("%s\nexpected output containing %q, got:\n%s", query, want, out)
+	}
+	return out
+}
+
+func TestEngineMultipleTables(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE users (id INT, name VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, note VARCHAR)", "CREATE TABLE OK")
+
+	mustExecute(t, engine, "INSERT INTO users VALUES (1, 'Ben')", "INSERT OK")
+	// Same key in a different table must not collide with users' index.
+	mustExecute(t, engine, "INSERT INTO orders VALUES (1, 'first')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (2, 'second')", "INSERT OK")
+
+	mustExecute(t, engine, "SELECT * FROM users", "(1 rows)")
+	mustExecute(t, engine, "SELECT * FROM orders", "(2 rows)")
+
+	mustExecute(t, engine, "DELETE FROM orders WHERE id = 2", "DELETE 1 rows")
+	mustExecute(t, engine, "SELECT * FROM users", "(1 rows)")
+	mustExecute(t, engine, "SELECT * FROM orders", "(1 rows)")
+
+	mustExecute(t, engine, "SELECT * FROM missing", "table missing does not exist")
+	mustExecute(t, engine, "INSERT INTO missing VALUES (1, 'x')", "table missing does not exist")
+	mustExecute(t, engine, "SELECT * FROM users JOIN missing ON users.id = missing.id", "table missing does not exist")
+	mustExecute(t, engine, "CREATE TABLE users (id INT)", "already exists")
+}
+
+func TestEngineSurvivesRestart(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+	mustExecute(t, engine, "CREATE TABLE users (id INT, name VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (7, 'Ada')", "INSERT OK")
+	if err := engine.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer engine.Close()
+
+	mustExecute(t, engine, "SELECT * FROM users", "[7 Ada]")
+	mustExecute(t, engine, "INSERT INTO users VALUES (7, 'Ada')", "unique constraint violation")
+}
+
+func TestEngineStoresEveryColumn(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, user_id INT, amount INT)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (101, 1, 500)", "INSERT OK")
+	mustExecute(t, engine, "SELECT * FROM orders", "[101 1 500]")
+
+	mustExecute(t, engine, "INSERT INTO orders VALUES (102, 1)", "expected 3 values, got 2")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (103, 'x', 1)", "column user_id expects INT")
+}
+
+func TestEngineColumnTypes(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+
+	mustExecute(t, engine, "CREATE TABLE payments (id BIGINT, amount DECIMAL(10,2), paid BOOLEAN, due DATE, created TIMESTAMP, rate DOUBLE, receipt BLOB)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO payments VALUES (1, 19.5, TRUE, DATE '2026-01-01', TIMESTAMP '2026-01-01 09:30:00', 0.25, X'CAFE')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO payments VALUES (2, 123456789.99, FALSE, '2026-02-01', '2026-02-01', 1, X'')", "exceeds DECIMAL(10,2)")
+	mustExecute(t, engine, "SELECT * FROM payments", `[1 19.50 true 2026-01-01 2026-01-01 09:30:00 0.25 \xcafe]`)
+	if err := engine.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer engine.Close()
+	mustExecute(t, engine, "SELECT * FROM payments WHERE id = 1", "[1 19.50 true")
+}
+
+func TestEngineNulls(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE users (id INT, email VARCHAR NOT NULL, nickname VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (1, 'ben@example.com', NULL)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (2, NULL, 'x')", "violates NOT NULL constraint")
+	mustExecute(t, engine, "INSERT INTO users VALUES (NULL, 'a@example.com', 'x')", "violates NOT NULL constraint")
+
+	mustExecute(t, engine, "SELECT * FROM users", "[1 ben@example.com NULL]")
+	mustExecute(t, engine, "SELECT * FROM users WHERE id = NULL", "(0 rows)")
+	mustExecute(t, engine, "SELECT * FROM users WHERE id IS NOT NULL", "(1 rows)")
+	mustExecute(t, engine, "DELETE FROM users WHERE id IS NULL", "DELETE 0 rows")
+}
+
+func TestEngineBooleanWhere(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE people (id INT, age INT, city VARCHAR, vip BOOLEAN)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES (1, 30, 'Nairobi', FALSE)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES (2, 17, 'Nairobi', TRUE)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES (3, 40, 'Mombasa', TRUE)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES (4, 25, 'Mombasa', FALSE)", "INSERT OK")
+
+	mustExecute(t, engine, "SELECT * FROM people WHERE age > 18 AND (city = 'Nairobi' OR vip = TRUE)", "(2 rows)")
+	mustExecute(t, engine, "DELETE FROM people WHERE city = 'Mombasa' AND NOT vip", "DELETE 1 rows")
+	mustExecute(t, engine, "SELECT * FROM people", "(3 rows)")
+}
+
+func TestEngineComparisonOperators(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE people (id INT, name VARCHAR, age INT)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES (1, 'Ben', 30)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES (2, 'Bea', 17)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES (3, 'Ann', 40)", "INSERT OK")
+
+	mustExecute(t, engine, "SELECT * FROM people WHERE age>=30", "(2 rows)")
+	mustExecute(t, engine, "SELECT * FROM people WHERE name <> 'Ben' AND age <= 40", "(2 rows)")
+	mustExecute(t, engine, "SELECT * FROM people WHERE name LIKE 'B%'", "(2 rows)")
+	mustExecute(t, engine, "SELECT * FROM people WHERE id IN (1, 3)", "(2 rows)")
+	mustExecute(t, engine, "SELECT * FROM people WHERE age NOT BETWEEN 18 AND 35", "(2 rows)")
+	mustExecute(t, engine, "DELETE FROM people WHERE name NOT LIKE '_e_'", "DELETE 1 rows")
+	mustExecute(t, engine, "SELECT * FROM people WHERE id != 2", "(1 rows)")
+}
+
+func TestEngineUpdate(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+
+	mustExecute(t, engine, "CREATE TABLE counters (id INT, name VARCHAR, hits INT)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO counters VALUES (1, 'home', 0)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO counters VALUES (2, 'about', 5)", "INSERT OK")
+
+	mustExecute(t, engine, "UPDATE counters SET hits = hits + 1", "UPDATE 2 rows")
+	mustExecute(t, engine, "UPDATE counters SET id = 10, name = 'index' WHERE name = 'home'", "UPDATE 1 rows")
+	mustExecute(t, engine, "UPDATE counters SET id = 2 WHERE id = 10", "unique constraint violation")
+	mustExecute(t, engine, "UPDATE counters SET hits = NULL WHERE nothing = 1", "unknown column nothing")
+
+	// The old key is free again and the new one is taken.
+	mustExecute(t, engine, "INSERT INTO counters VALUES (1, 'new', 0)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO counters VALUES (10, 'dup', 0)", "unique constraint violation")
+	engine.Close()
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer engine.Close()
+	mustExecute(t, engine, "SELECT * FROM counters WHERE id = 10", "[10 index 1]")
+	mustExecute(t, engine, "SELECT * FROM counters WHERE hits = 6", "[2 about 6]")
+}
+
+func TestEngineProjection(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE users (id INT, name VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, user_id INT, amount INT)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (1, 'Ben')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (101, 1, 500)", "INSERT OK")
+
+	mustExecute(t, engine, "SELECT * FROM users", "id name\n----------------\n[1 Ben]\n")
+	mustExecute(t, engine, "SELECT name FROM users", "name\n----------------\n[Ben]\n")
+	mustExecute(t, engine, "SELECT name, orders.id AS order_id, amount * 2 FROM users JOIN orders ON users.id = orders.user_id",
+		"name order_id (amount * 2)\n----------------\n[Ben 101 1000]\n")
+	mustExecute(t, engine, "SELECT orders.*, users.name FROM users JOIN orders ON users.id = orders.user_id",
+		"id user_id amount name\n----------------\n[101 1 500 Ben]\n")
+
+	mustExecute(t, engine, "SELECT id FROM users JOIN orders ON users.id = orders.user_id", "ambiguous column id")
+	mustExecute(t, engine, "SELECT items.* FROM users", "unknown table items")
+}
+
+func TestEngineJoinOnNonFirstColumn(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE users (id INT, name VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, user_id INT, amount INT)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (1, 'Ben')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (2, 'Ann')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (101, 1, 500)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (102, 2, 300)", "INSERT OK")
+
+	out := mustExecute(t, engine, "SELECT * FROM users JOIN orders ON users.id = orders.user_id", "(2 rows)")
+	if !strings.Contains(out, "[1 Ben 101 1 500]") || !strings.Contains(out, "[2 Ann 102 2 300]") {
+		t.Errorf("unexpected join output:\n%s", out)
+	}
+	mustExecute(t, engine, "SELECT * FROM users JOIN orders ON orders.user_id = users.id WHERE amount > 400", "(1 rows)")
+	mustExecute(t, engine, "SELECT * FROM users JOIN orders ON users.id = orders.user_id WHERE orders.id = 102 AND name = 'Ann'", "(1 rows)")
+
+	mustExecute(t, engine, "SELECT * FROM users JOIN orders ON users.id = orders.user_id WHERE id = 1", "ambiguous column id")
+	mustExecute(t, engine, "SELECT * FROM users WHERE missing = 1", "unknown column missing")
+	mustExecute(t, engine, "DELETE FROM users WHERE orders.amount = 1", "unknown column orders.amount")
+}
+
+func TestEngineDeleteAndReinsert(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+
+	mustExecute(t, engine, "CREATE TABLE items (id INT, name VARCHAR)", "CREATE TABLE OK")
+	for i := 0; i < 1000; i++ {
+		mustExecute(t, engine, fmt.Sprintf("INSERT INTO items VALUES (%d, 'item')", i), "INSERT OK")
+	}
+	// Emptying most leaves merges them and frees their pages.
+	mustExecute(t, engine, "DELETE FROM items WHERE id >= 10", "DELETE 990 rows")
+	mustExecute(t, engine, "INSERT INTO items VALUES (500, 'again')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO items VALUES (5, 'dup')", "unique constraint violation")
+	engine.Close()
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer engine.Close()
+	mustExecute(t, engine, "SELECT * FROM items", "(11 rows)")
+	mustExecute(t, engine, "SELECT * FROM items WHERE id = 500", "[500 again]")
+}
+
+func TestEngineSecondaryIndex(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+
+	mustExecute(t, engine, "CREATE TABLE users (id INT, badge INT, name VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (1, 10, 'ann')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (2, 20, 'ben')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (3, NULL, 'cy')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (4, 20, 'dee')", "INSERT OK")
+
+	// Existing duplicates make the build fail and leave no index behind.
+	mustExecute(t, engine, "CREATE UNIQUE INDEX users_badge ON users (badge)", "key 20 already exists in users_badge")
+	mustExecute(t, engine, "DROP INDEX users_badge", "index users_badge does not exist")
+	mustExecute(t, engine, "DELETE FROM users WHERE id = 4", "DELETE 1 rows")
+	mustExecute(t, engine, "CREATE UNIQUE INDEX users_badge ON users (badge)", "CREATE INDEX OK")
+
+	mustExecute(t, engine, "CREATE UNIQUE INDEX users_badge ON users (id)", "index users_badge already exists")
+	mustExecute(t, engine, "CREATE UNIQUE INDEX users_name ON users (name, NAME)", "appears more than once")
+	mustExecute(t, engine, "CREATE UNIQUE INDEX users_x ON users (x)", "has no column x")
+	mustExecute(t, engine, "DROP INDEX users_pkey", "cannot drop primary key index")
+
+	// The index is maintained by every statement; NULLs are not indexed.
+	mustExecute(t, engine, "INSERT INTO users VALUES (5, 10, 'eve')", "key 10 already exists in users_badge")
+	mustExecute(t, engine, "INSERT INTO users VALUES (5, NULL, 'eve')", "INSERT OK")
+	mustExecute(t, engine, "UPDATE users SET badge = 30 - badge WHERE badge IS NOT NULL", "UPDATE 2 rows")
+	mustExecute(t, engine, "UPDATE users SET badge = 20 WHERE id = 3", "key 20 already exists in users_badge")
+	mustExecute(t, engine, "DELETE FROM users WHERE badge = 20", "DELETE 1 rows")
+	mustExecute(t, engine, "INSERT INTO users VALUES (6, 20, 'fay')", "INSERT OK")
+	engine.Close()
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer engine.Close()
+	mustExecute(t, engine, "INSERT INTO users VALUES (7, 10, 'gus')", "key 10 already exists in users_badge")
+	mustExecute(t, engine, "DROP INDEX users_badge", "DROP INDEX OK")
+	mustExecute(t, engine, "INSERT INTO users VALUES (7, 10, 'gus')", "INSERT OK")
+	mustExecute(t, engine, "SELECT * FROM users WHERE badge = 10", "(2 rows)")
+}
+
+func TestEngineVariableLengthKeys(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+
+	// A VARCHAR primary key and a composite index over mixed types.
+	mustExecute(t, engine, "CREATE TABLE people (email VARCHAR, last VARCHAR, first VARCHAR, born DATE)", "CREATE TABLE OK")
+	mustExecute(t, engine, "CREATE UNIQUE INDEX people_name ON people (last, first)", "CREATE INDEX OK")
+	for i := 0; i < 500; i++ {
+		query := fmt.Sprintf("INSERT INTO people VALUES ('user%03d@example.com', 'Last%d', 'First%03d', '1990-01-%02d')", i, i%7, i, i%28+1)
+		mustExecute(t, engine, query, "INSERT OK")
+	}
+
+	mustExecute(t, engine, "INSERT INTO people VALUES ('user007@example.com', 'New', 'Person', NULL)", "key user007@example.com already exists in people_pkey")
+	mustExecute(t, engine, "INSERT INTO people VALUES ('new@example.com', 'Last0', 'First000', NULL)", "key (Last0, First000) already exists in people_name")
+	mustExecute(t, engine, "INSERT INTO people VALUES ('new@example.com', 'Last0', NULL, NULL)", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO people VALUES ('new2@example.com', 'Last0', NULL, NULL)", "INSERT OK")
+	mustExecute(t, engine, "CREATE UNIQUE INDEX people_born ON people (born, last)", "already exists in people_born")
+	mustExecute(t, engine, "CREATE UNIQUE INDEX people_born ON people (born, first)", "CREATE INDEX OK")
+
+	// Keys longer than a node can hold are rejected before the row is written.
+	long := strings.Repeat("x", 2000)
+	mustExecute(t, engine, "INSERT INTO people VALUES ('"+long+"', 'a', 'b', NULL)", "exceeds the maximum")
+	mustExecute(t, engine, "SELECT * FROM people WHERE last = 'a'", "(0 rows)")
+
+	// Updates move rows between composite keys and are checked like inserts.
+	mustExecute(t, engine, "UPDATE people SET first = 'First001' WHERE email = 'user000@example.com'", "UPDATE 1 rows")
+	mustExecute(t, engine, "UPDATE people SET last = 'Last0' WHERE email = 'user001@example.com'", "key (Last0, First001) already exists in people_name")
+	mustExecute(t, engine, "DELETE FROM people WHERE email >= 'user250'", "DELETE 250 rows")
+	engine.Close()
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer engine.Close()
+	mustExecute(t, engine, "INSERT INTO people VALUES ('user100@example.com', 'x', 'y', NULL)", "key user100@example.com already exists in people_pkey")
+	mustExecute(t, engine, "INSERT INTO people VALUES ('user300@example.com', 'Last6', 'First300', NULL)", "INSERT OK")
+	mustExecute(t, engine, "SELECT * FROM people", "(253 rows)")
+}
+
+// indexedIDs returns the ids of the rows an index holds under value,
+// reading each row back from the heap.
+func indexedIDs(t *testing.T, engine *Engine, tableName, indexName string, value interface{}) []int {
+	t.Helper()
+	table, err := engine.catalog.GetTable(tableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info *catalog.IndexInfo
+	for _, idx := range table.Indexes {
+		if idx.Name == indexName {
+			info = idx
+		}
+	}
+	if info == nil {
+		t.Fatalf("index %s not found", indexName)
+	}
+	key, err := index.EncodeKey(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	it := info.Index.SearchAll(key)
+	for {
+		_, rid, ok, err := it.Next()
+		if err != nil {
+			t.Fatalf("SearchAll failed: %v", err)
+		}
+		if !ok {
+			break
+		}
+		data, err := table.Heap.GetTuple(rid)
+		if err != nil {
+			t.Fatalf("index entry points at a missing tuple: %v", err)
+		}
+		tuple, err := executor.DeserializeTuple(table.Columns, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tuple.Values[0].(int))
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestEngineNonUniqueIndex(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, user_id INT, note VARCHAR)", "CREATE TABLE OK")
+	for i := 1; i <= 300; i++ {
+		mustExecute(t, engine, fmt.Sprintf("INSERT INTO orders VALUES (%d, %d, 'n')", i, i%3), "INSERT OK")
+	}
+	mustExecute(t, engine, "CREATE INDEX orders_user ON orders (user_id)", "CREATE INDEX OK")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (301, 1, 'n')", "INSERT OK")
+	mustExecute(t, engine, "INSERT INTO orders VALUES (302, NULL, 'n')", "INSERT OK")
+
+	if got := indexedIDs(t, engine, "orders", "orders_user", 1); len(got) != 101 || got[100] != 301 {
+		t.Fatalf("expected 101 orders for user 1 ending in 301, got %d: %v", len(got), got)
+	}
+
+	// Deletes and updates remove exactly the rows' own entries, including
+	// rows that move to another page when they grow.
+	mustExecute(t, engine, "DELETE FROM orders WHERE user_id = 2 AND id > 10", "DELETE 97 rows")
+	mustExecute(t, engine, "UPDATE orders SET user_id = 2 WHERE id IN (3, 6)", "UPDATE 2 rows")
+	mustExecute(t, engine, "UPDATE orders SET note = '"+strings.Repeat("x", 1000)+"' WHERE id IN (1, 2)", "UPDATE 2 rows")
+	engine.Close()
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer engine.Close()
+	if got, want := indexedIDs(t, engine, "orders", "orders_user", 2), []int{2, 3, 5, 6, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected orders %v for user 2, got %v", want, got)
+	}
+	if got := indexedIDs(t, engine, "orders", "orders_user", 0); len(got) != 98 || got[0] != 9 {
+		t.Errorf("expected 98 orders for user 0 starting at 9, got %d: %v", len(got), got)
+	}
+	if got := indexedIDs(t, engine, "orders", "orders_user", 1); len(got) != 101 || got[0] != 1 {
+		t.Errorf("expected 101 orders for user 1 starting at 1, got %d: %v", len(got), got)
+	}
+}
+
+func TestEngineIndexedWhere(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE users (id INT, name VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, user_id INT NOT NULL, amount INT)", "CREATE TABLE OK")
+	mustExecute(t, engine, "CREATE INDEX orders_user ON orders (user_id)", "CREATE INDEX OK")
+	for i := 1; i <= 50; i++ {
+		mustExecute(t, engine, fmt.Sprintf("INSERT INTO users VALUES (%d, 'u%d')", i, i), "INSERT OK")
+	}
+	for i := 1; i <= 500; i++ {
+		mustExecute(t, engine, fmt.Sprintf("INSERT INTO orders VALUES (%d, %d, %d)", i, i%50+1, i), "INSERT OK")
+	}
+
+	mustExecute(t, engine, "SELECT * FROM orders WHERE id = 42", "[42 43 42]")
+	mustExecute(t, engine, "SELECT * FROM orders WHERE id > 100 AND id <= 120", "(20 rows)")
+	mustExecute(t, engine, "SELECT * FROM orders WHERE id BETWEEN 1 AND 10 AND amount > 5", "(5 rows)")
+	mustExecute(t, engine, "SELECT * FROM orders WHERE user_id = 7", "(10 rows)")
+	mustExecute(t, engine, "SELECT * FROM orders WHERE user_id = 7 OR id = 1", "(11 rows)")
+	mustExecute(t, engine, "SELECT * FROM orders WHERE id = 2.5", "(0 rows)")
+
+	// The scanned table is the left side of the join.
+	out := mustExecute(t, engine, "SELECT * FROM orders JOIN users ON orders.user_id = users.id WHERE orders.id < 3", "(2 rows)")
+	if !strings.Contains(out, "[1 2 1 2 u2]") || !strings.Contains(out, "[2 3 2 3 u3]") {
+		t.Errorf("unexpected join output:\n%s", out)
+	}
+	mustExecute(t, engine, "SELECT * FROM users JOIN orders ON users.id = orders.user_id WHERE users.id = 4 AND amount > 250", "(5 rows)")
+
+	mustExecute(t, engine, "DELETE FROM orders WHERE user_id = 7", "DELETE 10 rows")
+	mustExecute(t, engine, "UPDATE orders SET user_id = 7 WHERE id >= 491", "UPDATE 10 rows")
+	mustExecute(t, engine, "UPDATE orders SET amount = 0 WHERE user_id = 7", "UPDATE 10 rows")
+	mustExecute(t, engine, "SELECT * FROM orders WHERE user_id = 7 AND amount = 0", "(10 rows)")
+	mustExecute(t, engine, "SELECT * FROM orders", "(490 rows)")
+}
+
+// TestEngineBulkLoadedIndex builds indexes several levels deep over an
+// existing table and keeps using them after a restart.
+func TestEngineBulkLoadedIndex(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+
+	mustExecute(t, engine, "CREATE TABLE events (id INT, kind INT, tag VARCHAR)", "CREATE TABLE OK")
+	for i := 0; i < 5000; i++ {
+		mustExecute(t, engine, fmt.Sprintf("INSERT INTO events VALUES (%d, %d, 'tag-%05d')", i, i%7, (i*7919)%5000), "INSERT OK")
+	}
+	mustExecute(t, engine, "CREATE INDEX events_kind ON events (kind)", "CREATE INDEX OK")
+	mustExecute(t, engine, "CREATE UNIQUE INDEX events_tag ON events (tag)", "CREATE INDEX OK")
+	engine.Close()
+
+	engine, err := initEngine(fileName)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer engine.Close()
+	if got := indexedIDs(t, engine, "events", "events_kind", 3); len(got) != 714 || got[0] != 3 || got[713] != 4994 {
+		t.Errorf("expected 714 events of kind 3 from 3 to 4994, got %d: %v", len(got), got)
+	}
+	mustExecute(t, engine, "SELECT * FROM events WHERE tag = 'tag-00001'", "[2679 5 tag-00001]")
+	mustExecute(t, engine, "INSERT INTO events VALUES (5000, 3, 'tag-00001')", "key tag-00001 already exists in events_tag")
+	mustExecute(t, engine, "DELETE FROM events WHERE kind = 3 AND id < 4000", "DELETE 571 rows")
+	mustExecute(t, engine, "INSERT INTO events VALUES (5000, 3, 'tag-05000')", "INSERT OK")
+	if got := indexedIDs(t, engine, "events", "events_kind", 3); len(got) != 144 || got[143] != 5000 {
+		t.Errorf("expected 144 events of kind 3 ending in 5000, got %d: %v", len(got), got)
+	}
+}
+
+func TestEngineVerify(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE users (id INT, name VARCHAR)", "CREATE TABLE OK")
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, user_id INT)", "CREATE TABLE OK")
+	for i := 0; i < 1000; i++ {
+		mustExecute(t, engine, fmt.Sprintf("INSERT INTO orders VALUES (%d, %d)", i, i%10), "INSERT OK")
+	}
+	mustExecute(t, engine, "CREATE INDEX orders_user ON orders (user_id)", "CREATE INDEX OK")
+
+	var out strings.Builder
+	if !engine.verify(&out) {
+		t.Fatalf("expected every index to pass:\n%s", out.String())
+	}
+	want := "users.users_pkey: OK (0 entries, 1 leaves, 0 internal nodes, depth 1)\n" +
+		"orders.orders_pkey: OK (1000 entries"
+	if !strings.HasPrefix(out.String(), want) || !strings.Contains(out.String(), "orders.orders_user: OK (1000 entries") {
+		t.Errorf("unexpected verify output:\n%s", out.String())
+	}
+
+	// An entry removed behind the executor's back is reported. Row 3
+	// has user_id 3, so the same key finds it in both indexes.
+	table, _ := engine.catalog.GetTable("orders")
+	key, _ := index.EncodeKey(3)
+	rid, err := table.Indexes[0].Index.Search(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Indexes[1].Index.DeleteEntry(key, rid); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if engine.verify(&out) {
+		t.Fatalf("expected orders_user to fail:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "orders.orders_user: FAILED: index orders_user: row") ||
+		!strings.Contains(out.String(), "orders.orders_pkey: OK") {
+		t.Errorf("unexpected verify output:\n%s", out.String())
+	}
+}
+
+func TestEngineVerifyLeavesFileUntouched(t *testing.T) {
+	engine, fileName := newTestEngine(t)
+	mustExecute(t, engine, "CREATE TABLE orders (id INT, user_id INT)", "CREATE TABLE OK")
+	for i := 0; i < 100; i++ {
+		mustExecute(t, engine, fmt.Sprintf("INSERT INTO orders VALUES (%d, %d)", i, i%10), "INSERT OK")
+	}
+	mustExecute(t, engine, "CREATE INDEX orders_user ON orders (user_id)", "CREATE INDEX OK")
+	if err := engine.Close(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Damage an index in memory only; verify must report it without
+	// writing the damage, or anything else, back to the file.
+	engine, err = initEngine(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, _ := engine.catalog.GetTable("orders")
+	key, _ := index.EncodeKey(3)
+	rid, err := table.Indexes[0].Index.Search(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Indexes[1].Index.DeleteEntry(key, rid); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if engine.verify(&out) {
+		t.Fatalf("expected orders_user to fail:\n%s", out.String())
+	}
+	if err := engine.closeReadOnly(); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("expected verify to leave the database file unchanged")
+	}
+}
+
+func TestEngineUpdateOverflow(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	defer engine.Close()
+
+	mustExecute(t, engine, "CREATE TABLE t (id INT, big BIGINT)", "CREATE TABLE OK")
+	mustExecute(t, engine, "INSERT INTO t VALUES (1, 9223372036854775000)", "INSERT OK")
+	mustExecute(t, engine, "UPDATE t SET big = big * 2", "out of range")
+	mustExecute(t, engine, "UPDATE t SET big = big + 1000", "out of range")
+	mustExecute(t, engine, "SELECT big FROM t", "[9223372036854775000]")
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/benkivuva/my-rdbms/internal/executor"
+)
+
+// verify checks the structure of every index and that it matches its
+// table's rows, writing one line per index to out. It reports whether
+// every index passed.
+func (e *Engine) verify(out io.Writer) bool {
+	passed := true
+	for _, table := range e.catalog.Tables() {
+		for _, idx := range tableIndexes(table) {
+			stats, err := idx.Index.Verify()
+			if err == nil {
+				err = executor.VerifyIndex(table.Heap, table.Columns, idx)
+			}
+			if err != nil {
+				fmt.Fprintf(out, "%s.%s: FAILED: %v\n", table.Name, idx.Name, err)
+				passed = false
+				continue
+			}
+			fmt.Fprintf(out, "%s.%s: OK (%d entries, %d leaves, %d internal nodes, depth %d)\n",
+				table.Name, idx.Name, stats.Entries, stats.Leaves, stats.Internal, stats.Depth)
+		}
+	}
+	return passed
+}
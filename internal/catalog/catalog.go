@@ -0,0 +1,228 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// CatalogPageID is the page reserved for the system catalog.
+const CatalogPageID storage.PageID = 0
+
+// IndexInfo describes an index on one or more columns of a table.
+type IndexInfo struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Index   *index.BTreeIndex
+}
+
+// TableInfo describes a table: its schema, heap and indexes.
+type TableInfo struct {
+	Name    string
+	Columns []sql.ColumnDef
+	Heap    *storage.TableHeap
+	Indexes []*IndexInfo
+}
+
+// PrimaryIndex returns the index on the table's first column.
+func (t *TableInfo) PrimaryIndex() *IndexInfo {
+	if len(t.Indexes) == 0 {
+		return nil
+	}
+	return t.Indexes[0]
+}
+
+// ColumnIndex returns the position of the named column, matched
+// case-insensitively, or -1 if the table has no such column.
+func (t *TableInfo) ColumnIndex(name string) int {
+	for i, col := range t.Columns {
+		if strings.EqualFold(col.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Catalog keeps track of all tables in the database and persists
+// their metadata on the catalog page.
+type Catalog struct {
+	bufferPool  *storage.BufferPool
+	diskManager *storage.DiskManager
+	tables      map[string]*TableInfo
+	order       []string
+}
+
+// NewCatalog loads the catalog from the database file, or initializes
+// the catalog page if the file is empty.
+func NewCatalog(bp *storage.BufferPool, dm *storage.DiskManager) (*Catalog, error) {
+	c := &Catalog{
+		bufferPool:  bp,
+		diskManager: dm,
+		tables:      make(map[string]*TableInfo),
+	}
+
+	numPages, err := dm.NumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	if numPages == 0 {
+		page, err := bp.NewPage()
+		if err != nil {
+			return nil, err
+		}
+		if page.ID != CatalogPageID {
+			bp.UnpinPage(page.ID, false)
+			return nil, fmt.Errorf("catalog page allocated at %d, expected %d", page.ID, CatalogPageID)
+		}
+		initCatalogPage(page.GetData())
+		bp.UnpinPage(page.ID, true)
+		return c, nil
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CreateTable registers a new table, allocating its heap and a
+// primary index on the first column.
+func (c *Catalog) CreateTable(name string, columns []sql.ColumnDef) (*TableInfo, error) {
+	if _, ok := c.tables[name]; ok {
+		return nil, fmt.Errorf("table %s already exists", name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s must have at least one column", name)
+	}
+	if table, _ := c.findIndex(name + "_pkey"); table != nil {
+		return nil, fmt.Errorf("index %s_pkey already exists", name)
+	}
+
+	// The primary key can never be NULL.
+	columns = append([]sql.ColumnDef(nil), columns...)
+	columns[0].NotNull = true
+
+	heap, err := storage.NewTableHeap(c.bufferPool, storage.InvalidPageID)
+	if err != nil {
+		return nil, err
+	}
+	btree, err := index.NewBTreeIndex(c.bufferPool, storage.InvalidPageID)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &TableInfo{
+		Name:    name,
+		Columns: columns,
+		Heap:    heap,
+		Indexes: []*IndexInfo{{
+			Name:    name + "_pkey",
+			Columns: []string{columns[0].Name},
+			Unique:  true,
+			Index:   btree,
+		}},
+	}
+	c.tables[name] = table
+	c.order = append(c.order, name)
+
+	if err := c.Save(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// CreateIndex registers a new, empty index on columns of a table, in
+// the order given. The caller is responsible for filling it from the
+// table's heap.
+func (c *Catalog) CreateIndex(name, tableName string, columns []string, unique bool) (*IndexInfo, error) {
+	if table, _ := c.findIndex(name); table != nil {
+		return nil, fmt.Errorf("index %s already exists", name)
+	}
+	table, err := c.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("index %s must have at least one column", name)
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		pos := table.ColumnIndex(column)
+		if pos < 0 {
+			return nil, fmt.Errorf("table %s has no column %s", tableName, column)
+		}
+		names[i] = table.Columns[pos].Name
+		for _, prev := range names[:i] {
+			if prev == names[i] {
+				return nil, fmt.Errorf("column %s appears more than once in index %s", names[i], name)
+			}
+		}
+	}
+
+	btree, err := index.NewBTreeIndex(c.bufferPool, storage.InvalidPageID)
+	if err != nil {
+		return nil, err
+	}
+	info := &IndexInfo{Name: name, Columns: names, Unique: unique, Index: btree}
+	table.Indexes = append(table.Indexes, info)
+
+	if err := c.Save(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DropIndex removes an index and frees its pages. A table's primary
+// key index cannot be dropped.
+func (c *Catalog) DropIndex(name string) error {
+	table, i := c.findIndex(name)
+	if table == nil {
+		return fmt.Errorf("index %s does not exist", name)
+	}
+	if i == 0 {
+		return fmt.Errorf("cannot drop primary key index %s", name)
+	}
+
+	info := table.Indexes[i]
+	table.Indexes = append(table.Indexes[:i:i], table.Indexes[i+1:]...)
+	if err := info.Index.Drop(); err != nil {
+		return err
+	}
+	return c.Save()
+}
+
+// findIndex returns the table holding the named index and the index's
+// position in it, or a nil table if no index has that name.
+func (c *Catalog) findIndex(name string) (*TableInfo, int) {
+	for _, table := range c.tables {
+		for i, info := range table.Indexes {
+			if info.Name == name {
+				return table, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// GetTable looks up a table by name.
+func (c *Catalog) GetTable(name string) (*TableInfo, error) {
+	table, ok := c.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("table %s does not exist", name)
+	}
+	return table, nil
+}
+
+// Tables returns all tables in creation order.
+func (c *Catalog) Tables() []*TableInfo {
+	out := make([]*TableInfo, 0, len(c.order))
+	for _, name := range c.order {
+		out = append(out, c.tables[name])
+	}
+	return out
+}
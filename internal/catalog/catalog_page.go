@@ -0,0 +1,374 @@
+package catalog
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// Catalog Page Layout (4KB):
+// Header (20 bytes):
+//   [0-3]:   Magic (uint32)
+//   [4-7]:   Version (uint32)
+//   [8-15]:  NextPageID (int64, overflow chain)
+//   [16-19]: PayloadLength (uint32)
+// Payload: serialized table metadata, continued on overflow pages.
+//
+// Payload:
+//   NumTables(2), then per table:
+//     Name, NumColumns(2), [ColName, ColType(1), Length(2), Precision(1), Scale(1), NotNull(1)]...,
+//     FirstPageID(8), NumIndexes(2), [IdxName, NumIdxColumns(2), [Column]..., Unique(1), RootPageID(8)]...
+//   NumFreePages(4), [PageID(8)]...
+// Strings are stored as Length(2) followed by the raw bytes.
+
+const (
+	catalogMagic   = 0x52444243 // "RDBC"
+	catalogVersion = 5
+
+	offsetMagic      = 0
+	offsetVersion    = 4
+	offsetNextPageID = 8
+	offsetPayloadLen = 16
+	catalogHeader    = 20
+	catalogCapacity  = storage.PageSize - catalogHeader
+)
+
+// Save writes the catalog to the catalog page chain. Overflow pages
+// that are no longer needed stay linked with an empty payload so later
+// saves can reuse them.
+func (c *Catalog) Save() error {
+	// Growing the chain may take pages off the free list, so make room
+	// first and serialize the free list afterwards.
+	if err := c.reserve(len(c.serialize())); err != nil {
+		return err
+	}
+	payload := c.serialize()
+
+	currPageID := CatalogPageID
+	for currPageID != storage.InvalidPageID {
+		page, err := c.bufferPool.FetchPage(currPageID)
+		if err != nil {
+			return err
+		}
+		data := page.GetData()
+
+		n := min(len(payload), catalogCapacity)
+		binary.BigEndian.PutUint32(data[offsetPayloadLen:], uint32(n))
+		copy(data[catalogHeader:], payload[:n])
+		payload = payload[n:]
+
+		nextID := getNextPageID(data)
+		c.bufferPool.UnpinPage(currPageID, true)
+		currPageID = nextID
+	}
+	if len(payload) > 0 {
+		return fmt.Errorf("catalog grew while saving")
+	}
+	return nil
+}
+
+// reserve extends the catalog page chain to hold size payload bytes.
+func (c *Catalog) reserve(size int) error {
+	currPageID := CatalogPageID
+	for size -= catalogCapacity; size > 0; size -= catalogCapacity {
+		page, err := c.bufferPool.FetchPage(currPageID)
+		if err != nil {
+			return err
+		}
+		data := page.GetData()
+
+		nextID := getNextPageID(data)
+		dirty := false
+		if nextID == storage.InvalidPageID {
+			next, err := c.bufferPool.NewPage()
+			if err != nil {
+				c.bufferPool.UnpinPage(currPageID, false)
+				return err
+			}
+			initCatalogPage(next.GetData())
+			nextID = next.ID
+			c.bufferPool.UnpinPage(next.ID, true)
+			setNextPageID(data, nextID)
+			dirty = true
+		}
+
+		c.bufferPool.UnpinPage(currPageID, dirty)
+		currPageID = nextID
+	}
+	return nil
+}
+
+// load reads the catalog from the catalog page chain. Save fills each
+// page before moving to the next, so payload after a partly filled page
+// or a page reached twice means the chain is corrupt.
+func (c *Catalog) load() error {
+	var payload []byte
+	seen := make(map[storage.PageID]bool)
+	partial := false
+
+	currPageID := CatalogPageID
+	for currPageID != storage.InvalidPageID {
+		if seen[currPageID] {
+			return fmt.Errorf("corrupt catalog: page %d appears twice in the overflow chain", currPageID)
+		}
+		seen[currPageID] = true
+
+		page, err := c.bufferPool.FetchPage(currPageID)
+		if err != nil {
+			return err
+		}
+		data := page.GetData()
+
+		if !hasMagic(data) {
+			c.bufferPool.UnpinPage(currPageID, false)
+			return fmt.Errorf("page %d is not a catalog page: unrecognized database file", currPageID)
+		}
+		if v := binary.BigEndian.Uint32(data[offsetVersion:]); v != catalogVersion {
+			c.bufferPool.UnpinPage(currPageID, false)
+			return fmt.Errorf("unsupported catalog version %d", v)
+		}
+
+		n := int(binary.BigEndian.Uint32(data[offsetPayloadLen:]))
+		if n > catalogCapacity {
+			c.bufferPool.UnpinPage(currPageID, false)
+			return fmt.Errorf("corrupt catalog: page %d holds %d payload bytes, more than its capacity of %d", currPageID, n, catalogCapacity)
+		}
+		if partial && n > 0 {
+			c.bufferPool.UnpinPage(currPageID, false)
+			return fmt.Errorf("corrupt catalog: page %d continues a payload that ended after %d bytes", currPageID, len(payload))
+		}
+		partial = n < catalogCapacity
+		payload = append(payload, data[catalogHeader:catalogHeader+n]...)
+		nextID := getNextPageID(data)
+		c.bufferPool.UnpinPage(currPageID, false)
+		currPageID = nextID
+	}
+
+	return c.deserialize(payload)
+}
+
+// initCatalogPage writes an empty catalog page header.
+func initCatalogPage(data []byte) {
+	binary.BigEndian.PutUint32(data[offsetMagic:], catalogMagic)
+	binary.BigEndian.PutUint32(data[offsetVersion:], catalogVersion)
+	setNextPageID(data, storage.InvalidPageID)
+	binary.BigEndian.PutUint32(data[offsetPayloadLen:], 0)
+}
+
+func getNextPageID(data []byte) storage.PageID {
+	return storage.PageID(int64(binary.BigEndian.Uint64(data[offsetNextPageID:])))
+}
+
+func setNextPageID(data []byte, pid storage.PageID) {
+	binary.BigEndian.PutUint64(data[offsetNextPageID:], uint64(pid))
+}
+
+func hasMagic(data []byte) bool {
+	return binary.BigEndian.Uint32(data[offsetMagic:]) == catalogMagic
+}
+
+func (c *Catalog) serialize() []byte {
+	var enc encoder
+	tables := c.Tables()
+	enc.putUint16(uint16(len(tables)))
+	for _, t := range tables {
+		enc.putString(t.Name)
+		enc.putUint16(uint16(len(t.Columns)))
+		for _, col := range t.Columns {
+			enc.putString(col.Name)
+			enc.putUint8(uint8(col.Type))
+			enc.putUint16(uint16(col.Length))
+			enc.putUint8(uint8(col.Precision))
+			enc.putUint8(uint8(col.Scale))
+			enc.putBool(col.NotNull)
+		}
+		enc.putInt64(int64(t.Heap.FirstPageID()))
+		enc.putUint16(uint16(len(t.Indexes)))
+		for _, idx := range t.Indexes {
+			enc.putString(idx.Name)
+			enc.putUint16(uint16(len(idx.Columns)))
+			for _, col := range idx.Columns {
+				enc.putString(col)
+			}
+			enc.putBool(idx.Unique)
+			enc.putInt64(int64(idx.Index.RootPageID()))
+		}
+	}
+
+	free := c.diskManager.FreePages()
+	enc.putUint32(uint32(len(free)))
+	for _, pid := range free {
+		enc.putInt64(int64(pid))
+	}
+	return enc.buf
+}
+
+func (c *Catalog) deserialize(payload []byte) error {
+	if len(payload) == 0 {
+		return nil
+	}
+	dec := decoder{buf: payload}
+
+	numTables := int(dec.uint16())
+	for i := 0; i < numTables && dec.err == nil; i++ {
+		table := &TableInfo{Name: dec.string()}
+
+		numCols := int(dec.uint16())
+		for j := 0; j < numCols && dec.err == nil; j++ {
+			col := sql.ColumnDef{Name: dec.string(), Type: sql.FieldType(dec.uint8())}
+			col.Length = int(dec.uint16())
+			col.Precision = int(dec.uint8())
+			col.Scale = int(dec.uint8())
+			col.NotNull = dec.bool()
+			table.Columns = append(table.Columns, col)
+		}
+
+		firstPageID := storage.PageID(dec.int64())
+		numIdx := int(dec.uint16())
+		for j := 0; j < numIdx && dec.err == nil; j++ {
+			info := &IndexInfo{Name: dec.string()}
+			numIdxCols := int(dec.uint16())
+			for k := 0; k < numIdxCols && dec.err == nil; k++ {
+				info.Columns = append(info.Columns, dec.string())
+			}
+			info.Unique = dec.bool()
+			rootID := storage.PageID(dec.int64())
+			if dec.err != nil {
+				break
+			}
+			btree, err := index.NewBTreeIndex(c.bufferPool, rootID)
+			if err != nil {
+				return err
+			}
+			info.Index = btree
+			table.Indexes = append(table.Indexes, info)
+		}
+		if dec.err != nil {
+			break
+		}
+
+		heap, err := storage.NewTableHeap(c.bufferPool, firstPageID)
+		if err != nil {
+			return err
+		}
+		table.Heap = heap
+
+		c.tables[table.Name] = table
+		c.order = append(c.order, table.Name)
+	}
+
+	numFree := int(dec.uint32())
+	free := make([]storage.PageID, 0, numFree)
+	for i := 0; i < numFree && dec.err == nil; i++ {
+		free = append(free, storage.PageID(dec.int64()))
+	}
+
+	if dec.err != nil {
+		return fmt.Errorf("corrupt catalog: %w", dec.err)
+	}
+	c.diskManager.SetFreePages(free)
+	return nil
+}
+
+// encoder appends big-endian values to a byte buffer.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) putUint8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) putBool(v bool) {
+	if v {
+		e.putUint8(1)
+	} else {
+		e.putUint8(0)
+	}
+}
+
+func (e *encoder) putUint16(v uint16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, v)
+}
+
+func (e *encoder) putUint32(v uint32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) putInt64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *encoder) putString(s string) {
+	e.putUint16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// decoder reads values written by encoder. The first short read
+// sets err and all later reads return zero values.
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if d.pos+n > len(d.buf) {
+		d.err = fmt.Errorf("unexpected end of payload at offset %d", d.pos)
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) bool() bool {
+	return d.uint8() != 0
+}
+
+func (d *decoder) uint16() uint16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) int64() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *decoder) string() string {
+	n := int(d.uint16())
+	b := d.next(n)
+	if b == nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,294 @@
+package catalog_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/catalog"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+func openCatalog(t *testing.T, fileName string) (*catalog.Catalog, *storage.BufferPool, *storage.DiskManager) {
+	t.Helper()
+	dm, err := storage.NewDiskManager(fileName)
+	if err != nil {
+		t.Fatalf("Failed to create DiskManager: %v", err)
+	}
+	bp := storage.NewBufferPool(50, dm)
+	cat, err := catalog.NewCatalog(bp, dm)
+	if err != nil {
+		dm.Close()
+		t.Fatalf("Failed to open catalog: %v", err)
+	}
+	return cat, bp, dm
+}
+
+func TestCatalogSurvivesRestart(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "catalog.db")
+
+	cat, bp, dm := openCatalog(t, fileName)
+	users, err := cat.CreateTable("users", []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeInt},
+		{Name: "name", Type: sql.TypeVarchar},
+	})
+	if err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	if _, err := cat.CreateTable("users", users.Columns); err == nil {
+		t.Fatal("Expected duplicate table error")
+	}
+
+	rid, err := users.Heap.InsertTuple([]byte("persisted"))
+	if err != nil {
+		t.Fatalf("InsertTuple failed: %v", err)
+	}
+	if err := users.PrimaryIndex().Index.Insert([]byte("1"), rid); err != nil {
+		t.Fatalf("Index insert failed: %v", err)
+	}
+
+	if err := cat.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := bp.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	dm.Close()
+
+	cat, _, dm = openCatalog(t, fileName)
+	defer dm.Close()
+
+	reloaded, err := cat.GetTable("users")
+	if err != nil {
+		t.Fatalf("GetTable after restart failed: %v", err)
+	}
+	if len(reloaded.Columns) != 2 || reloaded.Columns[1].Name != "name" || reloaded.Columns[1].Type != sql.TypeVarchar {
+		t.Errorf("Unexpected columns after restart: %+v", reloaded.Columns)
+	}
+
+	gotRID, err := reloaded.PrimaryIndex().Index.Search([]byte("1"))
+	if err != nil {
+		t.Fatalf("Index search after restart failed: %v", err)
+	}
+	data, err := reloaded.Heap.GetTuple(gotRID)
+	if err != nil {
+		t.Fatalf("GetTuple after restart failed: %v", err)
+	}
+	if string(data) != "persisted" {
+		t.Errorf("Expected 'persisted', got %q", data)
+	}
+}
+
+func TestCatalogOverflowPages(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "catalog.db")
+
+	cat, bp, dm := openCatalog(t, fileName)
+	// Enough tables that the catalog spills past page 0.
+	count := 120
+	for i := 0; i < count; i++ {
+		cols := []sql.ColumnDef{
+			{Name: "id", Type: sql.TypeInt},
+			{Name: fmt.Sprintf("a_rather_long_column_name_%d", i), Type: sql.TypeVarchar},
+		}
+		if _, err := cat.CreateTable(fmt.Sprintf("table_%03d", i), cols); err != nil {
+			t.Fatalf("CreateTable %d failed: %v", i, err)
+		}
+	}
+	if err := bp.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	dm.Close()
+
+	cat, _, dm = openCatalog(t, fileName)
+	defer dm.Close()
+
+	tables := cat.Tables()
+	if len(tables) != count {
+		t.Fatalf("Expected %d tables, got %d", count, len(tables))
+	}
+	for i, table := range tables {
+		if want := fmt.Sprintf("table_%03d", i); table.Name != want {
+			t.Errorf("Table %d: expected %s, got %s", i, want, table.Name)
+		}
+	}
+}
+
+func TestCatalogRejectsForeignFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "foreign.db")
+	if err := os.WriteFile(fileName, make([]byte, storage.PageSize), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dm, err := storage.NewDiskManager(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dm.Close()
+
+	if _, err := catalog.NewCatalog(storage.NewBufferPool(10, dm), dm); err == nil {
+		t.Fatal("Expected error opening a file without a catalog page")
+	}
+}
+
+func TestCatalogRejectsCorruptChain(t *testing.T) {
+	cases := []struct {
+		name    string
+		corrupt func(data []byte)
+	}{
+		{"payload too long", func(data []byte) {
+			binary.BigEndian.PutUint32(data[16:], storage.PageSize)
+		}},
+		{"chain loops", func(data []byte) {
+			binary.BigEndian.PutUint64(data[8:], 0)
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "corrupt.db")
+			cat, bp, dm := openCatalog(t, fileName)
+			if _, err := cat.CreateTable("users", []sql.ColumnDef{{Name: "id", Type: sql.TypeInt}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := bp.FlushAll(); err != nil {
+				t.Fatal(err)
+			}
+			dm.Close()
+
+			// Damage the header of the catalog page on disk.
+			page := storage.NewPage(catalog.CatalogPageID)
+			dm, err := storage.NewDiskManager(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dm.Close()
+			if err := dm.ReadPage(catalog.CatalogPageID, page); err != nil {
+				t.Fatal(err)
+			}
+			tc.corrupt(page.Data[:])
+			if err := dm.WritePage(page); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = catalog.NewCatalog(storage.NewBufferPool(10, dm), dm)
+			if err == nil || !strings.Contains(err.Error(), "corrupt catalog") {
+				t.Fatalf("Expected a corrupt catalog error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCatalogPersistsFreePages(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "catalog.db")
+
+	cat, bp, dm := openCatalog(t, fileName)
+	page, err := bp.NewPage()
+	if err != nil {
+		t.Fatalf("NewPage failed: %v", err)
+	}
+	freed := page.ID
+	bp.UnpinPage(freed, false)
+	if err := bp.DeletePage(freed); err != nil {
+		t.Fatalf("DeletePage failed: %v", err)
+	}
+	if err := cat.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := bp.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	dm.Close()
+
+	_, bp, dm = openCatalog(t, fileName)
+	defer dm.Close()
+
+	if free := dm.FreePages(); len(free) != 1 || free[0] != freed {
+		t.Fatalf("Expected free pages [%d] after restart, got %v", freed, free)
+	}
+	page, err = bp.NewPage()
+	if err != nil {
+		t.Fatalf("NewPage failed: %v", err)
+	}
+	if page.ID != freed {
+		t.Errorf("Expected freed page %d to be reused, got %d", freed, page.ID)
+	}
+	bp.UnpinPage(page.ID, false)
+}
+
+func TestCatalogIndexes(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "catalog.db")
+
+	cat, bp, dm := openCatalog(t, fileName)
+	if _, err := cat.CreateTable("orders", []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeInt},
+		{Name: "user_id", Type: sql.TypeBigInt},
+	}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	info, err := cat.CreateIndex("orders_user", "orders", []string{"USER_ID", "id"}, true)
+	if err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	if !reflect.DeepEqual(info.Columns, []string{"user_id", "id"}) {
+		t.Errorf("expected the columns' own spelling, got %v", info.Columns)
+	}
+	if err := info.Index.Insert([]byte("42"), storage.RID{PageID: 1, SlotID: 2}); err != nil {
+		t.Fatalf("Index insert failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, table string
+		columns     []string
+	}{
+		{"orders_user", "orders", []string{"id"}},
+		{"orders_pkey", "orders", []string{"id"}},
+		{"other", "missing", []string{"id"}},
+		{"other", "orders", []string{"missing"}},
+		{"other", "orders", []string{"id", "missing"}},
+		{"other", "orders", []string{"id", "ID"}},
+		{"other", "orders", nil},
+	} {
+		if _, err := cat.CreateIndex(tc.name, tc.table, tc.columns, true); err == nil {
+			t.Errorf("CreateIndex(%s, %s, %v): expected error", tc.name, tc.table, tc.columns)
+		}
+	}
+	if err := cat.DropIndex("orders_pkey"); err == nil {
+		t.Errorf("expected dropping the primary key index to fail")
+	}
+	if err := bp.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	dm.Close()
+
+	cat, _, dm = openCatalog(t, fileName)
+	defer dm.Close()
+
+	orders, err := cat.GetTable("orders")
+	if err != nil {
+		t.Fatalf("GetTable after restart failed: %v", err)
+	}
+	if len(orders.Indexes) != 2 || orders.Indexes[1].Name != "orders_user" || !orders.Indexes[1].Unique ||
+		!reflect.DeepEqual(orders.Indexes[1].Columns, []string{"user_id", "id"}) {
+		t.Fatalf("unexpected indexes after restart: %+v", orders.Indexes)
+	}
+	if rid, err := orders.Indexes[1].Index.Search([]byte("42")); err != nil || rid != (storage.RID{PageID: 1, SlotID: 2}) {
+		t.Errorf("expected key 42 to survive restart, got %v, %v", rid, err)
+	}
+
+	if err := cat.DropIndex("orders_user"); err != nil {
+		t.Fatalf("DropIndex failed: %v", err)
+	}
+	if len(orders.Indexes) != 1 {
+		t.Errorf("expected only the primary key index, got %d indexes", len(orders.Indexes))
+	}
+	if err := cat.DropIndex("orders_user"); err == nil {
+		t.Errorf("expected dropping a missing index to fail")
+	}
+	if len(dm.FreePages()) == 0 {
+		t.Errorf("expected the dropped index's pages to be freed")
+	}
+}
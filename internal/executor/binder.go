@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+// Column describes one column of the tuples an executor produces.
+type Column struct {
+	Table string
+	Def   sql.ColumnDef
+}
+
+// Schema describes the columns of the tuples an executor produces,
+// in tuple order.
+type Schema []Column
+
+// TableSchema builds the schema of tuples scanned from a table.
+func TableSchema(table string, columns []sql.ColumnDef) Schema {
+	schema := make(Schema, len(columns))
+	for i, col := range columns {
+		schema[i] = Column{Table: table, Def: col}
+	}
+	return schema
+}
+
+// Concat returns the schema of tuples formed by appending other's
+// columns to s's, as a join produces.
+func (s Schema) Concat(other Schema) Schema {
+	out := make(Schema, 0, len(s)+len(other))
+	out = append(out, s...)
+	return append(out, other...)
+}
+
+// Resolve finds the ordinal of the column ref names. Unqualified names
+// must match exactly one column across all tables.
+func (s Schema) Resolve(ref *sql.ColumnRef) (int, error) {
+	found := -1
+	var matches []string
+	for i, col := range s {
+		if !strings.EqualFold(col.Def.Name, ref.Name) {
+			continue
+		}
+		if ref.Table != "" && !strings.EqualFold(col.Table, ref.Table) {
+			continue
+		}
+		found = i
+		matches = append(matches, col.Table+"."+col.Def.Name)
+	}
+
+	switch len(matches) {
+	case 0:
+		return -1, fmt.Errorf("unknown column %s", ref)
+	case 1:
+		return found, nil
+	}
+	return -1, fmt.Errorf("ambiguous column %s (could be %s)", ref, strings.Join(matches, ", "))
+}
+
+// BoundColumn is a column reference resolved to its ordinal in the tuple.
+type BoundColumn struct {
+	Index int
+	Ref   *sql.ColumnRef
+}
+
+func (e *BoundColumn) String() string {
+	return e.Ref.String()
+}
+
+// BindExpr returns a copy of expr with every column reference replaced
+// by a BoundColumn resolved against schema.
+func BindExpr(expr sql.Expr, schema Schema) (sql.Expr, error) {
+	switch e := expr.(type) {
+	case nil:
+		return nil, nil
+
+	case *sql.Literal, *BoundColumn:
+		return e, nil
+
+	case *sql.ColumnRef:
+		idx, err := schema.Resolve(e)
+		if err != nil {
+			return nil, err
+		}
+		return &BoundColumn{Index: idx, Ref: e}, nil
+
+	case *sql.IsNullExpr:
+		inner, err := BindExpr(e.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &sql.IsNullExpr{Expr: inner, Not: e.Not}, nil
+
+	case *sql.UnaryExpr:
+		operand, err := BindExpr(e.Operand, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &sql.UnaryExpr{Op: e.Op, Operand: operand}, nil
+
+	case *sql.LikeExpr:
+		inner, err := BindExpr(e.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := BindExpr(e.Pattern, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &sql.LikeExpr{Expr: inner, Pattern: pattern, Not: e.Not}, nil
+
+	case *sql.InExpr:
+		inner, err := BindExpr(e.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]sql.Expr, len(e.List))
+		for i, item := range e.List {
+			if list[i], err = BindExpr(item, schema); err != nil {
+				return nil, err
+			}
+		}
+		return &sql.InExpr{Expr: inner, List: list, Not: e.Not}, nil
+
+	case *sql.BetweenExpr:
+		inner, err := BindExpr(e.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		low, err := BindExpr(e.Low, schema)
+		if err != nil {
+			return nil, err
+		}
+		high, err := BindExpr(e.High, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &sql.BetweenExpr{Expr: inner, Low: low, High: high, Not: e.Not}, nil
+
+	case *sql.BinaryExpr:
+		left, err := BindExpr(e.Left, schema)
+		if err != nil {
+			return nil, err
+		}
+		right, err := BindExpr(e.Right, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &sql.BinaryExpr{Op: e.Op, Left: left, Right: right}, nil
+	}
+	return nil, fmt.Errorf("unsupported expression %s", expr)
+}
+
+// BindSelectList resolves a SELECT list against schema, expanding *
+// and table.* into their columns. It returns the expression and output
+// name of each result column.
+func BindSelectList(items []sql.SelectItem, schema Schema) ([]sql.Expr, []string, error) {
+	var exprs []sql.Expr
+	var names []string
+	for _, item := range items {
+		if item.Star {
+			matched := false
+			for i, col := range schema {
+				if item.Table != "" && !strings.EqualFold(col.Table, item.Table) {
+					continue
+				}
+				ref := &sql.ColumnRef{Table: col.Table, Name: col.Def.Name}
+				exprs = append(exprs, &BoundColumn{Index: i, Ref: ref})
+				names = append(names, col.Def.Name)
+				matched = true
+			}
+			if !matched {
+				return nil, nil, fmt.Errorf("unknown table %s", item.Table)
+			}
+			continue
+		}
+
+		expr, err := BindExpr(item.Expr, schema)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := item.Alias
+		if name == "" {
+			if ref, ok := item.Expr.(*sql.ColumnRef); ok {
+				name = ref.Name
+			} else {
+				name = item.Expr.String()
+			}
+		}
+		exprs = append(exprs, expr)
+		names = append(names, name)
+	}
+	return exprs, names, nil
+}
+
+// Assignment sets the column at Index to the value of Expr.
+type Assignment struct {
+	Index int
+	Expr  sql.Expr
+}
+
+// BindAssignments resolves the columns and values of UPDATE ... SET
+// clauses against schema.
+func BindAssignments(sets []sql.SetClause, schema Schema) ([]Assignment, error) {
+	assigned := make(map[int]bool, len(sets))
+	out := make([]Assignment, len(sets))
+	for i, set := range sets {
+		idx, err := schema.Resolve(&sql.ColumnRef{Name: set.Column})
+		if err != nil {
+			return nil, err
+		}
+		if assigned[idx] {
+			return nil, fmt.Errorf("column %s assigned more than once", set.Column)
+		}
+		assigned[idx] = true
+
+		val, err := BindExpr(set.Value, schema)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = Assignment{Index: idx, Expr: val}
+	}
+	return out, nil
+}
+
+// BindJoinKeys resolves the two columns of an equi-join condition to
+// ordinals in the left and right tuples. The columns may be written in
+// either order; a self-join matches a to the left side first.
+func BindJoinKeys(left, right Schema, a, b *sql.ColumnRef) (int, int, error) {
+	leftIdx, err := left.Resolve(a)
+	if err == nil {
+		var rightIdx int
+		if rightIdx, err = right.Resolve(b); err == nil {
+			return leftIdx, rightIdx, nil
+		}
+	}
+
+	leftIdx, swapErr := left.Resolve(b)
+	if swapErr == nil {
+		rightIdx, swapErr := right.Resolve(a)
+		if swapErr == nil {
+			return leftIdx, rightIdx, nil
+		}
+	}
+	return -1, -1, err
+}
@@ -0,0 +1,200 @@
+package executor_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+func joinSchema() executor.Schema {
+	users := executor.TableSchema("users", []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeInt},
+		{Name: "name", Type: sql.TypeVarchar},
+	})
+	orders := executor.TableSchema("orders", []sql.ColumnDef{
+		{Name: "order_id", Type: sql.TypeInt},
+		{Name: "user_id", Type: sql.TypeInt},
+		{Name: "id", Type: sql.TypeInt},
+	})
+	return users.Concat(orders)
+}
+
+func TestSchemaResolve(t *testing.T) {
+	schema := joinSchema()
+
+	cases := []struct {
+		ref  sql.ColumnRef
+		want int
+	}{
+		{sql.ColumnRef{Name: "name"}, 1},
+		{sql.ColumnRef{Name: "user_id"}, 3},
+		{sql.ColumnRef{Table: "users", Name: "id"}, 0},
+		{sql.ColumnRef{Table: "orders", Name: "id"}, 4},
+		{sql.ColumnRef{Table: "ORDERS", Name: "User_Id"}, 3},
+	}
+	for _, c := range cases {
+		got, err := schema.Resolve(&c.ref)
+		if err != nil {
+			t.Fatalf("Resolve(%s) failed: %v", &c.ref, err)
+		}
+		if got != c.want {
+			t.Errorf("Resolve(%s): expected %d, got %d", &c.ref, c.want, got)
+		}
+	}
+
+	errs := []struct {
+		ref  sql.ColumnRef
+		want string
+	}{
+		{sql.ColumnRef{Name: "id"}, "ambiguous column id"},
+		{sql.ColumnRef{Name: "missing"}, "unknown column missing"},
+		{sql.ColumnRef{Table: "users", Name: "user_id"}, "unknown column users.user_id"},
+		{sql.ColumnRef{Table: "items", Name: "id"}, "unknown column items.id"},
+	}
+	for _, c := range errs {
+		_, err := schema.Resolve(&c.ref)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Resolve(%s): expected error %q, got %v", &c.ref, c.want, err)
+		}
+	}
+}
+
+func TestFilterUsesReferencedColumn(t *testing.T) {
+	schema := joinSchema()
+	rows := [][]interface{}{
+		{1, "Ben", 101, 1, 7},
+		{2, "Ann", 102, 1, 2},
+	}
+
+	for cond, want := range map[string]int{
+		"name = 'Ann'":           1,
+		"users.id = orders.id":   1,
+		"orders.user_id = 1":     2,
+		"users.id = user_id + 1": 1,
+	} {
+		bound, err := executor.BindExpr(parseWhere(t, cond), schema)
+		if err != nil {
+			t.Fatalf("BindExpr(%q) failed: %v", cond, err)
+		}
+		got := collect(t, executor.NewFilterExecutor(&rowsExecutor{rows: rows}, bound))
+		if len(got) != want {
+			t.Errorf("WHERE %s: expected %d rows, got %v", cond, want, got)
+		}
+	}
+
+	if _, err := executor.BindExpr(parseWhere(t, "id = 1"), schema); err == nil {
+		t.Errorf("expected ambiguous column error")
+	}
+}
+
+func TestNestedLoopJoinOnNonFirstColumn(t *testing.T) {
+	dm, err := storage.NewDiskManager(filepath.Join(t.TempDir(), "join.db"))
+	if err != nil {
+		t.Fatalf("NewDiskManager failed: %v", err)
+	}
+	defer dm.Close()
+	bp := storage.NewBufferPool(20, dm)
+
+	usersCols := []sql.ColumnDef{{Name: "id", Type: sql.TypeInt}, {Name: "name", Type: sql.TypeVarchar}}
+	ordersCols := []sql.ColumnDef{{Name: "order_id", Type: sql.TypeInt}, {Name: "user_id", Type: sql.TypeInt}, {Name: "amount", Type: sql.TypeInt}}
+
+	orders, err := storage.NewTableHeap(bp, storage.InvalidPageID)
+	if err != nil {
+		t.Fatalf("NewTableHeap failed: %v", err)
+	}
+	for _, row := range [][]interface{}{{101, 1, 500}, {102, 2, 300}, {103, 1, 50}} {
+		data, err := executor.SerializeTuple(ordersCols, row)
+		if err != nil {
+			t.Fatalf("SerializeTuple failed: %v", err)
+		}
+		if _, err := orders.InsertTuple(data); err != nil {
+			t.Fatalf("InsertTuple failed: %v", err)
+		}
+	}
+
+	left := executor.TableSchema("users", usersCols)
+	right := executor.TableSchema("orders", ordersCols)
+
+	// The condition may name either side first.
+	userID := &sql.ColumnRef{Table: "users", Name: "id"}
+	orderUserID := &sql.ColumnRef{Table: "orders", Name: "user_id"}
+	for _, on := range [][2]*sql.ColumnRef{{userID, orderUserID}, {orderUserID, userID}} {
+		a, b := on[0], on[1]
+		leftKey, rightKey, err := executor.BindJoinKeys(left, right, a, b)
+		if err != nil {
+			t.Fatalf("BindJoinKeys(%s, %s) failed: %v", a, b, err)
+		}
+
+		users := &rowsExecutor{rows: [][]interface{}{{1, "Ben"}, {2, "Ann"}, {3, "Eve"}}}
+		got := collect(t, executor.NewNestedLoopJoinExecutor(users, orders, ordersCols, leftKey, rightKey))
+		want := [][]interface{}{
+			{1, "Ben", 101, 1, 500},
+			{1, "Ben", 103, 1, 50},
+			{2, "Ann", 102, 2, 300},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ON %s = %s: expected %v, got %v", a, b, want, got)
+		}
+	}
+
+	if _, _, err := executor.BindJoinKeys(left, right, &sql.ColumnRef{Name: "id"}, &sql.ColumnRef{Name: "missing"}); err == nil {
+		t.Errorf("expected error for unknown join column")
+	}
+}
+
+func TestProjectionExecutor(t *testing.T) {
+	schema := joinSchema()
+	rows := [][]interface{}{
+		{1, "Ben", 101, 1, 7},
+		{2, "Ann", 102, 2, nil},
+	}
+
+	cases := []struct {
+		list    string
+		columns []string
+		want    [][]interface{}
+	}{
+		{"*", []string{"id", "name", "order_id", "user_id", "id"}, rows},
+		{"orders.*", []string{"order_id", "user_id", "id"}, [][]interface{}{{101, 1, 7}, {102, 2, nil}}},
+		{"name, users.id", []string{"name", "id"}, [][]interface{}{{"Ben", 1}, {"Ann", 2}}},
+		{"order_id - 100 AS n, orders.id + 1", []string{"n", "(orders.id + 1)"}, [][]interface{}{{1, 8}, {2, nil}}},
+		{"users.*, name LIKE 'B%' AS b", []string{"id", "name", "b"}, [][]interface{}{{1, "Ben", true}, {2, "Ann", false}}},
+	}
+	for _, c := range cases {
+		p, err := sql.NewParser(sql.NewLexer("SELECT " + c.list + " FROM t"))
+		if err != nil {
+			t.Fatalf("NewParser failed: %v", err)
+		}
+		stmt, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", c.list, err)
+		}
+		exprs, columns, err := executor.BindSelectList(stmt.(*sql.SelectStatement).Fields, schema)
+		if err != nil {
+			t.Fatalf("BindSelectList(%q) failed: %v", c.list, err)
+		}
+		if !reflect.DeepEqual(columns, c.columns) {
+			t.Errorf("SELECT %s: expected columns %v, got %v", c.list, c.columns, columns)
+		}
+		proj := executor.NewProjectionExecutor(&rowsExecutor{rows: rows}, exprs, columns)
+		if got := collect(t, proj); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("SELECT %s: expected %v, got %v", c.list, c.want, got)
+		}
+	}
+
+	for _, list := range []string{"id", "items.*", "missing + 1"} {
+		p, _ := sql.NewParser(sql.NewLexer("SELECT " + list + " FROM t"))
+		stmt, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", list, err)
+		}
+		if _, _, err := executor.BindSelectList(stmt.(*sql.SelectStatement).Fields, schema); err == nil {
+			t.Errorf("SELECT %s: expected bind error", list)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+// EvalExpr evaluates an expression against a tuple. Column references
+// must have been resolved with BindExpr first. NULL is represented by
+// nil, and boolean operators follow SQL three-valued logic.
+func EvalExpr(expr sql.Expr, tuple *Tuple) (interface{}, error) {
+	switch e := expr.(type) {
+	case *sql.Literal:
+		return e.Value, nil
+
+	case *BoundColumn:
+		return tuple.Values[e.Index], nil
+
+	case *sql.ColumnRef:
+		return nil, fmt.Errorf("column %s is not bound", e)
+
+	case *sql.IsNullExpr:
+		val, err := EvalExpr(e.Expr, tuple)
+		if err != nil {
+			return nil, err
+		}
+		return (val == nil) != e.Not, nil
+
+	case *sql.UnaryExpr:
+		val, err := EvalExpr(e.Operand, tuple)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		switch e.Op {
+		case "NOT":
+			b, ok := val.(bool)
+			if !ok {
+				return nil, fmt.Errorf("NOT expects a boolean, got %s", sql.FormatValue(val))
+			}
+			return !b, nil
+		case "-":
+			return arithmetic("-", 0, val)
+		}
+		return nil, fmt.Errorf("unknown unary operator %s", e.Op)
+
+	case *sql.BinaryExpr:
+		if e.Op == "AND" || e.Op == "OR" {
+			return evalLogical(e, tuple)
+		}
+
+		left, err := EvalExpr(e.Left, tuple)
+		if err != nil {
+			return nil, err
+		}
+		right, err := EvalExpr(e.Right, tuple)
+		if err != nil {
+			return nil, err
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+
+		switch e.Op {
+		case "=", "<>", "<", "<=", ">", ">=":
+			cmp, err := CompareValues(left, right)
+			if err != nil {
+				return nil, err
+			}
+			return compareResult(e.Op, cmp), nil
+		case "+", "-", "*", "/", "%":
+			return arithmetic(e.Op, left, right)
+		}
+		return nil, fmt.Errorf("unknown operator %s", e.Op)
+
+	case *sql.LikeExpr:
+		return evalLike(e, tuple)
+
+	case *sql.InExpr:
+		return evalIn(e, tuple)
+
+	case *sql.BetweenExpr:
+		return evalBetween(e, tuple)
+	}
+	return nil, fmt.Errorf("unsupported expression %s", expr)
+}
+
+// compareResult applies a comparison operator to the result of
+// CompareValues.
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// negate applies NOT to a three-valued result.
+func negate(val interface{}, not bool) interface{} {
+	if b, ok := val.(bool); ok && not {
+		return !b
+	}
+	return val
+}
+
+func evalLike(e *sql.LikeExpr, tuple *Tuple) (interface{}, error) {
+	val, err := EvalExpr(e.Expr, tuple)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := EvalExpr(e.Pattern, tuple)
+	if err != nil || val == nil || pattern == nil {
+		return nil, err
+	}
+	s, ok := val.(string)
+	p, pok := pattern.(string)
+	if !ok || !pok {
+		return nil, fmt.Errorf("LIKE expects strings, got %s and %s", sql.FormatValue(val), sql.FormatValue(pattern))
+	}
+	return negate(likeMatch([]rune(s), []rune(p)), e.Not), nil
+}
+
+// likeMatch reports whether s matches a LIKE pattern. It backtracks to
+// the most recent % only, which keeps matching linear in practice.
+func likeMatch(s, p []rune) bool {
+	si, pi := 0, 0
+	star, mark := -1, 0
+	for si < len(s) {
+		switch {
+		case pi < len(p) && (p[pi] == '_' || p[pi] == s[si]):
+			si++
+			pi++
+		case pi < len(p) && p[pi] == '%':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			mark++
+			si, pi = mark, star+1
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '%' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// evalIn is true if any list item equals the value. Otherwise it is
+// unknown if the value or any item is NULL, and false if not.
+func evalIn(e *sql.InExpr, tuple *Tuple) (interface{}, error) {
+	val, err := EvalExpr(e.Expr, tuple)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	sawNull := false
+	for _, item := range e.List {
+		x, err := EvalExpr(item, tuple)
+		if err != nil {
+			return nil, err
+		}
+		if x == nil {
+			sawNull = true
+			continue
+		}
+		cmp, err := CompareValues(val, x)
+		if err != nil {
+			return nil, err
+		}
+		if cmp == 0 {
+			return !e.Not, nil
+		}
+	}
+	if sawNull {
+		return nil, nil
+	}
+	return e.Not, nil
+}
+
+// evalBetween computes low <= expr AND expr <= high, so a NULL bound
+// still yields false when the other bound already excludes the value.
+func evalBetween(e *sql.BetweenExpr, tuple *Tuple) (interface{}, error) {
+	val, err := EvalExpr(e.Expr, tuple)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	bound := func(x sql.Expr, op string) (interface{}, error) {
+		b, err := EvalExpr(x, tuple)
+		if err != nil || b == nil {
+			return nil, err
+		}
+		cmp, err := CompareValues(val, b)
+		if err != nil {
+			return nil, err
+		}
+		return compareResult(op, cmp), nil
+	}
+
+	low, err := bound(e.Low, ">=")
+	if err != nil {
+		return nil, err
+	}
+	high, err := bound(e.High, "<=")
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	switch {
+	case low == false || high == false:
+		result = false
+	case low == nil || high == nil:
+		result = nil
+	default:
+		result = true
+	}
+	return negate(result, e.Not), nil
+}
+
+// EvalPredicate evaluates a condition, reporting whether it is true.
+// Unknown (NULL) results do not match.
+func EvalPredicate(expr sql.Expr, tuple *Tuple) (bool, error) {
+	val, err := EvalExpr(expr, tuple)
+	if err != nil || val == nil {
+		return false, err
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition %s is not boolean", expr)
+	}
+	return b, nil
+}
+
+// evalLogical evaluates AND / OR with three-valued logic. A false
+// operand decides AND and a true operand decides OR even when the
+// other operand is NULL.
+func evalLogical(e *sql.BinaryExpr, tuple *Tuple) (interface{}, error) {
+	operand := func(x sql.Expr) (interface{}, error) {
+		val, err := EvalExpr(x, tuple)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		if _, ok := val.(bool); !ok {
+			return nil, fmt.Errorf("%s expects booleans, got %s", e.Op, sql.FormatValue(val))
+		}
+		return val, nil
+	}
+
+	decisive := e.Op == "OR"
+
+	left, err := operand(e.Left)
+	if err != nil {
+		return nil, err
+	}
+	if left == decisive {
+		return decisive, nil
+	}
+	right, err := operand(e.Right)
+	if err != nil {
+		return nil, err
+	}
+	if right == decisive {
+		return decisive, nil
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return !decisive, nil
+}
+
+// arithmetic applies +, -, *, / or % to two non-NULL numbers.
+// INT op INT stays INT, BIGINT widens, DECIMAL is exact and any
+// floating point operand makes the result DOUBLE. DATE +/- INT moves
+// the date by whole days.
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	if d, ok := a.(sql.Date); ok && (op == "+" || op == "-") {
+		if n, ok := b.(int); ok {
+			if op == "-" {
+				n = -n
+			}
+			return d + sql.Date(n), nil
+		}
+	}
+
+	if !isNumeric(a) || !isNumeric(b) {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", op, sql.FormatValue(a), sql.FormatValue(b))
+	}
+
+	_, af := a.(float64)
+	_, bf := b.(float64)
+	if af || bf {
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return x / y, nil
+		}
+		return nil, fmt.Errorf("operator %s is not supported for floating point", op)
+	}
+
+	_, ad := a.(sql.Decimal)
+	_, bd := b.(sql.Decimal)
+	if ad || bd {
+		return decimalArithmetic(op, a, b)
+	}
+
+	// Overflow is an error rather than a wrapped result; INT results are
+	// range-checked again when they are stored.
+	x, y := toInt64(a), toInt64(b)
+	var r int64
+	overflow := false
+	switch op {
+	case "+":
+		r = x + y
+		overflow = (x^r)&(y^r) < 0
+	case "-":
+		r = x - y
+		overflow = (x^y)&(x^r) < 0
+	case "*":
+		r = x * y
+		overflow = x != 0 && (r/x != y || (x == -1 && y == math.MinInt64))
+	case "/", "%":
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if op == "/" {
+			r = x / y
+			overflow = x == math.MinInt64 && y == -1
+		} else {
+			r = x % y
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator %s", op)
+	}
+	if overflow {
+		return nil, fmt.Errorf("integer result of %d %s %d out of range", x, op, y)
+	}
+
+	_, a64 := a.(int64)
+	_, b64 := b.(int64)
+	if a64 || b64 {
+		return r, nil
+	}
+	return int(r), nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return 0
+}
+
+// decimalArithmetic computes exactly and rounds the result to the
+// larger operand scale (the sum of scales for *, at least 6 for /).
+func decimalArithmetic(op string, a, b interface{}) (interface{}, error) {
+	scale := func(v interface{}) int {
+		if d, ok := v.(sql.Decimal); ok {
+			return d.Scale
+		}
+		return 0
+	}
+	resultScale := max(scale(a), scale(b))
+
+	x, y := toRat(a), toRat(b)
+	r := new(big.Rat)
+	switch op {
+	case "+":
+		r.Add(x, y)
+	case "-":
+		r.Sub(x, y)
+	case "*":
+		r.Mul(x, y)
+		resultScale = min(scale(a)+scale(b), sql.MaxDecimalPrecision)
+	case "/":
+		if y.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		r.Quo(x, y)
+		resultScale = max(resultScale, 6)
+	default:
+		return nil, fmt.Errorf("operator %s is not supported for DECIMAL", op)
+	}
+
+	d, err := sql.ParseDecimal(r.FloatString(resultScale))
+	if err != nil {
+		return nil, fmt.Errorf("DECIMAL result out of range")
+	}
+	return d, nil
+}
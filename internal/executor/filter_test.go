@@ -0,0 +1,232 @@
+package executor_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+// rowsExecutor yields a fixed list of rows.
+type rowsExecutor struct {
+	rows [][]interface{}
+	pos  int
+}
+
+func (e *rowsExecutor) Init() error  { e.pos = 0; return nil }
+func (e *rowsExecutor) Close() error { return nil }
+
+func (e *rowsExecutor) Next() (*executor.Tuple, error) {
+	if e.pos >= len(e.rows) {
+		return nil, nil
+	}
+	e.pos++
+	return &executor.Tuple{Values: e.rows[e.pos-1]}, nil
+}
+
+func collect(t *testing.T, exec executor.Executor) [][]interface{} {
+	t.Helper()
+	if err := exec.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer exec.Close()
+
+	var out [][]interface{}
+	for {
+		tuple, err := exec.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if tuple == nil {
+			return out
+		}
+		out = append(out, tuple.Values)
+	}
+}
+
+// parseWhere parses the WHERE clause of "SELECT * FROM t WHERE <cond>".
+func parseWhere(t *testing.T, cond string) sql.Expr {
+	t.Helper()
+	p, err := sql.NewParser(sql.NewLexer("SELECT * FROM t WHERE " + cond))
+	if err != nil {
+		t.Fatalf("NewParser failed: %v", err)
+	}
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", cond, err)
+	}
+	return stmt.(*sql.SelectStatement).Where
+}
+
+// bindWhere parses cond and binds it to the columns of table t.
+func bindWhere(t *testing.T, cond string, columns []sql.ColumnDef) sql.Expr {
+	t.Helper()
+	bound, err := executor.BindExpr(parseWhere(t, cond), executor.TableSchema("t", columns))
+	if err != nil {
+		t.Fatalf("BindExpr(%q) failed: %v", cond, err)
+	}
+	return bound
+}
+
+func TestFilterThreeValuedLogic(t *testing.T) {
+	schema := []sql.ColumnDef{{Name: "a", Type: sql.TypeInt}}
+	rows := [][]interface{}{{1}, {nil}, {3}}
+
+	cases := []struct {
+		cond string
+		want int
+	}{
+		{"a = 1", 1},
+		{"a > 0", 2},
+		{"a = NULL", 0},
+		{"a IS NULL", 1},
+		{"a IS NOT NULL", 2},
+		{"NOT a = 1", 1},
+		{"a = 1 OR a IS NULL", 2},
+		{"a > 0 AND NULL", 0},
+		{"a = 1 OR NULL", 1},
+		{"NOT (a > 1 AND NULL)", 1},
+	}
+	for _, c := range cases {
+		got := collect(t, executor.NewFilterExecutor(&rowsExecutor{rows: rows}, bindWhere(t, c.cond, schema)))
+		if len(got) != c.want {
+			t.Errorf("WHERE %s: expected %d rows, got %v", c.cond, c.want, got)
+		}
+	}
+}
+
+func TestFilterBooleanExpressions(t *testing.T) {
+	schema := []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeInt},
+		{Name: "age", Type: sql.TypeInt},
+		{Name: "city", Type: sql.TypeVarchar},
+		{Name: "vip", Type: sql.TypeBoolean},
+	}
+	rows := [][]interface{}{
+		{1, 30, "Nairobi", false},
+		{2, 17, "Nairobi", true},
+		{3, 40, "Mombasa", true},
+		{4, 25, "Mombasa", false},
+		{5, 50, "Kisumu", nil},
+	}
+
+	cases := []struct {
+		cond string
+		want []int
+	}{
+		{"age > 18 AND (city = 'Nairobi' OR vip = TRUE)", []int{1, 3}},
+		{"age > 18 AND city = 'Nairobi' OR vip = TRUE", []int{1, 2, 3}},
+		{"NOT vip", []int{1, 4}},
+		{"age + 5 > 30 AND NOT city = 'Kisumu'", []int{1, 3}},
+		{"age * 2 - 10 = id * 10", []int{4}},
+		{"age % 10 = 0", []int{1, 3, 5}},
+		{"-age < -35", []int{3, 5}},
+	}
+	for _, c := range cases {
+		got := collect(t, executor.NewFilterExecutor(&rowsExecutor{rows: rows}, bindWhere(t, c.cond, schema)))
+		ids := []int{}
+		for _, row := range got {
+			ids = append(ids, row[0].(int))
+		}
+		if !reflect.DeepEqual(ids, c.want) {
+			t.Errorf("WHERE %s: expected ids %v, got %v", c.cond, c.want, ids)
+		}
+	}
+}
+
+func TestFilterComparisonOperators(t *testing.T) {
+	schema := []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeInt},
+		{Name: "name", Type: sql.TypeVarchar},
+		{Name: "price", Type: sql.TypeDecimal, Precision: 10, Scale: 2},
+		{Name: "born", Type: sql.TypeDate},
+	}
+	rows := [][]interface{}{
+		{1, "Ben", sql.Decimal{Unscaled: 1999, Scale: 2}, sql.Date(0)},
+		{2, "Bea", sql.Decimal{Unscaled: 500, Scale: 2}, sql.Date(10000)},
+		{3, "ann_b", nil, sql.Date(20000)},
+		{4, nil, sql.Decimal{Unscaled: 100000, Scale: 2}, nil},
+	}
+
+	cases := []struct {
+		cond string
+		want []int
+	}{
+		{"id <= 2", []int{1, 2}},
+		{"id >= 3", []int{3, 4}},
+		{"id <> 2", []int{1, 3, 4}},
+		{"id != 2", []int{1, 3, 4}},
+		{"name <> 'Ben'", []int{2, 3}},
+		{"name >= 'Be'", []int{1, 2, 3}},
+		{"price <= 19.99", []int{1, 2}},
+		{"price > 5", []int{1, 4}},
+		{"born > DATE '1997-05-19'", []int{3}},
+		{"born <= '1997-05-19'", []int{1, 2}},
+		{"name LIKE 'B%'", []int{1, 2}},
+		{"name LIKE 'B_n'", []int{1}},
+		{"name LIKE '%_b'", []int{3}},
+		{"name LIKE '%'", []int{1, 2, 3}},
+		{"name NOT LIKE 'B%'", []int{3}},
+		{"id IN (1, 3, 5)", []int{1, 3}},
+		{"id NOT IN (1, 3)", []int{2, 4}},
+		{"id IN (1, NULL)", []int{1}},
+		{"id NOT IN (1, NULL)", []int{}},
+		{"price IN (5, 1000)", []int{2, 4}},
+		{"id BETWEEN 2 AND 3", []int{2, 3}},
+		{"id NOT BETWEEN 2 AND 3", []int{1, 4}},
+		{"id BETWEEN 3 AND 2", []int{}},
+		{"id BETWEEN 2 AND NULL", []int{}},
+		{"id NOT BETWEEN 2 AND NULL", []int{1}},
+		{"price BETWEEN 5 AND 20 AND name LIKE 'B%'", []int{1, 2}},
+		{"born BETWEEN '1970-01-01' AND DATE '2000-01-01'", []int{1, 2}},
+	}
+	for _, c := range cases {
+		got := collect(t, executor.NewFilterExecutor(&rowsExecutor{rows: rows}, bindWhere(t, c.cond, schema)))
+		ids := []int{}
+		for _, row := range got {
+			ids = append(ids, row[0].(int))
+		}
+		if !reflect.DeepEqual(ids, c.want) {
+			t.Errorf("WHERE %s: expected ids %v, got %v", c.cond, c.want, ids)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	schema := []sql.ColumnDef{{Name: "a", Type: sql.TypeInt}}
+	rows := [][]interface{}{{1}}
+
+	for _, cond := range []string{"a = 'x'", "a + 1", "a / 0 = 1", "a LIKE '1'", "a IN ('x')", "a BETWEEN 'x' AND 2"} {
+		exec := executor.NewFilterExecutor(&rowsExecutor{rows: rows}, bindWhere(t, cond, schema))
+		if _, err := exec.Next(); err == nil {
+			t.Errorf("WHERE %s: expected error", cond)
+		}
+	}
+}
+
+func TestFilterIntegerOverflow(t *testing.T) {
+	schema := []sql.ColumnDef{
+		{Name: "hi", Type: sql.TypeBigInt},
+		{Name: "lo", Type: sql.TypeBigInt},
+	}
+	rows := [][]interface{}{{int64(math.MaxInt64), int64(math.MinInt64)}}
+
+	for _, cond := range []string{"hi + 1 > 0", "hi * 2 > 0", "2 * hi > 0", "lo - 1 < 0", "-lo > 0", "lo * -1 > 0", "-1 * lo > 0", "lo / -1 > 0", "hi - lo > 0"} {
+		exec := executor.NewFilterExecutor(&rowsExecutor{rows: rows}, bindWhere(t, cond, schema))
+		_, err := exec.Next()
+		if err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("WHERE %s: expected an out of range error, got %v", cond, err)
+		}
+	}
+
+	// Results at the edge of the range are fine.
+	for _, cond := range []string{"hi - 1 + 1 = hi", "lo + 1 - 1 = lo", "lo / 1 = lo", "lo % -1 = 0", "hi * -1 - 1 = lo"} {
+		exec := executor.NewFilterExecutor(&rowsExecutor{rows: rows}, bindWhere(t, cond, schema))
+		if got := collect(t, exec); len(got) != 1 {
+			t.Errorf("WHERE %s: expected the row to match, got %v", cond, got)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// IndexScanExecutor reads the rows of a table whose key in an index
+// lies in a range, in index order, fetching each row from the heap by
+// the RID stored in the index.
+type IndexScanExecutor struct {
+	heap   *storage.TableHeap
+	schema []sql.ColumnDef
+	iter   *index.RangeIterator
+}
+
+// NewIndexScanExecutor creates an index scan over the keys of idx
+// between lo and hi. Each bound holds values, in their columns' runtime
+// types, for a prefix of the index's columns, and matches every key
+// that starts with them; a bound with no values leaves that end open.
+// A point lookup passes the same values as both bounds with
+// index.Inclusive.
+func NewIndexScanExecutor(heap *storage.TableHeap, schema []sql.ColumnDef, idx TableIndex, lo, hi []interface{}, incl index.Inclusivity) (*IndexScanExecutor, error) {
+	loKey, err := index.EncodeKey(lo...)
+	if err != nil {
+		return nil, err
+	}
+	hiKey, err := index.EncodeKey(hi...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every key extending a bound, and every RID suffix of a non-unique
+	// entry, sorts between the bound and the bound followed by 0xFF:
+	// values start with a marker byte below 0xFF and RIDs with the high
+	// byte of a page ID.
+	rangeIncl := index.Exclusive
+	if len(lo) > 0 {
+		if incl&index.IncludeLo != 0 {
+			rangeIncl |= index.IncludeLo
+		} else {
+			loKey = append(loKey, 0xFF)
+		}
+	}
+	if len(hi) > 0 && incl&index.IncludeHi != 0 {
+		hiKey = append(hiKey, 0xFF)
+	}
+	return &IndexScanExecutor{
+		heap:   heap,
+		schema: schema,
+		iter:   idx.Index.Range(loKey, hiKey, rangeIncl),
+	}, nil
+}
+
+func (e *IndexScanExecutor) Init() error  { return nil }
+func (e *IndexScanExecutor) Close() error { return nil }
+
+func (e *IndexScanExecutor) Next() (*Tuple, error) {
+	tuple, _, err := e.nextRow()
+	return tuple, err
+}
+
+func (e *IndexScanExecutor) nextRow() (*Tuple, storage.RID, error) {
+	_, rid, ok, err := e.iter.Next()
+	if err != nil || !ok {
+		return nil, storage.RID{}, err
+	}
+	data, err := e.heap.GetTuple(rid)
+	if err != nil {
+		return nil, storage.RID{}, err
+	}
+	tuple, err := DeserializeTuple(e.schema, data)
+	return tuple, rid, err
+}
@@ -1,222 +1,387 @@
-package executor
-
-import (
-	"encoding/binary"
-	"fmt"
-
-	"github.com/benkivuva/my-rdbms/internal/index"
-	"github.com/benkivuva/my-rdbms/internal/sql"
-	"github.com/benkivuva/my-rdbms/internal/storage"
-)
-
-// SeqScanExecutor performs a sequential scan over all tuples in a heap.
-type SeqScanExecutor struct {
-	iterator *storage.TableIterator
-}
-
-// NewSeqScanExecutor creates a new sequential scan executor.
-func NewSeqScanExecutor(heap *storage.TableHeap) *SeqScanExecutor {
-	return &SeqScanExecutor{iterator: heap.Iterator()}
-}
-
-func (e *SeqScanExecutor) Init() error  { return nil }
-func (e *SeqScanExecutor) Close() error { return nil }
-
-func (e *SeqScanExecutor) Next() (*Tuple, error) {
-	data, _, err := e.iterator.Next()
-	if err != nil {
-		return nil, err
-	}
-	if data == nil {
-		return nil, nil
-	}
-
-	if len(data) < 4 {
-		return &Tuple{Values: []interface{}{string(data)}}, nil
-	}
-
-	id := binary.BigEndian.Uint32(data[:4])
-	name := string(data[4:])
-	return &Tuple{Values: []interface{}{int(id), name}}, nil
-}
-
-// InsertExecutor inserts a tuple into the heap and index.
-type InsertExecutor struct {
-	btree     *index.BTreeIndex
-	tableHeap *storage.TableHeap
-	values    []interface{}
-}
-
-// NewInsertExecutor creates a new insert executor.
-func NewInsertExecutor(btree *index.BTreeIndex, heap *storage.TableHeap, values []interface{}) *InsertExecutor {
-	return &InsertExecutor{btree: btree, tableHeap: heap, values: values}
-}
-
-func (e *InsertExecutor) Init() error  { return nil }
-func (e *InsertExecutor) Close() error { return nil }
-
-func (e *InsertExecutor) Next() (*Tuple, error) {
-	if len(e.values) == 0 {
-		return nil, nil
-	}
-
-	var data []byte
-	var keyVal int64
-
-	val := e.values[0]
-	switch v := val.(type) {
-	case int:
-		keyVal = int64(v)
-		data = make([]byte, 4)
-		binary.BigEndian.PutUint32(data, uint32(keyVal))
-	case string:
-		return nil, fmt.Errorf("primary key must be int")
-	default:
-		return nil, fmt.Errorf("unsupported type for primary key")
-	}
-
-	// Append name if present
-	if len(e.values) > 1 {
-		if name, ok := e.values[1].(string); ok {
-			data = append(data, []byte(name)...)
-		}
-	}
-
-	// Check for unique constraint violation
-	if _, err := e.btree.Search(keyVal); err == nil {
-		return nil, fmt.Errorf("unique constraint violation: key %d already exists", keyVal)
-	}
-
-	rid, err := e.tableHeap.InsertTuple(data)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := e.btree.Insert(keyVal, rid); err != nil {
-		return nil, err
-	}
-
-	return &Tuple{Values: e.values}, nil
-}
-
-// FilterExecutor filters tuples based on a WHERE clause.
-type FilterExecutor struct {
-	child Executor
-	cond  *sql.WhereClause
-}
-
-// NewFilterExecutor creates a new filter executor.
-func NewFilterExecutor(child Executor, cond *sql.WhereClause) *FilterExecutor {
-	return &FilterExecutor{child: child, cond: cond}
-}
-
-func (e *FilterExecutor) Init() error  { return e.child.Init() }
-func (e *FilterExecutor) Close() error { return e.child.Close() }
-
-func (e *FilterExecutor) Next() (*Tuple, error) {
-	for {
-		tuple, err := e.child.Next()
-		if err != nil {
-			return nil, err
-		}
-		if tuple == nil {
-			return nil, nil
-		}
-
-		if e.cond == nil {
-			return tuple, nil
-		}
-
-		val := tuple.Values[0]
-		match := false
-
-		switch e.cond.Op {
-		case "=":
-			match = val == e.cond.Value
-		case ">":
-			if v, ok := val.(int); ok {
-				if cv, ok2 := e.cond.Value.(int); ok2 {
-					match = v > cv
-				}
-			}
-		case "<":
-			if v, ok := val.(int); ok {
-				if cv, ok2 := e.cond.Value.(int); ok2 {
-					match = v < cv
-				}
-			}
-		}
-
-		if match {
-			return tuple, nil
-		}
-	}
-}
-
-// DeleteExecutor deletes tuples matching WHERE clause from heap and index.
-type DeleteExecutor struct {
-	tableHeap *storage.TableHeap
-	btree     *index.BTreeIndex
-	iterator  *storage.TableIterator
-	cond      *sql.WhereClause
-	count     int
-	done      bool
-}
-
-// NewDeleteExecutor creates a new delete executor.
-func NewDeleteExecutor(heap *storage.TableHeap, btree *index.BTreeIndex, cond *sql.WhereClause) *DeleteExecutor {
-	return &DeleteExecutor{
-		tableHeap: heap,
-		btree:     btree,
-		iterator:  heap.Iterator(),
-		cond:      cond,
-	}
-}
-
-func (e *DeleteExecutor) Init() error  { return nil }
-func (e *DeleteExecutor) Close() error { return nil }
-
-func (e *DeleteExecutor) Next() (*Tuple, error) {
-	if e.done {
-		return nil, nil
-	}
-
-	for {
-		data, rid, err := e.iterator.Next()
-		if err != nil {
-			return nil, err
-		}
-		if data == nil {
-			e.done = true
-			return &Tuple{Values: []interface{}{e.count}}, nil
-		}
-
-		// Check if tuple matches WHERE clause
-		match := true
-		if e.cond != nil {
-			var val interface{}
-			if len(data) >= 4 {
-				val = int(binary.BigEndian.Uint32(data[:4]))
-			} else {
-				val = string(data)
-			}
-			match = (e.cond.Op == "=" && fmt.Sprintf("%v", val) == fmt.Sprintf("%v", e.cond.Value))
-		}
-
-		if match {
-			// Delete from heap
-			if err := e.tableHeap.DeleteTuple(rid); err != nil {
-				return nil, err
-			}
-			// Delete from index (parse key from data)
-			var keyVal int64
-			if len(data) >= 4 {
-				keyVal = int64(binary.BigEndian.Uint32(data[:4]))
-			} else {
-				fmt.Sscanf(string(data), "%d", &keyVal)
-			}
-			// Note: B-Tree delete not implemented, but tuple is removed from heap
-			e.count++
-		}
-	}
-}
+package executor
+
+import (
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// SeqScanExecutor performs a sequential scan over all tuples in a heap.
+type SeqScanExecutor struct {
+	iterator *storage.TableIterator
+	schema   []sql.ColumnDef
+}
+
+// NewSeqScanExecutor creates a new sequential scan executor.
+func NewSeqScanExecutor(heap *storage.TableHeap, schema []sql.ColumnDef) *SeqScanExecutor {
+	return &SeqScanExecutor{iterator: heap.ScanIterator(), schema: schema}
+}
+
+func (e *SeqScanExecutor) Init() error  { return nil }
+func (e *SeqScanExecutor) Close() error { return nil }
+
+func (e *SeqScanExecutor) Next() (*Tuple, error) {
+	tuple, _, err := e.nextRow()
+	return tuple, err
+}
+
+func (e *SeqScanExecutor) nextRow() (*Tuple, storage.RID, error) {
+	data, rid, err := e.iterator.Next()
+	if err != nil || data == nil {
+		return nil, storage.RID{}, err
+	}
+	tuple, err := DeserializeTuple(e.schema, data)
+	return tuple, rid, err
+}
+
+// InsertExecutor inserts a tuple into the heap and its indexes.
+type InsertExecutor struct {
+	indexes   []TableIndex
+	tableHeap *storage.TableHeap
+	schema    []sql.ColumnDef
+	values    []interface{}
+}
+
+// NewInsertExecutor creates a new insert executor.
+func NewInsertExecutor(indexes []TableIndex, heap *storage.TableHeap, schema []sql.ColumnDef, values []interface{}) *InsertExecutor {
+	return &InsertExecutor{indexes: indexes, tableHeap: heap, schema: schema, values: values}
+}
+
+func (e *InsertExecutor) Init() error  { return nil }
+func (e *InsertExecutor) Close() error { return nil }
+
+func (e *InsertExecutor) Next() (*Tuple, error) {
+	if len(e.values) == 0 {
+		return nil, nil
+	}
+
+	values, err := CoerceTuple(e.schema, e.values)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := rowKeys(e.indexes, values)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := SerializeTuple(e.schema, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for unique constraint violation
+	if err := checkUnique(e.indexes, keys); err != nil {
+		return nil, err
+	}
+
+	rid, err := e.tableHeap.InsertTuple(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, idx := range e.indexes {
+		if err := idx.insert(keys[i], rid); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Tuple{Values: values}, nil
+}
+
+// FilterExecutor filters tuples based on a WHERE clause.
+type FilterExecutor struct {
+	child Executor
+	cond  sql.Expr
+}
+
+// NewFilterExecutor creates a new filter executor. cond must be bound
+// to the child's schema with BindExpr.
+func NewFilterExecutor(child Executor, cond sql.Expr) *FilterExecutor {
+	return &FilterExecutor{child: child, cond: cond}
+}
+
+func (e *FilterExecutor) Init() error  { return e.child.Init() }
+func (e *FilterExecutor) Close() error { return e.child.Close() }
+
+func (e *FilterExecutor) Next() (*Tuple, error) {
+	for {
+		tuple, err := e.child.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			return nil, nil
+		}
+
+		if e.cond == nil {
+			return tuple, nil
+		}
+
+		match, err := EvalPredicate(e.cond, tuple)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			return tuple, nil
+		}
+	}
+}
+
+// DeleteExecutor deletes tuples matching WHERE clause from heap and indexes.
+type DeleteExecutor struct {
+	tableHeap *storage.TableHeap
+	indexes   []TableIndex
+	schema    []sql.ColumnDef
+	cond      sql.Expr
+	done      bool
+}
+
+// NewDeleteExecutor creates a new delete executor. cond must be bound
+// to the table's schema with BindExpr.
+func NewDeleteExecutor(heap *storage.TableHeap, indexes []TableIndex, schema []sql.ColumnDef, cond sql.Expr) *DeleteExecutor {
+	return &DeleteExecutor{
+		tableHeap: heap,
+		indexes:   indexes,
+		schema:    schema,
+		cond:      cond,
+	}
+}
+
+func (e *DeleteExecutor) Init() error  { return nil }
+func (e *DeleteExecutor) Close() error { return nil }
+
+// deletedRow is a row to delete, found before any change is made.
+type deletedRow struct {
+	rid  storage.RID
+	keys []indexKey
+}
+
+// Next deletes the matching rows and returns a single tuple holding
+// their number. The rows are found first, through an index when cond
+// allows, since an index must not change while it is being scanned.
+func (e *DeleteExecutor) Next() (*Tuple, error) {
+	if e.done {
+		return nil, nil
+	}
+	e.done = true
+
+	scan, err := planScan(e.tableHeap, e.schema, e.indexes, e.cond)
+	if err != nil {
+		return nil, err
+	}
+	var rows []deletedRow
+	for {
+		tuple, rid, err := scan.nextRow()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			break
+		}
+
+		// Check if tuple matches WHERE clause
+		if e.cond != nil {
+			match, err := EvalPredicate(e.cond, tuple)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+		keys, err := rowKeys(e.indexes, tuple.Values)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, deletedRow{rid: rid, keys: keys})
+	}
+
+	for _, row := range rows {
+		if err := e.tableHeap.DeleteTuple(row.rid); err != nil {
+			return nil, err
+		}
+		for i, idx := range e.indexes {
+			if err := idx.delete(row.keys[i], row.rid); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &Tuple{Values: []interface{}{len(rows)}}, nil
+}
+
+// UpdateExecutor rewrites tuples matching a WHERE clause and keeps the
+// table's indexes in step.
+type UpdateExecutor struct {
+	tableHeap   *storage.TableHeap
+	indexes     []TableIndex
+	schema      []sql.ColumnDef
+	assignments []Assignment
+	cond        sql.Expr
+	done        bool
+}
+
+// NewUpdateExecutor creates a new update executor. assignments and cond
+// must be bound to the table's schema.
+func NewUpdateExecutor(heap *storage.TableHeap, indexes []TableIndex, schema []sql.ColumnDef, assignments []Assignment, cond sql.Expr) *UpdateExecutor {
+	return &UpdateExecutor{
+		tableHeap:   heap,
+		indexes:     indexes,
+		schema:      schema,
+		assignments: assignments,
+		cond:        cond,
+	}
+}
+
+func (e *UpdateExecutor) Init() error  { return nil }
+func (e *UpdateExecutor) Close() error { return nil }
+
+// pendingUpdate is a row to rewrite, computed before any change is made.
+// oldKeys and newKeys hold the row's key in each index.
+type pendingUpdate struct {
+	rid     storage.RID
+	oldKeys []indexKey
+	newKeys []indexKey
+	data    []byte
+}
+
+// Next applies the update and returns a single tuple holding the number
+// of rows changed. Every new row is computed and checked first, so a
+// constraint violation leaves the table untouched.
+func (e *UpdateExecutor) Next() (*Tuple, error) {
+	if e.done {
+		return nil, nil
+	}
+	e.done = true
+
+	updates, err := e.plan()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkKeys(updates); err != nil {
+		return nil, err
+	}
+
+	// Remove every changed key before inserting any, so rows may swap keys.
+	for _, u := range updates {
+		for i, idx := range e.indexes {
+			if old := u.oldKeys[i]; !old.equal(u.newKeys[i]) {
+				if err := idx.delete(old, u.rid); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for _, u := range updates {
+		rid, err := e.tableHeap.UpdateTuple(u.rid, u.data)
+		if err != nil {
+			return nil, err
+		}
+		for i, idx := range e.indexes {
+			old, key := u.oldKeys[i], u.newKeys[i]
+			switch {
+			case !key.equal(old):
+				err = idx.insert(key, rid)
+			case rid != u.rid:
+				if err = idx.delete(old, u.rid); err == nil {
+					err = idx.insert(key, rid)
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &Tuple{Values: []interface{}{len(updates)}}, nil
+}
+
+// plan finds the matching rows, through an index when the condition
+// allows, and computes the new version of each. Collecting them first
+// keeps relocated tuples from being visited twice by the scan and the
+// indexes from changing while they are scanned.
+func (e *UpdateExecutor) plan() ([]pendingUpdate, error) {
+	scan, err := planScan(e.tableHeap, e.schema, e.indexes, e.cond)
+	if err != nil {
+		return nil, err
+	}
+	var updates []pendingUpdate
+	for {
+		tuple, rid, err := scan.nextRow()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			return updates, nil
+		}
+		if e.cond != nil {
+			match, err := EvalPredicate(e.cond, tuple)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+
+		// Every SET expression sees the row as it was before the update.
+		values := append([]interface{}{}, tuple.Values...)
+		for _, a := range e.assignments {
+			if values[a.Index], err = EvalExpr(a.Expr, tuple); err != nil {
+				return nil, err
+			}
+		}
+		if values, err = CoerceTuple(e.schema, values); err != nil {
+			return nil, err
+		}
+
+		oldKeys, err := rowKeys(e.indexes, tuple.Values)
+		if err != nil {
+			return nil, err
+		}
+		newKeys, err := rowKeys(e.indexes, values)
+		if err != nil {
+			return nil, err
+		}
+		newData, err := SerializeTuple(e.schema, values)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, pendingUpdate{rid: rid, oldKeys: oldKeys, newKeys: newKeys, data: newData})
+	}
+}
+
+// checkKeys verifies that the keys of every unique index stay unique
+// once all updates are applied. A new key may reuse one that another
+// updated row is giving up.
+func (e *UpdateExecutor) checkKeys(updates []pendingUpdate) error {
+	for i, idx := range e.indexes {
+		if !idx.Unique {
+			continue
+		}
+
+		released := make(map[string]bool)
+		for _, u := range updates {
+			if old := u.oldKeys[i]; !old.equal(u.newKeys[i]) && !old.null {
+				released[string(old.value)] = true
+			}
+		}
+
+		claimed := make(map[string]bool)
+		for _, u := range updates {
+			key := u.newKeys[i]
+			if key.equal(u.oldKeys[i]) || key.null {
+				continue
+			}
+			if claimed[string(key.value)] {
+				return uniqueViolation(idx, key)
+			}
+			claimed[string(key.value)] = true
+			if released[string(key.value)] {
+				continue
+			}
+			if _, err := idx.Index.Search(key.value); err == nil {
+				return uniqueViolation(idx, key)
+			}
+		}
+	}
+	return nil
+}
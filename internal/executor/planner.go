@@ -0,0 +1,227 @@
+package executor
+
+import (
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// rowScanner is a scan that also reports where each row is stored, so
+// the delete and update executors can modify the rows they read.
+type rowScanner interface {
+	Executor
+	nextRow() (*Tuple, storage.RID, error)
+}
+
+// PlanScan returns the scan of a table that reads the fewest rows cond
+// allows: an index scan when cond constrains the leading columns of one
+// of indexes, and a sequential scan otherwise. The scan may return rows
+// that do not satisfy cond, so the caller still filters by it.
+//
+// cond must be bound with BindExpr to a schema that starts with the
+// table's columns, such as the table's own schema or a join of it with
+// other tables; columns past the table's are ignored.
+func PlanScan(heap *storage.TableHeap, schema []sql.ColumnDef, indexes []TableIndex, cond sql.Expr) (Executor, error) {
+	return planScan(heap, schema, indexes, cond)
+}
+
+func planScan(heap *storage.TableHeap, schema []sql.ColumnDef, indexes []TableIndex, cond sql.Expr) (rowScanner, error) {
+	bounds := make([]columnBounds, len(schema))
+	for _, conj := range conjuncts(cond, nil) {
+		addBound(bounds, schema, conj)
+	}
+
+	best, bestScore := -1, 0
+	for i, idx := range indexes {
+		if score := scanScore(schema, bounds, idx); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return NewSeqScanExecutor(heap, schema), nil
+	}
+
+	idx := indexes[best]
+	var lo, hi []interface{}
+	incl := index.Inclusive
+	for _, col := range idx.Columns {
+		b := bounds[col]
+		if b.eq != nil {
+			lo = append(lo, b.eq)
+			hi = append(hi, b.eq)
+			continue
+		}
+		// The first column without an equality may add a range, and
+		// ends the key.
+		if b.lo != nil {
+			lo = append(lo, b.lo)
+			if !b.loIncl {
+				incl &^= index.IncludeLo
+			}
+		}
+		if b.hi != nil {
+			hi = append(hi, b.hi)
+			if !b.hiIncl {
+				incl &^= index.IncludeHi
+			}
+		}
+		break
+	}
+	return NewIndexScanExecutor(heap, schema, idx, lo, hi, incl)
+}
+
+// columnBounds holds the constraints a condition places on one column.
+// A nil value means the condition does not bound the column that way.
+// notNull is set when the condition only holds for non-NULL values.
+type columnBounds struct {
+	eq             interface{}
+	lo, hi         interface{}
+	loIncl, hiIncl bool
+	notNull        bool
+}
+
+// scanScore rates how narrow a scan of idx is under bounds: two points
+// for each leading column fixed by an equality, and one for a range on
+// the column after them. Zero means the index cannot be used, either
+// because it does not help or because it leaves out matching rows: a
+// row with a NULL in any indexed column has no entry.
+func scanScore(schema []sql.ColumnDef, bounds []columnBounds, idx TableIndex) int {
+	for _, col := range idx.Columns {
+		if !schema[col].NotNull && !bounds[col].notNull {
+			return 0
+		}
+	}
+
+	score := 0
+	for _, col := range idx.Columns {
+		b := bounds[col]
+		if b.eq != nil {
+			score += 2
+			continue
+		}
+		if b.lo != nil || b.hi != nil {
+			score++
+		}
+		break
+	}
+	return score
+}
+
+// conjuncts appends the operands of the top-level ANDs in cond to out.
+func conjuncts(cond sql.Expr, out []sql.Expr) []sql.Expr {
+	if b, ok := cond.(*sql.BinaryExpr); ok && b.Op == "AND" {
+		return conjuncts(b.Right, conjuncts(b.Left, out))
+	}
+	if cond != nil {
+		out = append(out, cond)
+	}
+	return out
+}
+
+// flipped maps a comparison to the one that holds with its operands
+// swapped.
+var flipped = map[string]string{"=": "=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+// addBound narrows bounds by a comparison of a table column with a
+// literal: col op literal, literal op col, or col BETWEEN literal AND
+// literal, and notes columns tested with IS NOT NULL. Other conditions
+// are left to the filter.
+func addBound(bounds []columnBounds, schema []sql.ColumnDef, cond sql.Expr) {
+	switch e := cond.(type) {
+	case *sql.BinaryExpr:
+		op, ok := flipped[e.Op]
+		if !ok {
+			return
+		}
+		col, lit := e.Left, e.Right
+		if _, isLit := col.(*sql.Literal); isLit {
+			col, lit = lit, col
+		} else {
+			op = e.Op
+		}
+		c, v, ok := columnLiteral(schema, col, lit)
+		if !ok {
+			return
+		}
+		b := &bounds[c]
+		b.notNull = true
+		switch op {
+		case "=":
+			b.eq = v
+		case ">":
+			b.raiseLo(v, false)
+		case ">=":
+			b.raiseLo(v, true)
+		case "<":
+			b.lowerHi(v, false)
+		case "<=":
+			b.lowerHi(v, true)
+		}
+
+	case *sql.BetweenExpr:
+		if e.Not {
+			return
+		}
+		c, low, ok := columnLiteral(schema, e.Expr, e.Low)
+		if !ok {
+			return
+		}
+		_, high, ok := columnLiteral(schema, e.Expr, e.High)
+		if !ok {
+			return
+		}
+		bounds[c].notNull = true
+		bounds[c].raiseLo(low, true)
+		bounds[c].lowerHi(high, true)
+
+	case *sql.IsNullExpr:
+		if col, ok := e.Expr.(*BoundColumn); ok && e.Not && col.Index < len(schema) {
+			bounds[col.Index].notNull = true
+		}
+	}
+}
+
+// columnLiteral matches a table column and a non-NULL literal, and
+// returns the column's ordinal and the literal coerced to the column's
+// type. Literals that do not convert exactly, such as 2.5 for an INT
+// column, do not match.
+func columnLiteral(schema []sql.ColumnDef, colExpr, litExpr sql.Expr) (int, interface{}, bool) {
+	col, ok := colExpr.(*BoundColumn)
+	if !ok || col.Index >= len(schema) {
+		return 0, nil, false
+	}
+	lit, ok := litExpr.(*sql.Literal)
+	if !ok || lit.Value == nil {
+		return 0, nil, false
+	}
+	v, err := CoerceValue(schema[col.Index], lit.Value)
+	if err != nil || v == nil {
+		return 0, nil, false
+	}
+	if c, err := CompareValues(v, lit.Value); err != nil || c != 0 {
+		return 0, nil, false
+	}
+	return col.Index, v, true
+}
+
+// raiseLo tightens the lower bound to v if it is higher.
+func (b *columnBounds) raiseLo(v interface{}, incl bool) {
+	if b.lo != nil {
+		c, err := CompareValues(v, b.lo)
+		if err != nil || c < 0 || (c == 0 && incl) {
+			return
+		}
+	}
+	b.lo, b.loIncl = v, incl
+}
+
+// lowerHi tightens the upper bound to v if it is lower.
+func (b *columnBounds) lowerHi(v interface{}, incl bool) {
+	if b.hi != nil {
+		c, err := CompareValues(v, b.hi)
+		if err != nil || c > 0 || (c == 0 && incl) {
+			return
+		}
+	}
+	b.hi, b.hiIncl = v, incl
+}
@@ -0,0 +1,177 @@
+package executor_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+var scoreSchema = []sql.ColumnDef{
+	{Name: "id", Type: sql.TypeInt, NotNull: true},
+	{Name: "grp", Type: sql.TypeInt},
+	{Name: "name", Type: sql.TypeVarchar},
+	{Name: "score", Type: sql.TypeDouble},
+}
+
+// newScores fills a table with n rows and indexes on id, (grp, name)
+// and score. Every 50th row has no name.
+func newScores(t *testing.T, n int) (*storage.TableHeap, []executor.TableIndex) {
+	t.Helper()
+	dm, err := storage.NewDiskManager(filepath.Join(t.TempDir(), "scores.db"))
+	if err != nil {
+		t.Fatalf("NewDiskManager failed: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	bp := storage.NewBufferPool(50, dm)
+
+	heap, err := storage.NewTableHeap(bp, storage.InvalidPageID)
+	if err != nil {
+		t.Fatalf("NewTableHeap failed: %v", err)
+	}
+	indexes := []executor.TableIndex{
+		{Name: "scores_pkey", Columns: []int{0}, Unique: true},
+		{Name: "scores_grp_name", Columns: []int{1, 2}},
+		{Name: "scores_score", Columns: []int{3}},
+	}
+	for i := range indexes {
+		if indexes[i].Index, err = index.NewBTreeIndex(bp, storage.InvalidPageID); err != nil {
+			t.Fatalf("NewBTreeIndex failed: %v", err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		var name interface{} = fmt.Sprintf("n%03d", i)
+		if i%50 == 0 {
+			name = nil
+		}
+		row := []interface{}{i, i % 10, name, float64(i%37) - 18.5}
+		if _, err := executor.NewInsertExecutor(indexes, heap, scoreSchema, row).Next(); err != nil {
+			t.Fatalf("insert %v failed: %v", row, err)
+		}
+	}
+	return heap, indexes
+}
+
+// sortedByID returns rows sorted by their first column.
+func sortedByID(rows [][]interface{}) [][]interface{} {
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0].(int) < rows[j][0].(int) })
+	return rows
+}
+
+func TestPlanScan(t *testing.T) {
+	heap, indexes := newScores(t, 500)
+
+	cases := []struct {
+		cond      string
+		indexScan bool
+	}{
+		{"id = 42", true},
+		{"id > 100 AND id <= 120", true},
+		{"130 > id", true},
+		{"id BETWEEN 10 AND 20", true},
+		{"id >= 490 AND id > 495", true},
+		{"id < 20 AND id < 10 AND name LIKE 'n00%'", true},
+		{"id = 600", true},
+		{"grp = 3 AND name IS NOT NULL", true},
+		{"grp = 3 AND name = 'n013'", true},
+		{"grp = 3 AND name > 'n100' AND name <= 'n200'", true},
+		{"grp >= 8 AND name < 'n050'", true},
+		{"score < 0", true},
+		{"score = -18.5", true},
+		{"score > 10 AND id < 100", true},
+
+		// Rows with a NULL name are not in the (grp, name) index.
+		{"grp = 3", false},
+		{"name = 'n013'", false},
+		{"id + 1 = 5", false},
+		{"id = 2.5", false},
+		{"id > 1 OR id < 0", false},
+		{"NOT id = 5", false},
+		{"id = NULL", false},
+	}
+	for _, tc := range cases {
+		cond := bindWhere(t, tc.cond, scoreSchema)
+		scan, err := executor.PlanScan(heap, scoreSchema, indexes, cond)
+		if err != nil {
+			t.Fatalf("%s: PlanScan failed: %v", tc.cond, err)
+		}
+		if _, ok := scan.(*executor.IndexScanExecutor); ok != tc.indexScan {
+			t.Errorf("%s: expected index scan %v, got %T", tc.cond, tc.indexScan, scan)
+		}
+
+		got := sortedByID(collect(t, executor.NewFilterExecutor(scan, cond)))
+		want := sortedByID(collect(t, executor.NewFilterExecutor(executor.NewSeqScanExecutor(heap, scoreSchema), cond)))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %d rows, got %d", tc.cond, len(want), len(got))
+		}
+	}
+}
+
+func TestIndexScanOrder(t *testing.T) {
+	heap, indexes := newScores(t, 100)
+
+	// Rows come back in key order, with duplicates together.
+	scan, err := executor.NewIndexScanExecutor(heap, scoreSchema, indexes[1], []interface{}{7}, []interface{}{8}, index.Inclusive)
+	if err != nil {
+		t.Fatalf("NewIndexScanExecutor failed: %v", err)
+	}
+	var got []string
+	for _, row := range collect(t, scan) {
+		got = append(got, fmt.Sprintf("%v/%v", row[1], row[2]))
+	}
+	want := []string{
+		"7/n007", "7/n017", "7/n027", "7/n037", "7/n047", "7/n057", "7/n067", "7/n077", "7/n087", "7/n097",
+		"8/n008", "8/n018", "8/n028", "8/n038", "8/n048", "8/n058", "8/n068", "8/n078", "8/n088", "8/n098",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	scan, err = executor.NewIndexScanExecutor(heap, scoreSchema, indexes[0], []interface{}{95}, nil, index.Exclusive)
+	if err != nil {
+		t.Fatalf("NewIndexScanExecutor failed: %v", err)
+	}
+	if rows := collect(t, scan); len(rows) != 4 || rows[0][0] != 96 {
+		t.Errorf("expected ids 96 to 99, got %v", rows)
+	}
+}
+
+func TestDeleteUsesIndex(t *testing.T) {
+	heap, indexes := newScores(t, 300)
+
+	del := func(cond string) int {
+		t.Helper()
+		tuple, err := executor.NewDeleteExecutor(heap, indexes, scoreSchema, bindWhere(t, cond, scoreSchema)).Next()
+		if err != nil {
+			t.Fatalf("DELETE WHERE %s failed: %v", cond, err)
+		}
+		return tuple.Values[0].(int)
+	}
+	if n := del("id >= 100 AND id < 200"); n != 100 {
+		t.Errorf("expected 100 rows deleted, got %d", n)
+	}
+	if n := del("grp = 1 AND name IS NOT NULL"); n != 20 {
+		t.Errorf("expected 20 rows deleted, got %d", n)
+	}
+
+	// Every index still finds exactly the remaining rows.
+	for _, cond := range []string{"id >= 0", "grp >= 0 AND name IS NOT NULL", "score < 100"} {
+		where := bindWhere(t, cond, scoreSchema)
+		scan, err := executor.PlanScan(heap, scoreSchema, indexes, where)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := sortedByID(collect(t, executor.NewFilterExecutor(scan, where)))
+		want := sortedByID(collect(t, executor.NewFilterExecutor(executor.NewSeqScanExecutor(heap, scoreSchema), where)))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %d rows, got %d", cond, len(want), len(got))
+		}
+	}
+}
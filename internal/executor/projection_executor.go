@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+// ProjectionExecutor evaluates a SELECT list against each child tuple.
+type ProjectionExecutor struct {
+	child   Executor
+	exprs   []sql.Expr
+	columns []string
+}
+
+// NewProjectionExecutor creates a new projection executor. exprs must be
+// bound to the child's schema; columns names each output column. See
+// BindSelectList.
+func NewProjectionExecutor(child Executor, exprs []sql.Expr, columns []string) *ProjectionExecutor {
+	return &ProjectionExecutor{child: child, exprs: exprs, columns: columns}
+}
+
+// Columns returns the names of the output columns.
+func (e *ProjectionExecutor) Columns() []string {
+	return e.columns
+}
+
+func (e *ProjectionExecutor) Init() error  { return e.child.Init() }
+func (e *ProjectionExecutor) Close() error { return e.child.Close() }
+
+func (e *ProjectionExecutor) Next() (*Tuple, error) {
+	tuple, err := e.child.Next()
+	if err != nil || tuple == nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(e.exprs))
+	for i, expr := range e.exprs {
+		if values[i], err = EvalExpr(expr, tuple); err != nil {
+			return nil, err
+		}
+	}
+	return &Tuple{Values: values}, nil
+}
@@ -0,0 +1,261 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// TableIndex is an index over one or more columns of a table. The
+// insert, delete and update executors keep every index of a table in
+// step with its heap. Rows with a NULL in any indexed column are left
+// out of the index. A non-unique index may hold many rows per key; its
+// entries are stored with BTreeIndex.InsertEntry.
+type TableIndex struct {
+	Name    string
+	Columns []int
+	Unique  bool
+	Index   *index.BTreeIndex
+}
+
+// indexKey is a row's encoded key in one index. Null rows have no entry.
+type indexKey struct {
+	value  []byte
+	null   bool
+	values []interface{}
+}
+
+func (k indexKey) equal(other indexKey) bool {
+	return k.null == other.null && bytes.Equal(k.value, other.value)
+}
+
+// key extracts the row's key in idx from a coerced tuple.
+func (idx TableIndex) key(values []interface{}) (indexKey, error) {
+	k := indexKey{values: make([]interface{}, len(idx.Columns))}
+	for i, col := range idx.Columns {
+		k.values[i] = values[col]
+		if values[col] == nil {
+			k.null = true
+		}
+	}
+	if k.null {
+		return k, nil
+	}
+	encoded, err := index.EncodeKey(k.values...)
+	if err != nil {
+		return indexKey{}, fmt.Errorf("index %s: %w", idx.Name, err)
+	}
+	limit := index.MaxKeySize
+	if !idx.Unique {
+		limit = index.MaxDuplicateKeySize
+	}
+	if len(encoded) > limit {
+		return indexKey{}, fmt.Errorf("index %s: key of %d bytes exceeds the maximum of %d", idx.Name, len(encoded), limit)
+	}
+	k.value = encoded
+	return k, nil
+}
+
+// insert adds the row at rid under key. Null keys are not indexed.
+func (idx TableIndex) insert(key indexKey, rid storage.RID) error {
+	switch {
+	case key.null:
+		return nil
+	case idx.Unique:
+		return idx.Index.Insert(key.value, rid)
+	}
+	return idx.Index.InsertEntry(key.value, rid)
+}
+
+// entry returns what idx stores for the row at rid under key.
+func (idx TableIndex) entry(key indexKey, rid storage.RID) []byte {
+	if idx.Unique {
+		return key.value
+	}
+	return index.EntryKey(key.value, rid)
+}
+
+// delete removes the row at rid from under key.
+func (idx TableIndex) delete(key indexKey, rid storage.RID) error {
+	switch {
+	case key.null:
+		return nil
+	case idx.Unique:
+		return idx.Index.Delete(key.value)
+	}
+	return idx.Index.DeleteEntry(key.value, rid)
+}
+
+// rowKeys extracts the row's key in each of indexes.
+func rowKeys(indexes []TableIndex, values []interface{}) ([]indexKey, error) {
+	keys := make([]indexKey, len(indexes))
+	for i, idx := range indexes {
+		k, err := idx.key(values)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+// checkUnique fails if a unique index already holds the row's key.
+func checkUnique(indexes []TableIndex, keys []indexKey) error {
+	for i, idx := range indexes {
+		if !idx.Unique || keys[i].null {
+			continue
+		}
+		if _, err := idx.Index.Search(keys[i].value); err == nil {
+			return uniqueViolation(idx, keys[i])
+		}
+	}
+	return nil
+}
+
+func uniqueViolation(idx TableIndex, key indexKey) error {
+	return fmt.Errorf("unique constraint violation: key %s already exists in %s", key, idx.Name)
+}
+
+// String formats the key's values, as "(a, b)" for a composite key.
+func (k indexKey) String() string {
+	parts := make([]string, len(k.values))
+	for i, v := range k.values {
+		parts[i] = sql.FormatValue(v)
+	}
+	desc := strings.Join(parts, ", ")
+	if len(parts) > 1 {
+		desc = "(" + desc + ")"
+	}
+	return desc
+}
+
+// BuildIndex fills a new, empty index with the rows already in heap by
+// bulk loading it. A unique index fails on a duplicate key, leaving the
+// index empty.
+func BuildIndex(heap *storage.TableHeap, schema []sql.ColumnDef, idx TableIndex) error {
+	loader, err := index.NewBulkLoader(idx.Index, index.DefaultFillFactor, !idx.Unique)
+	if err != nil {
+		return err
+	}
+	defer loader.Close()
+
+	iter := heap.ScanIterator()
+	for {
+		data, rid, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			break
+		}
+
+		tuple, err := DeserializeTuple(schema, data)
+		if err != nil {
+			return err
+		}
+		k, err := idx.key(tuple.Values)
+		if err != nil {
+			return err
+		}
+		if k.null {
+			continue
+		}
+		if err := loader.Add(k.value, rid); err != nil {
+			return err
+		}
+	}
+
+	err = loader.Finish()
+	var dup *index.DuplicateKeyError
+	if errors.As(err, &dup) {
+		return duplicateRow(heap, schema, idx, dup.Key)
+	}
+	return err
+}
+
+// duplicateRow describes the duplicate key found by a bulk load, which
+// only knows its encoding, by finding a row that holds it.
+func duplicateRow(heap *storage.TableHeap, schema []sql.ColumnDef, idx TableIndex, key []byte) error {
+	iter := heap.ScanIterator()
+	for {
+		data, _, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return fmt.Errorf("unique constraint violation: duplicate key in %s", idx.Name)
+		}
+		tuple, err := DeserializeTuple(schema, data)
+		if err != nil {
+			return err
+		}
+		k, err := idx.key(tuple.Values)
+		if err != nil {
+			return err
+		}
+		if !k.null && bytes.Equal(k.value, key) {
+			return uniqueViolation(idx, k)
+		}
+	}
+}
+
+// VerifyIndex checks that idx holds exactly one entry for each row of
+// heap without a NULL in the indexed columns, stored under the row's key
+// and pointing at the row, and no other entries.
+func VerifyIndex(heap *storage.TableHeap, schema []sql.ColumnDef, idx TableIndex) error {
+	iter := heap.ScanIterator()
+	for {
+		data, rid, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			break
+		}
+		tuple, err := DeserializeTuple(schema, data)
+		if err != nil {
+			return err
+		}
+		k, err := idx.key(tuple.Values)
+		if err != nil {
+			return err
+		}
+		if k.null {
+			continue
+		}
+		if found, err := idx.Index.Search(idx.entry(k, rid)); err != nil || found != rid {
+			return fmt.Errorf("index %s: row %v with key %s has no entry", idx.Name, rid, k)
+		}
+	}
+
+	entries := idx.Index.Range(nil, nil, index.Inclusive)
+	for {
+		key, rid, ok, err := entries.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		data, err := heap.GetTuple(rid)
+		if err != nil {
+			return fmt.Errorf("index %s: entry %x points to missing row %v", idx.Name, key, rid)
+		}
+		tuple, err := DeserializeTuple(schema, data)
+		if err != nil {
+			return err
+		}
+		k, err := idx.key(tuple.Values)
+		if err != nil {
+			return err
+		}
+		if k.null || !bytes.Equal(idx.entry(k, rid), key) {
+			return fmt.Errorf("index %s: entry %x does not match row %v", idx.Name, key, rid)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package executor_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// ridOf returns where the row with the given id is stored.
+func ridOf(t *testing.T, pkey executor.TableIndex, id int) storage.RID {
+	t.Helper()
+	key, _ := index.EncodeKey(id)
+	rid, err := pkey.Index.Search(key)
+	if err != nil {
+		t.Fatalf("Search(%d) failed: %v", id, err)
+	}
+	return rid
+}
+
+func TestVerifyIndex(t *testing.T) {
+	cases := []struct {
+		name    string
+		corrupt func(t *testing.T, heap *storage.TableHeap, indexes []executor.TableIndex)
+		idx     int
+		want    string
+	}{
+		{"intact", func(*testing.T, *storage.TableHeap, []executor.TableIndex) {}, 0, ""},
+		{"intact composite", func(*testing.T, *storage.TableHeap, []executor.TableIndex) {}, 1, ""},
+		{"missing entry", func(t *testing.T, heap *storage.TableHeap, indexes []executor.TableIndex) {
+			key, _ := index.EncodeKey(7, "n017")
+			if err := indexes[1].Index.DeleteEntry(key, ridOf(t, indexes[0], 17)); err != nil {
+				t.Fatal(err)
+			}
+		}, 1, "has no entry"},
+		{"entry for a deleted row", func(t *testing.T, heap *storage.TableHeap, indexes []executor.TableIndex) {
+			if err := heap.DeleteTuple(ridOf(t, indexes[0], 42)); err != nil {
+				t.Fatal(err)
+			}
+		}, 0, "points to missing row"},
+		{"entry under the wrong key", func(t *testing.T, heap *storage.TableHeap, indexes []executor.TableIndex) {
+			key, _ := index.EncodeKey(1000.5)
+			if err := indexes[2].Index.InsertEntry(key, ridOf(t, indexes[0], 3)); err != nil {
+				t.Fatal(err)
+			}
+		}, 2, "does not match row"},
+		{"entry for the wrong row", func(t *testing.T, heap *storage.TableHeap, indexes []executor.TableIndex) {
+			key, _ := index.EncodeKey(5)
+			if err := indexes[0].Index.Delete(key); err != nil {
+				t.Fatal(err)
+			}
+			if err := indexes[0].Index.Insert(key, ridOf(t, indexes[0], 6)); err != nil {
+				t.Fatal(err)
+			}
+		}, 0, "has no entry"},
+	}
+
+	for _, tc := range cases {
+		heap, indexes := newScores(t, 300)
+		tc.corrupt(t, heap, indexes)
+		idx := indexes[tc.idx]
+		err := executor.VerifyIndex(heap, scoreSchema, idx)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%s: %s: unexpected error: %v", tc.name, idx.Name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: %s: expected an error containing %q, got %v", tc.name, idx.Name, tc.want, err)
+		}
+	}
+}
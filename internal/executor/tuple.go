@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+// Tuple Layout:
+//   Null bitmap: ceil(numColumns/8) bytes, bit i set if column i is NULL
+//   Column data in schema order, NULL columns omitted:
+//     INT, DATE:                  int32 (4 bytes, big-endian)
+//     BIGINT, TIMESTAMP:          int64 (8 bytes, big-endian)
+//     DECIMAL:                    unscaled int64 (8 bytes), scale from schema
+//     FLOAT:                      IEEE-754 float32 (4 bytes)
+//     DOUBLE:                     IEEE-754 float64 (8 bytes)
+//     BOOLEAN:                    1 byte
+//     VARCHAR, BLOB:              Length(2) followed by the raw bytes
+
+// SerializeTuple encodes values according to the table schema.
+// Values must already have their column's runtime type (see CoerceValue).
+func SerializeTuple(schema []sql.ColumnDef, values []interface{}) ([]byte, error) {
+	if len(values) != len(schema) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(schema), len(values))
+	}
+
+	bitmapLen := (len(schema) + 7) / 8
+	data := make([]byte, bitmapLen)
+
+	for i, col := range schema {
+		val := values[i]
+		if val == nil {
+			data[i/8] |= 1 << (i % 8)
+			continue
+		}
+
+		mismatch := fmt.Errorf("column %s expects %s, got %s", col.Name, col.Type, sql.FormatValue(val))
+		ok := false
+
+		switch col.Type {
+		case sql.TypeInt:
+			var v int
+			if v, ok = val.(int); ok {
+				if v < math.MinInt32 || v > math.MaxInt32 {
+					return nil, fmt.Errorf("value %d out of range for INT column %s", v, col.Name)
+				}
+				data = binary.BigEndian.AppendUint32(data, uint32(int32(v)))
+			}
+		case sql.TypeBigInt:
+			var v int64
+			if v, ok = val.(int64); ok {
+				data = binary.BigEndian.AppendUint64(data, uint64(v))
+			}
+		case sql.TypeBoolean:
+			var v bool
+			if v, ok = val.(bool); ok {
+				b := byte(0)
+				if v {
+					b = 1
+				}
+				data = append(data, b)
+			}
+		case sql.TypeFloat:
+			var v float64
+			if v, ok = val.(float64); ok {
+				data = binary.BigEndian.AppendUint32(data, math.Float32bits(float32(v)))
+			}
+		case sql.TypeDouble:
+			var v float64
+			if v, ok = val.(float64); ok {
+				data = binary.BigEndian.AppendUint64(data, math.Float64bits(v))
+			}
+		case sql.TypeDecimal:
+			var v sql.Decimal
+			if v, ok = val.(sql.Decimal); ok {
+				if v.Scale != col.Scale {
+					return nil, fmt.Errorf("decimal %s has scale %d, column %s expects %d", v, v.Scale, col.Name, col.Scale)
+				}
+				data = binary.BigEndian.AppendUint64(data, uint64(v.Unscaled))
+			}
+		case sql.TypeDate:
+			var v sql.Date
+			if v, ok = val.(sql.Date); ok {
+				data = binary.BigEndian.AppendUint32(data, uint32(v))
+			}
+		case sql.TypeTimestamp:
+			var v sql.Timestamp
+			if v, ok = val.(sql.Timestamp); ok {
+				data = binary.BigEndian.AppendUint64(data, uint64(v))
+			}
+		case sql.TypeVarchar:
+			var v string
+			if v, ok = val.(string); ok {
+				if len(v) > math.MaxUint16 {
+					return nil, fmt.Errorf("value too long for VARCHAR column %s", col.Name)
+				}
+				data = binary.BigEndian.AppendUint16(data, uint16(len(v)))
+				data = append(data, v...)
+			}
+		case sql.TypeBlob:
+			var v []byte
+			if v, ok = val.([]byte); ok {
+				if len(v) > math.MaxUint16 {
+					return nil, fmt.Errorf("value too long for BLOB column %s", col.Name)
+				}
+				data = binary.BigEndian.AppendUint16(data, uint16(len(v)))
+				data = append(data, v...)
+			}
+		default:
+			return nil, fmt.Errorf("column %s has unknown type %d", col.Name, col.Type)
+		}
+
+		if !ok {
+			return nil, mismatch
+		}
+	}
+	return data, nil
+}
+
+// DeserializeTuple decodes a tuple written by SerializeTuple.
+func DeserializeTuple(schema []sql.ColumnDef, data []byte) (*Tuple, error) {
+	bitmapLen := (len(schema) + 7) / 8
+	if len(data) < bitmapLen {
+		return nil, fmt.Errorf("corrupt tuple: %d bytes is shorter than null bitmap", len(data))
+	}
+
+	values := make([]interface{}, len(schema))
+	pos := bitmapLen
+
+	// next returns the following n bytes, or nil if the tuple is truncated.
+	next := func(n int) []byte {
+		if pos+n > len(data) {
+			return nil
+		}
+		b := data[pos : pos+n]
+		pos += n
+		return b
+	}
+
+	for i, col := range schema {
+		if data[i/8]&(1<<(i%8)) != 0 {
+			continue
+		}
+
+		var b []byte
+		switch col.Type {
+		case sql.TypeInt:
+			if b = next(4); b != nil {
+				values[i] = int(int32(binary.BigEndian.Uint32(b)))
+			}
+		case sql.TypeBigInt:
+			if b = next(8); b != nil {
+				values[i] = int64(binary.BigEndian.Uint64(b))
+			}
+		case sql.TypeBoolean:
+			if b = next(1); b != nil {
+				values[i] = b[0] != 0
+			}
+		case sql.TypeFloat:
+			if b = next(4); b != nil {
+				values[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+			}
+		case sql.TypeDouble:
+			if b = next(8); b != nil {
+				values[i] = math.Float64frombits(binary.BigEndian.Uint64(b))
+			}
+		case sql.TypeDecimal:
+			if b = next(8); b != nil {
+				values[i] = sql.Decimal{Unscaled: int64(binary.BigEndian.Uint64(b)), Scale: col.Scale}
+			}
+		case sql.TypeDate:
+			if b = next(4); b != nil {
+				values[i] = sql.Date(int32(binary.BigEndian.Uint32(b)))
+			}
+		case sql.TypeTimestamp:
+			if b = next(8); b != nil {
+				values[i] = sql.Timestamp(int64(binary.BigEndian.Uint64(b)))
+			}
+		case sql.TypeVarchar, sql.TypeBlob:
+			if b = next(2); b != nil {
+				if b = next(int(binary.BigEndian.Uint16(b))); b != nil {
+					if col.Type == sql.TypeVarchar {
+						values[i] = string(b)
+					} else {
+						values[i] = append([]byte{}, b...)
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("column %s has unknown type %d", col.Name, col.Type)
+		}
+
+		if b == nil {
+			return nil, fmt.Errorf("corrupt tuple: column %s truncated", col.Name)
+		}
+	}
+	return &Tuple{Values: values}, nil
+}
@@ -0,0 +1,157 @@
+package executor_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+func TestTupleRoundTrip(t *testing.T) {
+	schema := []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeInt},
+		{Name: "user_id", Type: sql.TypeInt},
+		{Name: "amount", Type: sql.TypeInt},
+		{Name: "note", Type: sql.TypeVarchar},
+	}
+
+	cases := [][]interface{}{
+		{101, 1, 500, "first order"},
+		{-7, 0, 2147483647, ""},
+		{1, nil, 3, nil},
+	}
+
+	for _, values := range cases {
+		data, err := executor.SerializeTuple(schema, values)
+		if err != nil {
+			t.Fatalf("SerializeTuple(%v) failed: %v", values, err)
+		}
+		tuple, err := executor.DeserializeTuple(schema, data)
+		if err != nil {
+			t.Fatalf("DeserializeTuple(%v) failed: %v", values, err)
+		}
+		if !reflect.DeepEqual(tuple.Values, values) {
+			t.Errorf("Round trip mismatch: expected %v, got %v", values, tuple.Values)
+		}
+	}
+}
+
+func TestTupleSchemaMismatch(t *testing.T) {
+	schema := []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeInt},
+		{Name: "name", Type: sql.TypeVarchar},
+	}
+
+	if _, err := executor.SerializeTuple(schema, []interface{}{1}); err == nil {
+		t.Error("Expected error for missing value")
+	}
+	if _, err := executor.SerializeTuple(schema, []interface{}{1, 2}); err == nil {
+		t.Error("Expected error for INT value in VARCHAR column")
+	}
+	if _, err := executor.DeserializeTuple(schema, []byte{0, 0, 0}); err == nil {
+		t.Error("Expected error for truncated tuple")
+	}
+}
+
+func TestTupleAllTypes(t *testing.T) {
+	schema := []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeBigInt},
+		{Name: "paid", Type: sql.TypeBoolean},
+		{Name: "ratio", Type: sql.TypeFloat},
+		{Name: "score", Type: sql.TypeDouble},
+		{Name: "amount", Type: sql.TypeDecimal, Precision: 10, Scale: 2},
+		{Name: "due", Type: sql.TypeDate},
+		{Name: "created", Type: sql.TypeTimestamp},
+		{Name: "receipt", Type: sql.TypeBlob},
+		{Name: "email", Type: sql.TypeVarchar, Length: 64},
+	}
+	literals := []interface{}{
+		9000000000,
+		true,
+		sql.Decimal{Unscaled: 15, Scale: 1},
+		1,
+		sql.Decimal{Unscaled: 19999, Scale: 3},
+		"2026-01-01",
+		sql.Date(20454),
+		"raw",
+		"ben@example.com",
+	}
+	want := []interface{}{
+		int64(9000000000),
+		true,
+		1.5,
+		1.0,
+		sql.Decimal{Unscaled: 2000, Scale: 2},
+		sql.Date(20454),
+		sql.Timestamp(1767225600000000),
+		[]byte("raw"),
+		"ben@example.com",
+	}
+
+	values, err := executor.CoerceTuple(schema, literals)
+	if err != nil {
+		t.Fatalf("CoerceTuple failed: %v", err)
+	}
+	data, err := executor.SerializeTuple(schema, values)
+	if err != nil {
+		t.Fatalf("SerializeTuple failed: %v", err)
+	}
+	tuple, err := executor.DeserializeTuple(schema, data)
+	if err != nil {
+		t.Fatalf("DeserializeTuple failed: %v", err)
+	}
+	if !reflect.DeepEqual(tuple.Values, want) {
+		t.Errorf("Round trip mismatch:\nexpected %#v\ngot      %#v", want, tuple.Values)
+	}
+}
+
+func TestCoerceValueErrors(t *testing.T) {
+	cases := []struct {
+		col sql.ColumnDef
+		val interface{}
+	}{
+		{sql.ColumnDef{Name: "a", Type: sql.TypeInt}, 1 << 40},
+		{sql.ColumnDef{Name: "a", Type: sql.TypeInt}, "1"},
+		{sql.ColumnDef{Name: "a", Type: sql.TypeBoolean}, 1},
+		{sql.ColumnDef{Name: "a", Type: sql.TypeDecimal, Precision: 4, Scale: 2}, 100},
+		{sql.ColumnDef{Name: "a", Type: sql.TypeDate}, "yesterday"},
+		{sql.ColumnDef{Name: "a", Type: sql.TypeVarchar, Length: 3}, "abcd"},
+	}
+	for _, c := range cases {
+		if _, err := executor.CoerceValue(c.col, c.val); err == nil {
+			t.Errorf("Expected error coercing %v into %s", c.val, c.col.Type)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	cases := []struct {
+		a, b interface{}
+		want int
+	}{
+		{1, 2, -1},
+		{int64(5), 5, 0},
+		{sql.Decimal{Unscaled: 150, Scale: 2}, 1.5, 0},
+		{sql.Decimal{Unscaled: 1001, Scale: 3}, 1, 1},
+		{"apple", "banana", -1},
+		{false, true, -1},
+		{sql.Date(20454), "2026-01-02", -1},
+		{sql.Timestamp(1767225600000000), sql.Date(20454), 0},
+		{[]byte{1, 2}, []byte{1}, 1},
+	}
+	for _, c := range cases {
+		got, err := executor.CompareValues(c.a, c.b)
+		if err != nil {
+			t.Errorf("CompareValues(%v, %v) failed: %v", c.a, c.b, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("CompareValues(%v, %v) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+
+	if _, err := executor.CompareValues(1, "1"); err == nil {
+		t.Error("Expected error comparing INT with VARCHAR")
+	}
+}
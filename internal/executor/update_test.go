@@ -0,0 +1,196 @@
+package executor_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/executor"
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+var accountSchema = []sql.ColumnDef{
+	{Name: "id", Type: sql.TypeInt, NotNull: true},
+	{Name: "name", Type: sql.TypeVarchar},
+	{Name: "balance", Type: sql.TypeInt},
+}
+
+type testTable struct {
+	heap  *storage.TableHeap
+	btree *index.BTreeIndex
+}
+
+func (tt *testTable) indexes() []executor.TableIndex {
+	return []executor.TableIndex{{Name: "accounts_pkey", Columns: []int{0}, Unique: true, Index: tt.btree}}
+}
+
+func newAccounts(t *testing.T, rows [][]interface{}) *testTable {
+	t.Helper()
+	dm, err := storage.NewDiskManager(filepath.Join(t.TempDir(), "accounts.db"))
+	if err != nil {
+		t.Fatalf("NewDiskManager failed: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	bp := storage.NewBufferPool(20, dm)
+
+	heap, err := storage.NewTableHeap(bp, storage.InvalidPageID)
+	if err != nil {
+		t.Fatalf("NewTableHeap failed: %v", err)
+	}
+	btree, err := index.NewBTreeIndex(bp, storage.InvalidPageID)
+	if err != nil {
+		t.Fatalf("NewBTreeIndex failed: %v", err)
+	}
+	tt := &testTable{heap: heap, btree: btree}
+	for _, row := range rows {
+		if _, err := executor.NewInsertExecutor(tt.indexes(), heap, accountSchema, row).Next(); err != nil {
+			t.Fatalf("insert %v failed: %v", row, err)
+		}
+	}
+	return tt
+}
+
+// update runs "UPDATE accounts SET <sets> [WHERE <cond>]".
+func (tt *testTable) update(t *testing.T, stmt string) (int, error) {
+	t.Helper()
+	p, err := sql.NewParser(sql.NewLexer("UPDATE accounts SET " + stmt))
+	if err != nil {
+		t.Fatalf("NewParser failed: %v", err)
+	}
+	parsed, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", stmt, err)
+	}
+	upd := parsed.(*sql.UpdateStatement)
+
+	schema := executor.TableSchema("accounts", accountSchema)
+	assignments, err := executor.BindAssignments(upd.Sets, schema)
+	if err != nil {
+		return 0, err
+	}
+	cond, err := executor.BindExpr(upd.Where, schema)
+	if err != nil {
+		return 0, err
+	}
+	tuple, err := executor.NewUpdateExecutor(tt.heap, tt.indexes(), accountSchema, assignments, cond).Next()
+	if err != nil {
+		return 0, err
+	}
+	return tuple.Values[0].(int), nil
+}
+
+// rows returns the table sorted by id and checks that the index maps
+// every id to its row.
+func (tt *testTable) rows(t *testing.T) [][]interface{} {
+	t.Helper()
+	got := collect(t, executor.NewSeqScanExecutor(tt.heap, accountSchema))
+	sort.Slice(got, func(i, j int) bool { return got[i][0].(int) < got[j][0].(int) })
+
+	for _, row := range got {
+		key, err := index.EncodeKey(row[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		rid, err := tt.btree.Search(key)
+		if err != nil {
+			t.Fatalf("index lookup of %v failed: %v", row[0], err)
+		}
+		data, err := tt.heap.GetTuple(rid)
+		if err != nil {
+			t.Fatalf("index entry for %v points at a missing tuple: %v", row[0], err)
+		}
+		tuple, err := executor.DeserializeTuple(accountSchema, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tuple.Values, row) {
+			t.Fatalf("index entry for %v points at %v", row[0], tuple.Values)
+		}
+	}
+	return got
+}
+
+func TestUpdateExecutorSetExpressions(t *testing.T) {
+	tt := newAccounts(t, [][]interface{}{
+		{1, "ben", 100},
+		{2, "ann", 50},
+		{3, "eve", nil},
+	})
+
+	if n, err := tt.update(t, "balance = balance + 1, name = 'x' WHERE balance >= 100"); err != nil || n != 1 {
+		t.Fatalf("expected 1 row updated, got %d, %v", n, err)
+	}
+	if n, err := tt.update(t, "balance = id * 10 WHERE id > 1"); err != nil || n != 2 {
+		t.Fatalf("expected 2 rows updated, got %d, %v", n, err)
+	}
+	// Growing a value relocates the tuple.
+	long := strings.Repeat("a", 3000)
+	if n, err := tt.update(t, "name = '"+long+"' WHERE id = 2"); err != nil || n != 1 {
+		t.Fatalf("expected 1 row updated, got %d, %v", n, err)
+	}
+
+	want := [][]interface{}{
+		{1, "x", 101},
+		{2, long, 20},
+		{3, "eve", 30},
+	}
+	if got := tt.rows(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestUpdateExecutorPrimaryKey(t *testing.T) {
+	tt := newAccounts(t, [][]interface{}{
+		{1, "ben", 100},
+		{2, "ann", 50},
+		{3, "eve", 10},
+	})
+
+	// Every key moves up by one; 2 and 3 are reused by other rows.
+	if n, err := tt.update(t, "id = id + 1"); err != nil || n != 3 {
+		t.Fatalf("expected 3 rows updated, got %d, %v", n, err)
+	}
+	want := [][]interface{}{
+		{2, "ben", 100},
+		{3, "ann", 50},
+		{4, "eve", 10},
+	}
+	if got := tt.rows(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	oldKey, _ := index.EncodeKey(1)
+	if _, err := tt.btree.Search(oldKey); err == nil {
+		t.Errorf("expected old key 1 to be removed from the index")
+	}
+
+	// Swap two keys in one statement.
+	if _, err := tt.update(t, "id = 6 - id WHERE id IN (2, 4)"); err != nil {
+		t.Fatalf("swap failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"id = 3 WHERE id = 2",
+		"id = 9 WHERE id > 2",
+		"id = NULL WHERE id = 2",
+		"balance = 'lots'",
+		"missing = 1",
+		"balance = 1, balance = 2",
+	} {
+		if _, err := tt.update(t, stmt); err == nil {
+			t.Errorf("UPDATE accounts SET %s: expected error", stmt)
+		}
+	}
+
+	want = [][]interface{}{
+		{2, "eve", 10},
+		{3, "ann", 50},
+		{4, "ben", 100},
+	}
+	if got := tt.rows(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("failed updates changed the table: expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,286 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+// CoerceValue converts a literal to the runtime representation of the
+// column's type, checking ranges, lengths and DECIMAL precision.
+func CoerceValue(col sql.ColumnDef, val interface{}) (interface{}, error) {
+	if val == nil {
+		if col.NotNull {
+			return nil, fmt.Errorf("null value in column %s violates NOT NULL constraint", col.Name)
+		}
+		return nil, nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("column %s expects %s, got %s", col.Name, col.Type, sql.FormatValue(val))
+	}
+
+	switch col.Type {
+	case sql.TypeInt, sql.TypeBigInt:
+		var n int64
+		switch v := val.(type) {
+		case int:
+			n = int64(v)
+		case int64:
+			n = v
+		default:
+			return nil, mismatch()
+		}
+		if col.Type == sql.TypeBigInt {
+			return n, nil
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("value %d out of range for INT column %s", n, col.Name)
+		}
+		return int(n), nil
+
+	case sql.TypeBoolean:
+		if v, ok := val.(bool); ok {
+			return v, nil
+		}
+		return nil, mismatch()
+
+	case sql.TypeFloat, sql.TypeDouble:
+		f, ok := toFloat(val)
+		if !ok {
+			return nil, mismatch()
+		}
+		if col.Type == sql.TypeFloat {
+			f = float64(float32(f))
+		}
+		return f, nil
+
+	case sql.TypeDecimal:
+		var d sql.Decimal
+		switch v := val.(type) {
+		case int:
+			d = sql.Decimal{Unscaled: int64(v)}
+		case int64:
+			d = sql.Decimal{Unscaled: v}
+		case sql.Decimal:
+			d = v
+		case float64:
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				return nil, fmt.Errorf("value %v out of range for DECIMAL column %s", v, col.Name)
+			}
+			parsed, err := sql.ParseDecimal(big.NewFloat(v).Text('f', col.Scale))
+			if err != nil {
+				return nil, fmt.Errorf("value %v out of range for DECIMAL column %s", v, col.Name)
+			}
+			d = parsed
+		default:
+			return nil, mismatch()
+		}
+		d, err := d.Rescale(col.Scale)
+		if err != nil {
+			return nil, fmt.Errorf("value %s out of range for DECIMAL column %s", sql.FormatValue(val), col.Name)
+		}
+		if d.Digits() > col.Precision {
+			return nil, fmt.Errorf("value %s exceeds DECIMAL(%d,%d) for column %s", sql.FormatValue(val), col.Precision, col.Scale, col.Name)
+		}
+		return d, nil
+
+	case sql.TypeDate:
+		switch v := val.(type) {
+		case sql.Date:
+			return v, nil
+		case sql.Timestamp:
+			return sql.DateFromTime(v.Time()), nil
+		case string:
+			return sql.ParseDate(v)
+		}
+		return nil, mismatch()
+
+	case sql.TypeTimestamp:
+		switch v := val.(type) {
+		case sql.Timestamp:
+			return v, nil
+		case sql.Date:
+			return sql.TimestampFromTime(v.Time()), nil
+		case string:
+			return sql.ParseTimestamp(v)
+		}
+		return nil, mismatch()
+
+	case sql.TypeVarchar:
+		v, ok := val.(string)
+		if !ok {
+			return nil, mismatch()
+		}
+		if col.Length > 0 && len(v) > col.Length {
+			return nil, fmt.Errorf("value too long for VARCHAR(%d) column %s", col.Length, col.Name)
+		}
+		return v, nil
+
+	case sql.TypeBlob:
+		switch v := val.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+		return nil, mismatch()
+	}
+	return nil, fmt.Errorf("column %s has unknown type %d", col.Name, col.Type)
+}
+
+// CoerceTuple coerces each value to its column's type.
+func CoerceTuple(schema []sql.ColumnDef, values []interface{}) ([]interface{}, error) {
+	if len(values) != len(schema) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(schema), len(values))
+	}
+	out := make([]interface{}, len(values))
+	for i, col := range schema {
+		v, err := CoerceValue(col, values[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// CompareValues orders two non-NULL values, returning -1, 0 or 1.
+// Numeric types compare by value across INT, BIGINT, DECIMAL and
+// floating point; strings are promoted when compared to dates and
+// timestamps. Values of unrelated types are an error.
+func CompareValues(a, b interface{}) (int, error) {
+	if isNumeric(a) && isNumeric(b) {
+		return compareNumeric(a, b), nil
+	}
+
+	switch av := a.(type) {
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return strings.Compare(av, bv), nil
+		case sql.Date, sql.Timestamp:
+			c, err := CompareValues(b, a)
+			return -c, err
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return compareOrdered(boolRank(av), boolRank(bv)), nil
+		}
+	case sql.Date:
+		switch bv := b.(type) {
+		case sql.Date:
+			return compareOrdered(av, bv), nil
+		case sql.Timestamp:
+			return compareOrdered(sql.TimestampFromTime(av.Time()), bv), nil
+		case string:
+			d, err := sql.ParseDate(bv)
+			if err != nil {
+				return 0, err
+			}
+			return compareOrdered(av, d), nil
+		}
+	case sql.Timestamp:
+		switch bv := b.(type) {
+		case sql.Timestamp:
+			return compareOrdered(av, bv), nil
+		case sql.Date:
+			return compareOrdered(av, sql.TimestampFromTime(bv.Time())), nil
+		case string:
+			ts, err := sql.ParseTimestamp(bv)
+			if err != nil {
+				return 0, err
+			}
+			return compareOrdered(av, ts), nil
+		}
+	case []byte:
+		switch bv := b.(type) {
+		case []byte:
+			return bytes.Compare(av, bv), nil
+		case string:
+			return bytes.Compare(av, []byte(bv)), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", sql.FormatValue(a), sql.FormatValue(b))
+}
+
+// ValuesEqual reports whether two values compare equal. NULL is not
+// equal to anything, and values that cannot be compared are not equal.
+func ValuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	c, err := CompareValues(a, b)
+	return err == nil && c == 0
+}
+
+func isNumeric(v interface{}) bool {
+	switch v.(type) {
+	case int, int64, float64, sql.Decimal:
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case sql.Decimal:
+		return n.Float64(), true
+	}
+	return 0, false
+}
+
+func toRat(v interface{}) *big.Rat {
+	switch n := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(n))
+	case int64:
+		return new(big.Rat).SetInt64(n)
+	case sql.Decimal:
+		return n.Rat()
+	}
+	return nil
+}
+
+func compareNumeric(a, b interface{}) int {
+	_, af := a.(float64)
+	_, bf := b.(float64)
+	if af || bf {
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		return compareOrdered(x, y)
+	}
+	if ai, ok := a.(int); ok {
+		if bi, ok := b.(int); ok {
+			return compareOrdered(ai, bi)
+		}
+	}
+	return toRat(a).Cmp(toRat(b))
+}
+
+func compareOrdered[T int | int64 | float64 | sql.Date | sql.Timestamp](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -1,189 +1,477 @@
-package index
-
-import (
-	"fmt"
-
-	"github.com/benkivuva/my-rdbms/internal/storage"
-)
-
-// BTreeIndex manages the B-tree structure for primary key lookups.
-type BTreeIndex struct {
-	bufferPool *storage.BufferPool
-	rootPageID storage.PageID
-}
-
-// NewBTreeIndex creates a new B-Tree index.
-// Pass InvalidPageID to allocate a new root.
-func NewBTreeIndex(bp *storage.BufferPool, rootID storage.PageID) (*BTreeIndex, error) {
-	bt := &BTreeIndex{
-		bufferPool: bp,
-		rootPageID: rootID,
-	}
-
-	if bt.rootPageID == storage.InvalidPageID {
-		root, err := bt.bufferPool.NewPage()
-		if err != nil {
-			return nil, err
-		}
-		defer bt.bufferPool.UnpinPage(root.ID, true)
-
-		node := NewBTreeNode(root)
-		node.Init(NodeTypeLeaf)
-		bt.rootPageID = root.ID
-	}
-	return bt, nil
-}
-
-// Search looks up the RID for the given key.
-func (bt *BTreeIndex) Search(key int64) (storage.RID, error) {
-	if bt.rootPageID == storage.InvalidPageID {
-		return storage.RID{}, fmt.Errorf("empty tree")
-	}
-
-	currPageID := bt.rootPageID
-
-	for {
-		page, err := bt.bufferPool.FetchPage(currPageID)
-		if err != nil {
-			return storage.RID{}, err
-		}
-		node := NewBTreeNode(page)
-
-		if node.IsLeaf() {
-			count := int(node.GetNumKeys())
-			for i := 0; i < count; i++ {
-				if node.GetKey(i) == key {
-					rid := node.GetValueRID(i)
-					bt.bufferPool.UnpinPage(currPageID, false)
-					return rid, nil
-				}
-			}
-			bt.bufferPool.UnpinPage(currPageID, false)
-			return storage.RID{}, fmt.Errorf("key %d not found", key)
-		}
-
-		// Internal node: find the appropriate child
-		count := int(node.GetNumKeys())
-		childPageID := storage.PageID(-1)
-		for i := count - 1; i >= 0; i-- {
-			if key >= node.GetKey(i) {
-				childPageID = node.GetValuePageID(i)
-				break
-			}
-		}
-
-		if childPageID == -1 && count > 0 {
-			childPageID = node.GetValuePageID(0)
-		}
-		if childPageID == -1 {
-			bt.bufferPool.UnpinPage(currPageID, false)
-			return storage.RID{}, fmt.Errorf("empty internal node")
-		}
-
-		bt.bufferPool.UnpinPage(currPageID, false)
-		currPageID = childPageID
-	}
-}
-
-// Insert inserts a key/RID pair into the index.
-func (bt *BTreeIndex) Insert(key int64, rid storage.RID) error {
-	path := make([]storage.PageID, 0)
-	currPageID := bt.rootPageID
-
-	var leafPage *storage.Page
-	var leafNode *BTreeNode
-
-	// Traverse to leaf
-	for {
-		path = append(path, currPageID)
-		page, err := bt.bufferPool.FetchPage(currPageID)
-		if err != nil {
-			return err
-		}
-		node := NewBTreeNode(page)
-
-		if node.IsLeaf() {
-			leafPage = page
-			leafNode = node
-			break
-		}
-
-		count := int(node.GetNumKeys())
-		childID := storage.PageID(-1)
-		if count > 0 {
-			childID = node.GetValuePageID(0)
-			for i := count - 1; i >= 0; i-- {
-				if key >= node.GetKey(i) {
-					childID = node.GetValuePageID(i)
-					break
-				}
-			}
-		}
-		bt.bufferPool.UnpinPage(currPageID, false)
-		currPageID = childID
-	}
-
-	// Insert into leaf
-	if leafNode.InsertLeaf(key, rid) {
-		bt.bufferPool.UnpinPage(leafPage.ID, true)
-		return nil
-	}
-
-	// Split leaf
-	newPage, err := bt.bufferPool.NewPage()
-	if err != nil {
-		bt.bufferPool.UnpinPage(leafPage.ID, false)
-		return err
-	}
-	newNode := NewBTreeNode(newPage)
-
-	splitKey := leafNode.SplitLeaf(newNode, newPage.ID)
-
-	if key >= splitKey {
-		newNode.InsertLeaf(key, rid)
-	} else {
-		leafNode.InsertLeaf(key, rid)
-	}
-
-	bt.bufferPool.UnpinPage(leafPage.ID, true)
-	bt.bufferPool.UnpinPage(newPage.ID, true)
-
-	return bt.insertIntoParent(path, splitKey, newPage.ID)
-}
-
-func (bt *BTreeIndex) insertIntoParent(path []storage.PageID, key int64, childPageID storage.PageID) error {
-	if len(path) == 1 {
-		// Root split: create new root
-		oldRootID := path[0]
-
-		newRootPage, err := bt.bufferPool.NewPage()
-		if err != nil {
-			return err
-		}
-		newRoot := NewBTreeNode(newRootPage)
-		newRoot.Init(NodeTypeInternal)
-
-		minKey := int64(-1 << 63)
-		newRoot.InsertInternal(minKey, oldRootID)
-		newRoot.InsertInternal(key, childPageID)
-
-		bt.rootPageID = newRootPage.ID
-		bt.bufferPool.UnpinPage(newRootPage.ID, true)
-		return nil
-	}
-
-	parentID := path[len(path)-2]
-
-	parentPage, err := bt.bufferPool.FetchPage(parentID)
-	if err != nil {
-		return err
-	}
-	parentNode := NewBTreeNode(parentPage)
-
-	if parentNode.InsertInternal(key, childPageID) {
-		bt.bufferPool.UnpinPage(parentID, true)
-		return nil
-	}
-
-	return fmt.Errorf("splitting internal nodes not implemented")
-}
+package index
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// BTreeIndex manages the B-tree structure for index lookups. Keys are
+// byte strings compared with bytes.Compare; see EncodeKey.
+type BTreeIndex struct {
+	bufferPool *storage.BufferPool
+	rootPageID storage.PageID
+}
+
+// NewBTreeIndex creates a new B-Tree index.
+// Pass InvalidPageID to allocate a new root.
+func NewBTreeIndex(bp *storage.BufferPool, rootID storage.PageID) (*BTreeIndex, error) {
+	bt := &BTreeIndex{
+		bufferPool: bp,
+		rootPageID: rootID,
+	}
+
+	if bt.rootPageID == storage.InvalidPageID {
+		root, err := bt.bufferPool.NewPageGuarded()
+		if err != nil {
+			return nil, err
+		}
+		defer root.Drop()
+
+		node := NewBTreeNode(root.Page())
+		node.Init(NodeTypeLeaf)
+		bt.rootPageID = root.PageID()
+	}
+	return bt, nil
+}
+
+// RootPageID returns the current root page of the tree.
+// The root moves when it splits, so callers persisting it must re-read it.
+func (bt *BTreeIndex) RootPageID() storage.PageID {
+	return bt.rootPageID
+}
+
+// Search looks up the RID for the given key.
+func (bt *BTreeIndex) Search(key []byte) (storage.RID, error) {
+	if bt.rootPageID == storage.InvalidPageID {
+		return storage.RID{}, fmt.Errorf("empty tree")
+	}
+
+	leaf, _, _, err := bt.findLeaf(key)
+	if err != nil {
+		return storage.RID{}, err
+	}
+	defer leaf.Drop()
+
+	node := NewBTreeNode(leaf.Page())
+	idx := node.LowerBound(key)
+	if idx < int(node.GetNumKeys()) && bytes.Equal(node.GetKey(idx), key) {
+		return node.GetValueRID(idx), nil
+	}
+	return storage.RID{}, fmt.Errorf("key not found")
+}
+
+// findLeaf descends from the root to the leaf that covers key and
+// returns it read-latched, along with the pages passed, ending with the
+// leaf, and in slots[i] the pair of path[i] followed to reach path[i+1].
+func (bt *BTreeIndex) findLeaf(key []byte) (leaf storage.ReadPageGuard, path []storage.PageID, slots []int, err error) {
+	currPageID := bt.rootPageID
+
+	for {
+		path = append(path, currPageID)
+		guard, err := bt.bufferPool.FetchPageRead(currPageID)
+		if err != nil {
+			return storage.ReadPageGuard{}, nil, nil, err
+		}
+		node := NewBTreeNode(guard.Page())
+
+		if node.IsLeaf() {
+			return guard, path, slots, nil
+		}
+
+		// Internal node: find the appropriate child
+		if node.GetNumKeys() == 0 {
+			guard.Drop()
+			return storage.ReadPageGuard{}, nil, nil, fmt.Errorf("empty internal node")
+		}
+		slot := node.ChildIndex(key)
+		slots = append(slots, slot)
+		childID := node.GetValuePageID(slot)
+		guard.Drop()
+		currPageID = childID
+	}
+}
+
+// Insert inserts a key/RID pair into the index.
+func (bt *BTreeIndex) Insert(key []byte, rid storage.RID) error {
+	if len(key) == 0 {
+		return fmt.Errorf("empty key")
+	}
+	if len(key) > MaxKeySize {
+		return fmt.Errorf("key of %d bytes exceeds the maximum of %d", len(key), MaxKeySize)
+	}
+
+	readLeaf, path, _, err := bt.findLeaf(key)
+	if err != nil {
+		return err
+	}
+	readLeaf.Drop()
+	leaf, err := bt.bufferPool.FetchPageWrite(path[len(path)-1])
+	if err != nil {
+		return err
+	}
+	defer leaf.Drop()
+	leafNode := NewBTreeNode(leaf.Page())
+
+	// Insert into leaf
+	if leafNode.InsertLeaf(key, rid) {
+		return nil
+	}
+
+	// Split leaf
+	newLeaf, err := bt.bufferPool.NewPageGuarded()
+	if err != nil {
+		return err
+	}
+	defer newLeaf.Drop()
+	newNode := NewBTreeNode(newLeaf.Page())
+	newID := newLeaf.PageID()
+
+	splitKey := leafNode.SplitLeaf(newNode, newID)
+
+	if bytes.Compare(key, splitKey) >= 0 {
+		newNode.InsertLeaf(key, rid)
+	} else {
+		leafNode.InsertLeaf(key, rid)
+	}
+
+	leaf.Drop()
+	newLeaf.Drop()
+	return bt.insertIntoParent(path, splitKey, newID)
+}
+
+// Delete removes key from the index. A node left less than half full
+// borrows pairs from a sibling or merges with it, merged-away pages
+// are freed, and the root is replaced by its only child when it has one.
+func (bt *BTreeIndex) Delete(key []byte) error {
+	leaf, path, slots, err := bt.findLeaf(key)
+	if err != nil {
+		return err
+	}
+	leafID := path[len(path)-1]
+
+	// Look the key up under the read latch so that a missing key does not
+	// dirty the leaf.
+	node := NewBTreeNode(leaf.Page())
+	idx := node.LowerBound(key)
+	found := idx < int(node.GetNumKeys()) && bytes.Equal(node.GetKey(idx), key)
+	leaf.Drop()
+	if !found {
+		return fmt.Errorf("key not found")
+	}
+
+	guard, err := bt.bufferPool.FetchPageWrite(leafID)
+	if err != nil {
+		return err
+	}
+	node = NewBTreeNode(guard.Page())
+	node.RemoveAt(idx)
+	underflow := len(path) > 1 && node.Underfull()
+	guard.Drop()
+	if underflow {
+		return bt.rebalance(path, slots)
+	}
+	return nil
+}
+
+// InsertEntry adds a key/RID pair to an index that allows duplicate
+// keys, storing it under EntryKey(key, rid). Such an index must only be
+// modified with InsertEntry and DeleteEntry and read with SearchAll.
+func (bt *BTreeIndex) InsertEntry(key []byte, rid storage.RID) error {
+	if len(key) > MaxDuplicateKeySize {
+		return fmt.Errorf("key of %d bytes exceeds the maximum of %d", len(key), MaxDuplicateKeySize)
+	}
+	return bt.Insert(EntryKey(key, rid), rid)
+}
+
+// DeleteEntry removes the pair of key and rid added by InsertEntry,
+// leaving other RIDs under the same key in place.
+func (bt *BTreeIndex) DeleteEntry(key []byte, rid storage.RID) error {
+	return bt.Delete(EntryKey(key, rid))
+}
+
+// rebalance fixes the underfull last node on path, whose parent holds
+// it at slots[len(slots)-1], together with its left sibling, or its
+// right one if it is the first child. The pair is merged when it fits
+// in one node and its pairs are redistributed otherwise. Each pair's key
+// is the lower bound of what it points to, so a node's first key
+// doubles as its separator in the parent.
+func (bt *BTreeIndex) rebalance(path []storage.PageID, slots []int) error {
+	parentID := path[len(path)-2]
+	idx := slots[len(slots)-1]
+
+	parentGuard, err := bt.bufferPool.FetchPageWrite(parentID)
+	if err != nil {
+		return err
+	}
+	defer parentGuard.Drop()
+	parent := NewBTreeNode(parentGuard.Page())
+
+	leftIdx := max(idx-1, 0)
+	if leftIdx+1 >= int(parent.GetNumKeys()) {
+		// An only child has no sibling; it stays underfull.
+		return nil
+	}
+	leftID := parent.GetValuePageID(leftIdx)
+	rightID := parent.GetValuePageID(leftIdx + 1)
+
+	leftGuard, err := bt.bufferPool.FetchPageWrite(leftID)
+	if err != nil {
+		return err
+	}
+	defer leftGuard.Drop()
+	rightGuard, err := bt.bufferPool.FetchPageWrite(rightID)
+	if err != nil {
+		return err
+	}
+	defer rightGuard.Drop()
+	left := NewBTreeNode(leftGuard.Page())
+	right := NewBTreeNode(rightGuard.Page())
+
+	if left.UsedBytes()+right.UsedBytes() > left.Capacity() {
+		moved, movedTo := redistribute(parent, leftIdx, left, right, leftID, rightID)
+		// A shorter separator can leave the parent underfull in turn.
+		underflow := len(path) > 2 && parent.Underfull()
+		rightGuard.Drop()
+		leftGuard.Drop()
+		parentGuard.Drop()
+		if err := bt.setParent(movedTo, moved...); err != nil {
+			return err
+		}
+		if underflow {
+			return bt.rebalance(path[:len(path)-1], slots[:len(slots)-1])
+		}
+		return nil
+	}
+
+	// Merge: the right node empties into the left.
+	var moved []storage.PageID
+	if left.IsLeaf() {
+		left.SetNextPageID(right.GetNextPageID())
+	} else {
+		for i := 0; i < int(right.GetNumKeys()); i++ {
+			moved = append(moved, right.GetValuePageID(i))
+		}
+	}
+	left.AppendPairs(right)
+	parent.RemoveAt(leftIdx + 1)
+
+	shrinkRoot := len(path) == 2 && parent.GetNumKeys() == 1
+	underflow := len(path) > 2 && parent.Underfull()
+
+	rightGuard.Drop()
+	leftGuard.Drop()
+	parentGuard.Drop()
+
+	if err := bt.bufferPool.DeletePage(rightID); err != nil {
+		return err
+	}
+	if err := bt.setParent(leftID, moved...); err != nil {
+		return err
+	}
+
+	switch {
+	case shrinkRoot:
+		bt.rootPageID = leftID
+		if err := bt.setParent(storage.InvalidPageID, leftID); err != nil {
+			return err
+		}
+		return bt.bufferPool.DeletePage(parentID)
+	case underflow:
+		return bt.rebalance(path[:len(path)-1], slots[:len(slots)-1])
+	}
+	return nil
+}
+
+// redistribute moves pairs from the fuller of two adjacent siblings to
+// the other for as long as each move narrows the gap between their
+// sizes, which may leave the receiving node the fuller one, and updates
+// the right node's separator in parent. If the new separator does not
+// fit in parent, fewer pairs are moved, down to none, which leaves the
+// underfull node as it is. For internal nodes it returns the children
+// that moved and the page they moved to, whose parent pointers the
+// caller must update.
+func redistribute(parent *BTreeNode, leftIdx int, left, right *BTreeNode, leftID, rightID storage.PageID) ([]storage.PageID, storage.PageID) {
+	leftUsed, rightUsed := left.UsedBytes(), right.UsedBytes()
+	fromRight := leftUsed < rightUsed
+
+	// Count the pairs to move. separator returns the right node's first
+	// key after moving count pairs.
+	count := 0
+	var separator func(count int) []byte
+	if fromRight {
+		for count < int(right.GetNumKeys())-1 {
+			size := right.pairSize(count)
+			if size >= rightUsed-leftUsed {
+				break
+			}
+			leftUsed += size
+			rightUsed -= size
+			count++
+		}
+		separator = func(count int) []byte { return right.GetKey(count) }
+	} else {
+		last := int(left.GetNumKeys()) - 1
+		for count < last {
+			size := left.pairSize(last - count)
+			if size >= leftUsed-rightUsed {
+				break
+			}
+			rightUsed += size
+			leftUsed -= size
+			count++
+		}
+		separator = func(count int) []byte { return left.GetKey(last - count + 1) }
+	}
+	for count > 0 && !parent.SetKey(leftIdx+1, separator(count)) {
+		count--
+	}
+	if count == 0 {
+		return nil, storage.InvalidPageID
+	}
+
+	var moved []storage.PageID
+	for i := 0; i < count; i++ {
+		if fromRight {
+			if !left.IsLeaf() {
+				moved = append(moved, right.GetValuePageID(0))
+			}
+			left.InsertPairAt(int(left.GetNumKeys()), right, 0)
+			right.RemoveAt(0)
+		} else {
+			last := int(left.GetNumKeys()) - 1
+			if !left.IsLeaf() {
+				moved = append(moved, left.GetValuePageID(last))
+			}
+			right.InsertPairAt(0, left, last)
+			left.RemoveAt(last)
+		}
+	}
+	if fromRight {
+		return moved, leftID
+	}
+	return moved, rightID
+}
+
+// insertIntoParent links a new node, childPageID, whose smallest key is
+// key, into the parent of the last node on path, splitting full internal
+// nodes up the path and growing a new root when the old one splits.
+func (bt *BTreeIndex) insertIntoParent(path []storage.PageID, key []byte, childPageID storage.PageID) error {
+	if len(path) == 1 {
+		// Root split: create new root
+		oldRootID := path[0]
+
+		newRoot, err := bt.bufferPool.NewPageGuarded()
+		if err != nil {
+			return err
+		}
+		newRootID := newRoot.PageID()
+		node := NewBTreeNode(newRoot.Page())
+		node.Init(NodeTypeInternal)
+
+		// The empty key sorts before every other key.
+		node.InsertInternal(nil, oldRootID)
+		node.InsertInternal(key, childPageID)
+		newRoot.Drop()
+
+		bt.rootPageID = newRootID
+		return bt.setParent(newRootID, oldRootID, childPageID)
+	}
+
+	parentID := path[len(path)-2]
+
+	parentGuard, err := bt.bufferPool.FetchPageWrite(parentID)
+	if err != nil {
+		return err
+	}
+	defer parentGuard.Drop()
+	parentNode := NewBTreeNode(parentGuard.Page())
+
+	if parentNode.InsertInternal(key, childPageID) {
+		parentGuard.Drop()
+		return bt.setParent(parentID, childPageID)
+	}
+
+	// Split the full parent and insert into whichever half covers key
+	newGuard, err := bt.bufferPool.NewPageGuarded()
+	if err != nil {
+		return err
+	}
+	defer newGuard.Drop()
+	newID := newGuard.PageID()
+	newNode := NewBTreeNode(newGuard.Page())
+
+	splitKey := parentNode.SplitInternal(newNode)
+
+	intoNew := bytes.Compare(key, splitKey) >= 0
+	if intoNew {
+		newNode.InsertInternal(key, childPageID)
+	} else {
+		parentNode.InsertInternal(key, childPageID)
+	}
+
+	moved := make([]storage.PageID, newNode.GetNumKeys())
+	for i := range moved {
+		moved[i] = newNode.GetValuePageID(i)
+	}
+
+	parentGuard.Drop()
+	newGuard.Drop()
+
+	if !intoNew {
+		if err := bt.setParent(parentID, childPageID); err != nil {
+			return err
+		}
+	}
+	if err := bt.setParent(newID, moved...); err != nil {
+		return err
+	}
+
+	return bt.insertIntoParent(path[:len(path)-1], splitKey, newID)
+}
+
+// setParent records parentID as the parent of each child page.
+func (bt *BTreeIndex) setParent(parentID storage.PageID, children ...storage.PageID) error {
+	for _, childID := range children {
+		guard, err := bt.bufferPool.FetchPageWrite(childID)
+		if err != nil {
+			return err
+		}
+		NewBTreeNode(guard.Page()).SetParentPageID(parentID)
+		guard.Drop()
+	}
+	return nil
+}
+
+// Drop frees every page of the tree. The index must not be used
+// afterwards.
+func (bt *BTreeIndex) Drop() error {
+	if err := bt.dropPage(bt.rootPageID); err != nil {
+		return err
+	}
+	bt.rootPageID = storage.InvalidPageID
+	return nil
+}
+
+func (bt *BTreeIndex) dropPage(pageID storage.PageID) error {
+	guard, err := bt.bufferPool.FetchPageRead(pageID)
+	if err != nil {
+		return err
+	}
+	node := NewBTreeNode(guard.Page())
+	var children []storage.PageID
+	if !node.IsLeaf() {
+		for i := 0; i < int(node.GetNumKeys()); i++ {
+			children = append(children, node.GetValuePageID(i))
+		}
+	}
+	guard.Drop()
+
+	for _, childID := range children {
+		if err := bt.dropPage(childID); err != nil {
+			return err
+		}
+	}
+	return bt.bufferPool.DeletePage(pageID)
+}
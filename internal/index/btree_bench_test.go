@@ -0,0 +1,141 @@
+package index_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// fullNode returns a node of the given type filled to capacity with the
+// keys 0, 2, 4, ...
+func fullNode(nodeType uint32) *index.BTreeNode {
+	node := index.NewBTreeNode(storage.NewPage(0))
+	node.Init(nodeType)
+	for i := int64(0); ; i++ {
+		var ok bool
+		if nodeType == index.NodeTypeLeaf {
+			ok = node.InsertLeaf(keyFor(i*2), ridFor(i*2))
+		} else {
+			ok = node.InsertInternal(keyFor(i*2), storage.PageID(i))
+		}
+		if !ok {
+			return node
+		}
+	}
+}
+
+// linearFind is the scan nodes used before binary search, kept as a
+// baseline for the benchmarks below.
+func linearFind(node *index.BTreeNode, key []byte) int {
+	count := int(node.GetNumKeys())
+	for i := 0; i < count; i++ {
+		if bytes.Compare(node.GetKey(i), key) >= 0 {
+			return i
+		}
+	}
+	return count
+}
+
+// linearChild is the backwards walk internal nodes used before binary
+// search.
+func linearChild(node *index.BTreeNode, key []byte) int {
+	for i := int(node.GetNumKeys()) - 1; i >= 0; i-- {
+		if bytes.Compare(key, node.GetKey(i)) >= 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+func TestNodeSearchMatchesLinearScan(t *testing.T) {
+	for _, nodeType := range []uint32{index.NodeTypeLeaf, index.NodeTypeInternal} {
+		node := fullNode(nodeType)
+		maxKey := int64(node.GetNumKeys() * 2)
+		for k := int64(-1); k <= maxKey; k++ {
+			key := keyFor(k)
+			if got, want := node.LowerBound(key), linearFind(node, key); got != want {
+				t.Fatalf("type %d: LowerBound(%d) = %d, want %d", nodeType, k, got, want)
+			}
+			if got, want := node.ChildIndex(key), linearChild(node, key); got != want {
+				t.Fatalf("type %d: ChildIndex(%d) = %d, want %d", nodeType, k, got, want)
+			}
+		}
+	}
+}
+
+func benchmarkNode(b *testing.B, nodeType uint32, find func(*index.BTreeNode, []byte) int) {
+	node := fullNode(nodeType)
+	var keys [][]byte
+	for _, k := range rand.New(rand.NewSource(1)).Perm(int(node.GetNumKeys()) * 2) {
+		keys = append(keys, keyFor(int64(k)))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		find(node, keys[i%len(keys)])
+	}
+}
+
+func BenchmarkLeafLowerBound(b *testing.B) {
+	benchmarkNode(b, index.NodeTypeLeaf, (*index.BTreeNode).LowerBound)
+}
+
+func BenchmarkLeafLinearScan(b *testing.B) {
+	benchmarkNode(b, index.NodeTypeLeaf, linearFind)
+}
+
+func BenchmarkInternalChildIndex(b *testing.B) {
+	benchmarkNode(b, index.NodeTypeInternal, (*index.BTreeNode).ChildIndex)
+}
+
+func BenchmarkInternalLinearScan(b *testing.B) {
+	benchmarkNode(b, index.NodeTypeInternal, linearChild)
+}
+
+func BenchmarkBTreeSearch(b *testing.B) {
+	bt, _ := newTestTree(b)
+	const n = 200000
+	keys := rand.New(rand.NewSource(1)).Perm(n)
+	for _, k := range keys {
+		if err := bt.Insert(keyFor(int64(k)), ridFor(int64(k))); err != nil {
+			b.Fatalf("Insert(%d) failed: %v", k, err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := bt.Search(keyFor(int64(keys[i%n]))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBTreeInsert(b *testing.B) {
+	bt, _ := newTestTree(b)
+	keys := rand.New(rand.NewSource(1)).Perm(b.N)
+	b.ResetTimer()
+	for _, k := range keys {
+		if err := bt.Insert(keyFor(int64(k)), ridFor(int64(k))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBTreeBulkLoad(b *testing.B) {
+	bt, _ := newTestTree(b)
+	keys := rand.New(rand.NewSource(1)).Perm(b.N)
+	b.ResetTimer()
+	loader, err := index.NewBulkLoader(bt, index.DefaultFillFactor, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, k := range keys {
+		if err := loader.Add(keyFor(int64(k)), ridFor(int64(k))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := loader.Finish(); err != nil {
+		b.Fatal(err)
+	}
+}
@@ -0,0 +1,231 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// Inclusivity selects which ends of a key range are part of the range.
+type Inclusivity int
+
+const (
+	Exclusive Inclusivity = 0
+	IncludeLo Inclusivity = 1 << 0
+	IncludeHi Inclusivity = 1 << 1
+	Inclusive             = IncludeLo | IncludeHi
+)
+
+// RangeIterator walks the keys of a range in order, or in reverse
+// order for iterators returned by ReverseRange. The tree must not be
+// modified while an iterator is in use.
+type RangeIterator struct {
+	bt      *BTreeIndex
+	lo, hi  []byte
+	incl    Inclusivity
+	reverse bool
+	started bool
+	done    bool
+
+	// path and slots hold the internal nodes above the current leaf and
+	// the pair followed in each; the reverse iterator climbs them to
+	// reach the previous leaf, since leaves only link forward.
+	path   []storage.PageID
+	slots  []int
+	leafID storage.PageID
+	pos    int
+}
+
+// Range returns an iterator over the keys between lo and hi in
+// ascending order. A nil bound leaves that end of the range open.
+func (bt *BTreeIndex) Range(lo, hi []byte, incl Inclusivity) *RangeIterator {
+	return &RangeIterator{bt: bt, lo: lo, hi: hi, incl: incl}
+}
+
+// ReverseRange is like Range but returns keys in descending order.
+func (bt *BTreeIndex) ReverseRange(lo, hi []byte, incl Inclusivity) *RangeIterator {
+	return &RangeIterator{bt: bt, lo: lo, hi: hi, incl: incl, reverse: true}
+}
+
+// SearchAll returns an iterator over the RIDs stored under key by
+// InsertEntry, in RID order. The keys it returns carry the RID suffix
+// added by EntryKey.
+func (bt *BTreeIndex) SearchAll(key []byte) *RangeIterator {
+	hi := append(append([]byte(nil), key...), bytes.Repeat([]byte{0xFF}, ridSize)...)
+	return bt.Range(key, hi, Inclusive)
+}
+
+// Next returns a copy of the next key and its RID. ok is false once
+// the range is exhausted.
+func (it *RangeIterator) Next() (key []byte, rid storage.RID, ok bool, err error) {
+	if it.done {
+		return nil, storage.RID{}, false, nil
+	}
+	if !it.started {
+		if err := it.seek(); err != nil {
+			return nil, storage.RID{}, false, err
+		}
+		it.started = true
+	}
+
+	for {
+		guard, err := it.bt.bufferPool.FetchPageRead(it.leafID)
+		if err != nil {
+			return nil, storage.RID{}, false, err
+		}
+		node := NewBTreeNode(guard.Page())
+		count := int(node.GetNumKeys())
+
+		if it.pos >= 0 && it.pos < count {
+			key := append([]byte(nil), node.GetKey(it.pos)...)
+			rid := node.GetValueRID(it.pos)
+			guard.Drop()
+			if !it.inRange(key) {
+				it.done = true
+				return nil, storage.RID{}, false, nil
+			}
+			if it.reverse {
+				it.pos--
+			} else {
+				it.pos++
+			}
+			return key, rid, true, nil
+		}
+
+		nextID := node.GetNextPageID()
+		guard.Drop()
+		if it.reverse {
+			if err := it.prevLeaf(); err != nil {
+				return nil, storage.RID{}, false, err
+			}
+		} else {
+			it.leafID = nextID
+			it.pos = 0
+		}
+		if it.leafID == storage.InvalidPageID {
+			it.done = true
+			return nil, storage.RID{}, false, nil
+		}
+	}
+}
+
+// inRange reports whether key is before the far end of the range. The
+// near end is handled by seek.
+func (it *RangeIterator) inRange(key []byte) bool {
+	if it.reverse {
+		if it.lo == nil {
+			return true
+		}
+		c := bytes.Compare(key, it.lo)
+		return c > 0 || (c == 0 && it.incl&IncludeLo != 0)
+	}
+	if it.hi == nil {
+		return true
+	}
+	c := bytes.Compare(key, it.hi)
+	return c < 0 || (c == 0 && it.incl&IncludeHi != 0)
+}
+
+// seek positions the iterator on the first key of the range: the
+// smallest key at or above lo, or the largest at or below hi when
+// iterating in reverse.
+func (it *RangeIterator) seek() error {
+	pick := func(n *BTreeNode) int { return n.ChildIndex(it.lo) }
+	if it.reverse {
+		pick = func(n *BTreeNode) int {
+			if it.hi == nil {
+				return int(n.GetNumKeys()) - 1
+			}
+			return n.ChildIndex(it.hi)
+		}
+	}
+	if err := it.descend(it.bt.rootPageID, pick); err != nil {
+		return err
+	}
+
+	guard, err := it.bt.bufferPool.FetchPageRead(it.leafID)
+	if err != nil {
+		return err
+	}
+	defer guard.Drop()
+	node := NewBTreeNode(guard.Page())
+	switch {
+	case it.reverse && it.hi == nil:
+		it.pos = int(node.GetNumKeys()) - 1
+	case it.reverse && it.incl&IncludeHi != 0:
+		it.pos = node.UpperBound(it.hi) - 1
+	case it.reverse:
+		it.pos = node.LowerBound(it.hi) - 1
+	case it.incl&IncludeLo != 0:
+		it.pos = node.LowerBound(it.lo)
+	default:
+		it.pos = node.UpperBound(it.lo)
+	}
+	return nil
+}
+
+// descend follows the child chosen by pick from pageID down to a leaf,
+// pushing the internal nodes passed onto the iterator's path.
+func (it *RangeIterator) descend(pageID storage.PageID, pick func(*BTreeNode) int) error {
+	for {
+		guard, err := it.bt.bufferPool.FetchPageRead(pageID)
+		if err != nil {
+			return err
+		}
+		node := NewBTreeNode(guard.Page())
+		if node.IsLeaf() {
+			guard.Drop()
+			it.leafID = pageID
+			return nil
+		}
+
+		if node.GetNumKeys() == 0 {
+			guard.Drop()
+			return fmt.Errorf("empty internal node")
+		}
+		slot := pick(node)
+		childID := node.GetValuePageID(slot)
+		guard.Drop()
+
+		it.path = append(it.path, pageID)
+		it.slots = append(it.slots, slot)
+		pageID = childID
+	}
+}
+
+// prevLeaf moves the iterator to the end of the leaf before the
+// current one, or sets leafID to InvalidPageID at the first leaf.
+func (it *RangeIterator) prevLeaf() error {
+	// Climb until a node has a child left of the one we came from.
+	for len(it.path) > 0 && it.slots[len(it.slots)-1] == 0 {
+		it.path = it.path[:len(it.path)-1]
+		it.slots = it.slots[:len(it.slots)-1]
+	}
+	if len(it.path) == 0 {
+		it.leafID = storage.InvalidPageID
+		return nil
+	}
+
+	top := len(it.path) - 1
+	it.slots[top]--
+	guard, err := it.bt.bufferPool.FetchPageRead(it.path[top])
+	if err != nil {
+		return err
+	}
+	childID := NewBTreeNode(guard.Page()).GetValuePageID(it.slots[top])
+	guard.Drop()
+
+	rightmost := func(n *BTreeNode) int { return int(n.GetNumKeys()) - 1 }
+	if err := it.descend(childID, rightmost); err != nil {
+		return err
+	}
+
+	guard, err = it.bt.bufferPool.FetchPageRead(it.leafID)
+	if err != nil {
+		return err
+	}
+	it.pos = int(NewBTreeNode(guard.Page()).GetNumKeys()) - 1
+	guard.Drop()
+	return nil
+}
@@ -0,0 +1,156 @@
+package index_test
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+)
+
+// collect drains it, checking that each RID matches its key.
+func collect(t *testing.T, it *index.RangeIterator) []int64 {
+	t.Helper()
+	var keys []int64
+	for {
+		key, rid, ok, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if !ok {
+			return keys
+		}
+		k := keyInt(key)
+		if rid != ridFor(k) {
+			t.Fatalf("key %d: expected %v, got %v", k, ridFor(k), rid)
+		}
+		keys = append(keys, k)
+	}
+}
+
+// expectRange returns the even keys below n that lie in the range,
+// ascending.
+func expectRange(n, lo, hi int64, incl index.Inclusivity) []int64 {
+	var keys []int64
+	for k := int64(0); k < n; k += 2 {
+		if (k > lo || (k == lo && incl&index.IncludeLo != 0)) &&
+			(k < hi || (k == hi && incl&index.IncludeHi != 0)) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func equalKeys(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func reversed(keys []int64) []int64 {
+	out := make([]int64, len(keys))
+	for i, k := range keys {
+		out[len(keys)-1-i] = k
+	}
+	return out
+}
+
+func TestBTreeRange(t *testing.T) {
+	bt, _ := newTestTree(t)
+
+	// Even keys only, so odd bounds fall between stored keys.
+	const n = 20000
+	rng := rand.New(rand.NewSource(3))
+	for _, i := range rng.Perm(n / 2) {
+		k := int64(i * 2)
+		if err := bt.Insert(keyFor(k), ridFor(k)); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", k, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		lo, hi int64
+		incl   index.Inclusivity
+	}{
+		{"inclusive", 100, 200, index.Inclusive},
+		{"exclusive", 100, 200, index.Exclusive},
+		{"lo only", 100, 200, index.IncludeLo},
+		{"hi only", 100, 200, index.IncludeHi},
+		{"odd bounds", 101, 199, index.Inclusive},
+		{"spans leaves", 1000, 9000, index.Inclusive},
+		{"single key", 500, 500, index.Inclusive},
+		{"empty point", 500, 500, index.IncludeLo},
+		{"inverted", 200, 100, index.Inclusive},
+		{"below all", -50, -1, index.Inclusive},
+		{"above all", n, n + 100, index.Inclusive},
+		{"everything", math.MinInt64, math.MaxInt64, index.Inclusive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := expectRange(n, tt.lo, tt.hi, tt.incl)
+			if got := collect(t, bt.Range(keyFor(tt.lo), keyFor(tt.hi), tt.incl)); !equalKeys(got, want) {
+				t.Errorf("Range: expected %d keys %v, got %d keys %v", len(want), head(want), len(got), head(got))
+			}
+			want = reversed(want)
+			if got := collect(t, bt.ReverseRange(keyFor(tt.lo), keyFor(tt.hi), tt.incl)); !equalKeys(got, want) {
+				t.Errorf("ReverseRange: expected %d keys %v, got %d keys %v", len(want), head(want), len(got), head(got))
+			}
+		})
+	}
+}
+
+func TestBTreeRangeMinMax(t *testing.T) {
+	bt, _ := newTestTree(t)
+
+	it := bt.Range(nil, nil, index.Inclusive)
+	if _, _, ok, err := it.Next(); ok || err != nil {
+		t.Fatalf("expected an empty tree to yield nothing, got ok=%v err=%v", ok, err)
+	}
+
+	for k := int64(-500); k <= 5000; k++ {
+		if err := bt.Insert(keyFor(k), ridFor(k)); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", k, err)
+		}
+	}
+	// Emptying the leftmost leaves makes the reverse scan climb past them.
+	for k := int64(-500); k < 3000; k++ {
+		if err := bt.Delete(keyFor(k)); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", k, err)
+		}
+	}
+
+	// Nil bounds leave the range open.
+	minKey, _, ok, err := bt.Range(nil, nil, index.Exclusive).Next()
+	if err != nil || !ok || !bytes.Equal(minKey, keyFor(3000)) {
+		t.Errorf("expected min 3000, got %x (ok=%v, err=%v)", minKey, ok, err)
+	}
+	maxKey, _, ok, err := bt.ReverseRange(nil, nil, index.Exclusive).Next()
+	if err != nil || !ok || !bytes.Equal(maxKey, keyFor(5000)) {
+		t.Errorf("expected max 5000, got %x (ok=%v, err=%v)", maxKey, ok, err)
+	}
+	if got := collect(t, bt.ReverseRange(nil, keyFor(2999), index.Inclusive)); len(got) != 0 {
+		t.Errorf("expected no keys below 3000, got %d", len(got))
+	}
+	if got := collect(t, bt.ReverseRange(nil, nil, index.Inclusive)); len(got) != 2001 {
+		t.Errorf("expected 2001 keys, got %d", len(got))
+	}
+	if got := collect(t, bt.Range(keyFor(4990), nil, index.Exclusive)); !equalKeys(got, []int64{4991, 4992, 4993, 4994, 4995, 4996, 4997, 4998, 4999, 5000}) {
+		t.Errorf("expected the keys above 4990, got %v", got)
+	}
+}
+
+// head shortens keys for error messages.
+func head(keys []int64) []int64 {
+	if len(keys) > 8 {
+		return keys[:8]
+	}
+	return keys
+}
@@ -1,203 +1,367 @@
-package index
-
-import (
-	"encoding/binary"
-	"sort"
-
-	"github.com/benkivuva/my-rdbms/internal/storage"
-)
-
-const (
-	NodeTypeInternal = 1
-	NodeTypeLeaf     = 2
-)
-
-// BTreeNode Layout:
-// Header (24 bytes):
-//   [0-3]:   NodeType (uint32)
-//   [4-7]:   NumKeys (uint32)
-//   [8-15]:  ParentPageID (int64)
-//   [16-23]: NextPageID (int64, for leaf linking)
-//
-// Body:
-//   Internal: [Key(8), ChildPageID(8)] pairs (16 bytes each)
-//   Leaf:     [Key(8), RID(12)] pairs (20 bytes each)
-
-const HeaderSize = 24
-
-// BTreeNode wraps a page to provide B-Tree node operations.
-type BTreeNode struct {
-	data []byte
-}
-
-// NewBTreeNode creates a B-Tree node view over a page.
-func NewBTreeNode(page *storage.Page) *BTreeNode {
-	return &BTreeNode{data: page.GetData()}
-}
-
-// Init initializes the node with the given type.
-func (n *BTreeNode) Init(nodeType uint32) {
-	n.SetNodeType(nodeType)
-	n.SetNumKeys(0)
-	n.SetParentPageID(-1)
-	n.SetNextPageID(-1)
-}
-
-func (n *BTreeNode) GetNodeType() uint32 {
-	return binary.BigEndian.Uint32(n.data[0:4])
-}
-
-func (n *BTreeNode) SetNodeType(t uint32) {
-	binary.BigEndian.PutUint32(n.data[0:4], t)
-}
-
-func (n *BTreeNode) GetNumKeys() uint32 {
-	return binary.BigEndian.Uint32(n.data[4:8])
-}
-
-func (n *BTreeNode) SetNumKeys(num uint32) {
-	binary.BigEndian.PutUint32(n.data[4:8], num)
-}
-
-func (n *BTreeNode) GetParentPageID() storage.PageID {
-	return storage.PageID(int64(binary.BigEndian.Uint64(n.data[8:16])))
-}
-
-func (n *BTreeNode) SetParentPageID(pid storage.PageID) {
-	binary.BigEndian.PutUint64(n.data[8:16], uint64(pid))
-}
-
-func (n *BTreeNode) GetNextPageID() storage.PageID {
-	return storage.PageID(int64(binary.BigEndian.Uint64(n.data[16:24])))
-}
-
-func (n *BTreeNode) SetNextPageID(pid storage.PageID) {
-	binary.BigEndian.PutUint64(n.data[16:24], uint64(pid))
-}
-
-func (n *BTreeNode) IsLeaf() bool {
-	return n.GetNodeType() == NodeTypeLeaf
-}
-
-func (n *BTreeNode) GetKey(i int) int64 {
-	offset := n.getKeyOffset(i)
-	return int64(binary.BigEndian.Uint64(n.data[offset : offset+8]))
-}
-
-func (n *BTreeNode) SetKey(i int, key int64) {
-	offset := n.getKeyOffset(i)
-	binary.BigEndian.PutUint64(n.data[offset:offset+8], uint64(key))
-}
-
-func (n *BTreeNode) GetValuePageID(i int) storage.PageID {
-	offset := n.getValueOffset(i)
-	return storage.PageID(int64(binary.BigEndian.Uint64(n.data[offset : offset+8])))
-}
-
-func (n *BTreeNode) SetValuePageID(i int, val storage.PageID) {
-	offset := n.getValueOffset(i)
-	binary.BigEndian.PutUint64(n.data[offset:offset+8], uint64(val))
-}
-
-func (n *BTreeNode) GetValueRID(i int) storage.RID {
-	offset := n.getValueOffset(i)
-	pid := storage.PageID(int64(binary.BigEndian.Uint64(n.data[offset : offset+8])))
-	sid := binary.BigEndian.Uint32(n.data[offset+8 : offset+12])
-	return storage.RID{PageID: pid, SlotID: sid}
-}
-
-func (n *BTreeNode) SetValueRID(i int, val storage.RID) {
-	offset := n.getValueOffset(i)
-	binary.BigEndian.PutUint64(n.data[offset:offset+8], uint64(val.PageID))
-	binary.BigEndian.PutUint32(n.data[offset+8:offset+12], val.SlotID)
-}
-
-func (n *BTreeNode) getKeyOffset(i int) int {
-	pairSize := 16
-	if n.IsLeaf() {
-		pairSize = 20
-	}
-	return HeaderSize + i*pairSize
-}
-
-func (n *BTreeNode) getValueOffset(i int) int {
-	return n.getKeyOffset(i) + 8
-}
-
-// MaxCapacity returns the maximum number of key-value pairs.
-func (n *BTreeNode) MaxCapacity() int {
-	pairSize := 16
-	if n.IsLeaf() {
-		pairSize = 20
-	}
-	return (storage.PageSize - HeaderSize) / pairSize
-}
-
-// InsertLeaf inserts a key/RID pair into a leaf node.
-func (n *BTreeNode) InsertLeaf(key int64, rid storage.RID) bool {
-	num := int(n.GetNumKeys())
-	if num >= n.MaxCapacity() {
-		return false
-	}
-
-	idx := sort.Search(num, func(i int) bool {
-		return n.GetKey(i) >= key
-	})
-
-	pairSize := 20
-	src := HeaderSize + idx*pairSize
-	dest := src + pairSize
-	count := (num - idx) * pairSize
-	copy(n.data[dest:dest+count], n.data[src:src+count])
-
-	n.SetKey(idx, key)
-	n.SetValueRID(idx, rid)
-	n.SetNumKeys(uint32(num + 1))
-	return true
-}
-
-// InsertInternal inserts a key/child pair into an internal node.
-func (n *BTreeNode) InsertInternal(key int64, val storage.PageID) bool {
-	num := int(n.GetNumKeys())
-	if num >= n.MaxCapacity() {
-		return false
-	}
-
-	idx := sort.Search(num, func(i int) bool {
-		return n.GetKey(i) >= key
-	})
-
-	pairSize := 16
-	src := HeaderSize + idx*pairSize
-	dest := src + pairSize
-	count := (num - idx) * pairSize
-	copy(n.data[dest:dest+count], n.data[src:src+count])
-
-	n.SetKey(idx, key)
-	n.SetValuePageID(idx, val)
-	n.SetNumKeys(uint32(num + 1))
-	return true
-}
-
-// SplitLeaf moves half of the items to the recipient node.
-func (n *BTreeNode) SplitLeaf(recipient *BTreeNode, recipientPageID storage.PageID) int64 {
-	total := int(n.GetNumKeys())
-	splitIdx := total / 2
-	moveCount := total - splitIdx
-
-	recipient.Init(NodeTypeLeaf)
-
-	pairSize := 20
-	startOffset := n.getKeyOffset(splitIdx)
-	dataLen := moveCount * pairSize
-
-	copy(recipient.data[HeaderSize:HeaderSize+dataLen], n.data[startOffset:startOffset+dataLen])
-	recipient.SetNumKeys(uint32(moveCount))
-
-	n.SetNumKeys(uint32(splitIdx))
-
-	recipient.SetNextPageID(n.GetNextPageID())
-	n.SetNextPageID(recipientPageID)
-
-	return recipient.GetKey(0)
-}
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+const (
+	NodeTypeInternal = 1
+	NodeTypeLeaf     = 2
+)
+
+// BTreeNode Layout:
+// Header (28 bytes):
+//   [0-3]:   NodeType (uint32)
+//   [4-7]:   NumKeys (uint32)
+//   [8-15]:  ParentPageID (int64)
+//   [16-23]: NextPageID (int64, for leaf linking)
+//   [24-25]: CellStart (uint16, lowest offset used by cells)
+//   [26-27]: Reserved
+//
+// Body:
+//   Slot array growing up from the header: CellOffset(2) per pair, in
+//   key order.
+//   Cells growing down from the end of the page, in any order:
+//     Internal: KeyLen(2), Key, ChildPageID(8)
+//     Leaf:     KeyLen(2), Key, RID(12)
+// Removing a pair leaves a hole in the cell area that is reclaimed by
+// compacting the page when an insert runs out of contiguous space.
+
+const (
+	HeaderSize = 28
+
+	offsetCellStart = 24
+	slotSize        = 2
+	keyLenSize      = 2
+	childSize       = 8
+	ridSize         = 12
+)
+
+// MaxKeySize is the longest key a node accepts. It guarantees at least
+// four pairs per page, so either half of a split has room for the key
+// that caused it.
+const MaxKeySize = (storage.PageSize-HeaderSize)/4 - slotSize - keyLenSize - ridSize
+
+// BTreeNode wraps a page to provide B-Tree node operations.
+type BTreeNode struct {
+	data []byte
+}
+
+// NewBTreeNode creates a B-Tree node view over a page.
+func NewBTreeNode(page *storage.Page) *BTreeNode {
+	return &BTreeNode{data: page.GetData()}
+}
+
+// Init initializes the node with the given type.
+func (n *BTreeNode) Init(nodeType uint32) {
+	n.SetNodeType(nodeType)
+	n.SetNumKeys(0)
+	n.SetParentPageID(-1)
+	n.SetNextPageID(-1)
+	n.setCellStart(storage.PageSize)
+}
+
+func (n *BTreeNode) GetNodeType() uint32 {
+	return binary.BigEndian.Uint32(n.data[0:4])
+}
+
+func (n *BTreeNode) SetNodeType(t uint32) {
+	binary.BigEndian.PutUint32(n.data[0:4], t)
+}
+
+func (n *BTreeNode) GetNumKeys() uint32 {
+	return binary.BigEndian.Uint32(n.data[4:8])
+}
+
+func (n *BTreeNode) SetNumKeys(num uint32) {
+	binary.BigEndian.PutUint32(n.data[4:8], num)
+}
+
+func (n *BTreeNode) GetParentPageID() storage.PageID {
+	return storage.PageID(int64(binary.BigEndian.Uint64(n.data[8:16])))
+}
+
+func (n *BTreeNode) SetParentPageID(pid storage.PageID) {
+	binary.BigEndian.PutUint64(n.data[8:16], uint64(pid))
+}
+
+func (n *BTreeNode) GetNextPageID() storage.PageID {
+	return storage.PageID(int64(binary.BigEndian.Uint64(n.data[16:24])))
+}
+
+func (n *BTreeNode) SetNextPageID(pid storage.PageID) {
+	binary.BigEndian.PutUint64(n.data[16:24], uint64(pid))
+}
+
+func (n *BTreeNode) IsLeaf() bool {
+	return n.GetNodeType() == NodeTypeLeaf
+}
+
+func (n *BTreeNode) getCellStart() int {
+	return int(binary.BigEndian.Uint16(n.data[offsetCellStart:]))
+}
+
+func (n *BTreeNode) setCellStart(offset int) {
+	binary.BigEndian.PutUint16(n.data[offsetCellStart:], uint16(offset))
+}
+
+func (n *BTreeNode) getCellOffset(i int) int {
+	at := HeaderSize + i*slotSize
+	return int(binary.BigEndian.Uint16(n.data[at : at+slotSize]))
+}
+
+func (n *BTreeNode) setCellOffset(i int, offset int) {
+	at := HeaderSize + i*slotSize
+	binary.BigEndian.PutUint16(n.data[at:at+slotSize], uint16(offset))
+}
+
+// GetKey returns the key of pair i. The slice aliases the page and is
+// only valid until the node is modified or its page unpinned.
+func (n *BTreeNode) GetKey(i int) []byte {
+	offset := n.getCellOffset(i)
+	keyLen := int(binary.BigEndian.Uint16(n.data[offset : offset+keyLenSize]))
+	start := offset + keyLenSize
+	return n.data[start : start+keyLen]
+}
+
+// getValueOffset returns where pair i's value starts, right after its key.
+func (n *BTreeNode) getValueOffset(i int) int {
+	offset := n.getCellOffset(i)
+	return offset + keyLenSize + int(binary.BigEndian.Uint16(n.data[offset:offset+keyLenSize]))
+}
+
+func (n *BTreeNode) GetValuePageID(i int) storage.PageID {
+	offset := n.getValueOffset(i)
+	return storage.PageID(int64(binary.BigEndian.Uint64(n.data[offset : offset+8])))
+}
+
+func (n *BTreeNode) SetValuePageID(i int, val storage.PageID) {
+	offset := n.getValueOffset(i)
+	binary.BigEndian.PutUint64(n.data[offset:offset+8], uint64(val))
+}
+
+func (n *BTreeNode) GetValueRID(i int) storage.RID {
+	offset := n.getValueOffset(i)
+	pid := storage.PageID(int64(binary.BigEndian.Uint64(n.data[offset : offset+8])))
+	sid := binary.BigEndian.Uint32(n.data[offset+8 : offset+12])
+	return storage.RID{PageID: pid, SlotID: sid}
+}
+
+func (n *BTreeNode) valueSize() int {
+	if n.IsLeaf() {
+		return ridSize
+	}
+	return childSize
+}
+
+// pairSize returns the bytes pair i occupies: its slot and its cell.
+func (n *BTreeNode) pairSize(i int) int {
+	return slotSize + keyLenSize + len(n.GetKey(i)) + n.valueSize()
+}
+
+// Capacity returns the bytes available to pairs in a node.
+func (n *BTreeNode) Capacity() int {
+	return storage.PageSize - HeaderSize
+}
+
+// UsedBytes returns the bytes taken by the node's pairs, not counting
+// holes left by removed pairs.
+func (n *BTreeNode) UsedBytes() int {
+	used := 0
+	for i := 0; i < int(n.GetNumKeys()); i++ {
+		used += n.pairSize(i)
+	}
+	return used
+}
+
+// Underfull reports whether a non-root node is less than half full and
+// should borrow from or merge with a sibling.
+func (n *BTreeNode) Underfull() bool {
+	return n.UsedBytes() < n.Capacity()/2
+}
+
+// LowerBound returns the index of the first key >= key, or NumKeys if
+// every key is smaller.
+func (n *BTreeNode) LowerBound(key []byte) int {
+	return sort.Search(int(n.GetNumKeys()), func(i int) bool {
+		return bytes.Compare(n.GetKey(i), key) >= 0
+	})
+}
+
+// UpperBound returns the index of the first key > key, or NumKeys if
+// no key is larger.
+func (n *BTreeNode) UpperBound(key []byte) int {
+	return sort.Search(int(n.GetNumKeys()), func(i int) bool {
+		return bytes.Compare(n.GetKey(i), key) > 0
+	})
+}
+
+// ChildIndex returns the pair of an internal node to follow for key:
+// the last pair whose key is <= key, since each key is the lower bound
+// of its child. Keys below the first pair fall to the first child.
+func (n *BTreeNode) ChildIndex(key []byte) int {
+	return max(n.UpperBound(key)-1, 0)
+}
+
+// insertCell inserts a pair at index idx, compacting the page if the
+// contiguous free space is too small. It returns false if the pair does
+// not fit.
+func (n *BTreeNode) insertCell(idx int, key, value []byte) bool {
+	num := int(n.GetNumKeys())
+	cellLen := keyLenSize + len(key) + len(value)
+
+	slotsEnd := HeaderSize + (num+1)*slotSize
+	if n.getCellStart()-cellLen < slotsEnd {
+		if n.UsedBytes()+slotSize+cellLen > n.Capacity() {
+			return false
+		}
+		n.compact()
+	}
+
+	offset := n.getCellStart() - cellLen
+	binary.BigEndian.PutUint16(n.data[offset:], uint16(len(key)))
+	copy(n.data[offset+keyLenSize:], key)
+	copy(n.data[offset+keyLenSize+len(key):], value)
+	n.setCellStart(offset)
+
+	at := HeaderSize + idx*slotSize
+	end := HeaderSize + num*slotSize
+	copy(n.data[at+slotSize:end+slotSize], n.data[at:end])
+	n.setCellOffset(idx, offset)
+	n.SetNumKeys(uint32(num + 1))
+	return true
+}
+
+// compact rewrites the cells contiguously at the end of the page,
+// reclaiming the holes left by removed pairs.
+func (n *BTreeNode) compact() {
+	num := int(n.GetNumKeys())
+	cells := make([][]byte, num)
+	for i := range cells {
+		offset := n.getCellOffset(i)
+		end := n.getValueOffset(i) + n.valueSize()
+		cells[i] = append([]byte(nil), n.data[offset:end]...)
+	}
+
+	offset := storage.PageSize
+	for i, cell := range cells {
+		offset -= len(cell)
+		copy(n.data[offset:], cell)
+		n.setCellOffset(i, offset)
+	}
+	n.setCellStart(offset)
+}
+
+// InsertLeaf inserts a key/RID pair into a leaf node.
+func (n *BTreeNode) InsertLeaf(key []byte, rid storage.RID) bool {
+	var value [ridSize]byte
+	binary.BigEndian.PutUint64(value[0:8], uint64(rid.PageID))
+	binary.BigEndian.PutUint32(value[8:12], rid.SlotID)
+	return n.insertCell(n.LowerBound(key), key, value[:])
+}
+
+// InsertInternal inserts a key/child pair into an internal node.
+func (n *BTreeNode) InsertInternal(key []byte, val storage.PageID) bool {
+	var value [childSize]byte
+	binary.BigEndian.PutUint64(value[:], uint64(val))
+	return n.insertCell(n.LowerBound(key), key, value[:])
+}
+
+// InsertPairAt inserts a key/value pair copied from a node of the same
+// type at index idx. It returns false if the pair does not fit.
+func (n *BTreeNode) InsertPairAt(idx int, src *BTreeNode, srcIdx int) bool {
+	valueAt := src.getValueOffset(srcIdx)
+	value := src.data[valueAt : valueAt+src.valueSize()]
+	return n.insertCell(idx, src.GetKey(srcIdx), value)
+}
+
+// RemoveAt removes the pair at index idx.
+func (n *BTreeNode) RemoveAt(idx int) {
+	num := int(n.GetNumKeys())
+	if n.getCellOffset(idx) == n.getCellStart() {
+		// A cell at the edge of the cell area is reclaimed directly.
+		n.setCellStart(n.getValueOffset(idx) + n.valueSize())
+	}
+
+	at := HeaderSize + idx*slotSize
+	end := HeaderSize + num*slotSize
+	copy(n.data[at:end-slotSize], n.data[at+slotSize:end])
+	n.SetNumKeys(uint32(num - 1))
+	if num == 1 {
+		n.setCellStart(storage.PageSize)
+	}
+}
+
+// SetKey replaces the key of pair i, keeping its value. It returns
+// false, leaving the node unchanged, if the new key does not fit.
+func (n *BTreeNode) SetKey(i int, key []byte) bool {
+	if n.UsedBytes()-len(n.GetKey(i))+len(key) > n.Capacity() {
+		return false
+	}
+	valueAt := n.getValueOffset(i)
+	value := append([]byte(nil), n.data[valueAt:valueAt+n.valueSize()]...)
+	key = append([]byte(nil), key...)
+	n.RemoveAt(i)
+	return n.insertCell(i, key, value)
+}
+
+// AppendPairs moves every pair of src, a node of the same type, to the
+// end of n. The caller checks that they fit.
+func (n *BTreeNode) AppendPairs(src *BTreeNode) {
+	for i := 0; i < int(src.GetNumKeys()); i++ {
+		n.InsertPairAt(int(n.GetNumKeys()), src, i)
+	}
+	src.SetNumKeys(0)
+	src.setCellStart(storage.PageSize)
+}
+
+// splitIndex returns the first pair to move to a new right sibling so
+// that both halves hold about the same number of bytes.
+func (n *BTreeNode) splitIndex() int {
+	num := int(n.GetNumKeys())
+	half := n.UsedBytes() / 2
+	used := 0
+	for i := 0; i < num; i++ {
+		used += n.pairSize(i)
+		if used > half {
+			return max(min(i, num-1), 1)
+		}
+	}
+	return num / 2
+}
+
+// moveUpperHalf moves the pairs from index from onwards to recipient.
+func (n *BTreeNode) moveUpperHalf(recipient *BTreeNode, from int) {
+	num := int(n.GetNumKeys())
+	for i := from; i < num; i++ {
+		recipient.InsertPairAt(i-from, n, i)
+	}
+	n.SetNumKeys(uint32(from))
+	n.compact()
+}
+
+// SplitInternal moves the upper half of the pairs to the recipient node
+// and returns a copy of the recipient's first key, which separates the
+// two nodes in their parent.
+func (n *BTreeNode) SplitInternal(recipient *BTreeNode) []byte {
+	recipient.Init(NodeTypeInternal)
+	recipient.SetParentPageID(n.GetParentPageID())
+	n.moveUpperHalf(recipient, n.splitIndex())
+	return append([]byte(nil), recipient.GetKey(0)...)
+}
+
+// SplitLeaf moves half of the items to the recipient node and returns a
+// copy of the recipient's first key.
+func (n *BTreeNode) SplitLeaf(recipient *BTreeNode, recipientPageID storage.PageID) []byte {
+	recipient.Init(NodeTypeLeaf)
+	n.moveUpperHalf(recipient, n.splitIndex())
+
+	recipient.SetNextPageID(n.GetNextPageID())
+	n.SetNextPageID(recipientPageID)
+
+	return append([]byte(nil), recipient.GetKey(0)...)
+}
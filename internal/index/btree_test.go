@@ -0,0 +1,462 @@
+package index_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+func newTestTree(t testing.TB) (*index.BTreeIndex, *storage.BufferPool) {
+	bt, bp, _ := newTestTreeWithDisk(t)
+	return bt, bp
+}
+
+func newTestTreeWithDisk(t testing.TB) (*index.BTreeIndex, *storage.BufferPool, *storage.DiskManager) {
+	t.Helper()
+	dm, err := storage.NewDiskManager(filepath.Join(t.TempDir(), "btree.db"))
+	if err != nil {
+		t.Fatalf("NewDiskManager failed: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	bp := storage.NewBufferPool(256, dm)
+	if _, ok := t.(*testing.T); ok {
+		// Tracking slows every fetch, so benchmarks go without it.
+		bp.TrackPins(true)
+		t.Cleanup(func() {
+			if err := bp.LeakedPins(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	bt, err := index.NewBTreeIndex(bp, storage.InvalidPageID)
+	if err != nil {
+		t.Fatalf("NewBTreeIndex failed: %v", err)
+	}
+	return bt, bp, dm
+}
+
+func ridFor(key int64) storage.RID {
+	return storage.RID{PageID: storage.PageID(key / 100), SlotID: uint32(key % 100)}
+}
+
+// keyFor encodes an integer as an index key.
+func keyFor(key int64) []byte {
+	encoded, err := index.EncodeKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return encoded
+}
+
+// keyInt decodes a key made by keyFor.
+func keyInt(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[1:]) ^ 1<<63)
+}
+
+// checkTree verifies that every key in keys is found with its RID and
+// that the tree is well formed, as described for checkNodes.
+func checkTree(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool, keys []int64) {
+	t.Helper()
+	for _, key := range keys {
+		rid, err := bt.Search(keyFor(key))
+		if err != nil {
+			t.Fatalf("Search(%d) failed: %v", key, err)
+		}
+		if rid != ridFor(key) {
+			t.Fatalf("Search(%d): expected %v, got %v", key, ridFor(key), rid)
+		}
+	}
+
+	leafKeys, leafDepth := checkNodes(t, bt, bp)
+	stats, err := bt.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if stats.Entries != leafKeys || stats.Depth != leafDepth {
+		t.Errorf("Verify found %d entries at depth %d, expected %d at depth %d", stats.Entries, stats.Depth, leafKeys, leafDepth)
+	}
+	if leafKeys != len(keys) {
+		t.Errorf("expected %d keys in leaves, got %d", len(keys), leafKeys)
+	}
+	if leafDepth < 3 && len(keys) > 100000 {
+		t.Errorf("expected at least 3 levels for %d keys, got %d", len(keys), leafDepth)
+	}
+}
+
+// checkNodes walks the tree checking that keys are ordered and within
+// their parent's bounds, non-root nodes are at least a third full, all
+// leaves are at the same depth and each node's ParentPageID names its
+// parent. It returns the number of keys in the leaves and their depth.
+func checkNodes(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) (leafKeys, leafDepth int) {
+	t.Helper()
+	leafDepth = -1
+	// A nil hi bound is unbounded; the root's lo is the empty sentinel.
+	var walk func(pageID, parentID storage.PageID, lo, hi []byte, depth int)
+	walk = func(pageID, parentID storage.PageID, lo, hi []byte, depth int) {
+		page, err := bp.FetchPage(pageID)
+		if err != nil {
+			t.Fatalf("FetchPage(%d) failed: %v", pageID, err)
+		}
+		node := index.NewBTreeNode(page)
+		defer bp.UnpinPage(pageID, false)
+
+		if got := node.GetParentPageID(); got != parentID {
+			t.Fatalf("page %d: expected parent %d, got %d", pageID, parentID, got)
+		}
+		count := int(node.GetNumKeys())
+		// Redistribution evens siblings out to within a pair, so a node
+		// can end up a little under half full.
+		if parentID != storage.InvalidPageID && node.UsedBytes()*3 < node.Capacity() {
+			t.Fatalf("page %d: %d of %d bytes used", pageID, node.UsedBytes(), node.Capacity())
+		}
+		for i := 0; i < count; i++ {
+			k := node.GetKey(i)
+			if (i > 0 && bytes.Compare(k, node.GetKey(i-1)) <= 0) || bytes.Compare(k, lo) < 0 ||
+				(hi != nil && bytes.Compare(k, hi) >= 0) {
+				t.Fatalf("page %d: key %x out of order or outside [%x, %x)", pageID, k, lo, hi)
+			}
+		}
+
+		if node.IsLeaf() {
+			if leafDepth >= 0 && depth != leafDepth {
+				t.Fatalf("page %d: leaf at depth %d, expected %d", pageID, depth, leafDepth)
+			}
+			leafDepth = depth
+			leafKeys += count
+			return
+		}
+		for i := 0; i < count; i++ {
+			childHi := hi
+			if i+1 < count {
+				childHi = bytes.Clone(node.GetKey(i + 1))
+			}
+			walk(node.GetValuePageID(i), pageID, bytes.Clone(node.GetKey(i)), childHi, depth+1)
+		}
+	}
+	walk(bt.RootPageID(), storage.InvalidPageID, nil, nil, 1)
+	return leafKeys, leafDepth
+}
+
+func TestBTreeSequentialInserts(t *testing.T) {
+	n := 2000000
+	if testing.Short() {
+		n = 100000
+	}
+	bt, bp := newTestTree(t)
+	// Recording a stack for every pin would triple the time of this
+	// test; the smaller tests cover the same paths for leaks.
+	bp.TrackPins(false)
+
+	keys := make([]int64, n)
+	for i := range keys {
+		keys[i] = int64(i)
+		if err := bt.Insert(keyFor(keys[i]), ridFor(keys[i])); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", keys[i], err)
+		}
+	}
+	checkTree(t, bt, bp, keys)
+
+	if _, err := bt.Search(keyFor(int64(n))); err == nil {
+		t.Errorf("expected missing key to fail")
+	}
+}
+
+func TestBTreeRandomInserts(t *testing.T) {
+	n := 2000000
+	if testing.Short() {
+		n = 100000
+	}
+	bt, bp := newTestTree(t)
+	// Recording a stack for every pin would triple the time of this
+	// test; the smaller tests cover the same paths for leaks.
+	bp.TrackPins(false)
+
+	rng := rand.New(rand.NewSource(1))
+	keys := make([]int64, n)
+	seen := make(map[int64]bool, n)
+	for i := range keys {
+		key := rng.Int63n(1 << 40)
+		for seen[key] {
+			key = rng.Int63n(1 << 40)
+		}
+		seen[key] = true
+		keys[i] = key
+		if err := bt.Insert(keyFor(key), ridFor(key)); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", key, err)
+		}
+	}
+	checkTree(t, bt, bp, keys)
+}
+
+// TestBTreeDescendingInserts fills the tree from the right and reopens
+// it from its root page ID, as the catalog does on startup.
+func TestBTreeDescendingInserts(t *testing.T) {
+	bt, bp := newTestTree(t)
+	keys := make([]int64, 60000)
+	for i := range keys {
+		keys[i] = int64(len(keys) - i)
+		if err := bt.Insert(keyFor(keys[i]), ridFor(keys[i])); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", keys[i], err)
+		}
+	}
+	if err := bp.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := index.NewBTreeIndex(bp, bt.RootPageID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, reopened, bp, keys)
+}
+
+func TestBTreeDelete(t *testing.T) {
+	n := 200000
+	if testing.Short() {
+		n = 50000
+	}
+	bt, bp, dm := newTestTreeWithDisk(t)
+
+	rng := rand.New(rand.NewSource(2))
+	keys := rng.Perm(n)
+	for _, k := range keys {
+		if err := bt.Insert(keyFor(int64(k)), ridFor(int64(k))); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", k, err)
+		}
+	}
+	pagesBefore, err := dm.NumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete every other key in random order, then check the survivors.
+	var kept []int64
+	for _, k := range rng.Perm(n) {
+		if k%2 == 0 {
+			kept = append(kept, int64(k))
+			continue
+		}
+		if err := bt.Delete(keyFor(int64(k))); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", k, err)
+		}
+	}
+	checkTree(t, bt, bp, kept)
+	if _, err := bt.Search(keyFor(1)); err == nil {
+		t.Errorf("expected deleted key 1 to be gone")
+	}
+	if err := bt.Delete(keyFor(1)); err == nil {
+		t.Errorf("expected deleting a missing key to fail")
+	}
+	if len(dm.FreePages()) == 0 {
+		t.Errorf("expected merges to free pages")
+	}
+
+	// Delete the rest in ascending order, collapsing the tree to a single leaf.
+	for _, k := range kept {
+		if err := bt.Delete(keyFor(k)); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", k, err)
+		}
+	}
+	checkTree(t, bt, bp, nil)
+
+	// Refilling the tree reuses the freed pages instead of growing the file.
+	for _, k := range keys {
+		if err := bt.Insert(keyFor(int64(k)), ridFor(int64(k))); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", k, err)
+		}
+	}
+	pagesAfter, err := dm.NumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pagesAfter > pagesBefore {
+		t.Errorf("file grew from %d to %d pages despite freed pages", pagesBefore, pagesAfter)
+	}
+}
+
+func TestBTreeDeleteDescending(t *testing.T) {
+	bt, bp := newTestTree(t)
+	n := 100000
+	keys := make([]int64, n)
+	for i := range keys {
+		keys[i] = int64(i)
+		if err := bt.Insert(keyFor(keys[i]), ridFor(keys[i])); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", keys[i], err)
+		}
+	}
+	// Removing from the right end exercises borrowing from left siblings.
+	for len(keys) > n/4 {
+		last := keys[len(keys)-1]
+		if err := bt.Delete(keyFor(last)); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", last, err)
+		}
+		keys = keys[:len(keys)-1]
+	}
+	checkTree(t, bt, bp, keys)
+}
+
+func TestBTreeDrop(t *testing.T) {
+	bt, _, dm := newTestTreeWithDisk(t)
+	for k := int64(0); k < 50000; k++ {
+		if err := bt.Insert(keyFor(k), ridFor(k)); err != nil {
+			t.Fatalf("Insert(%d) failed: %v", k, err)
+		}
+	}
+	numPages, err := dm.NumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bt.Drop(); err != nil {
+		t.Fatalf("Drop failed: %v", err)
+	}
+	if free := len(dm.FreePages()); int64(free) != numPages {
+		t.Errorf("expected all %d pages to be freed, got %d", numPages, free)
+	}
+}
+
+func TestBTreeVariableLengthKeys(t *testing.T) {
+	bt, bp := newTestTree(t)
+
+	// Keys from a few bytes up to the maximum, so nodes hold anywhere
+	// from a handful of pairs to a few hundred.
+	rng := rand.New(rand.NewSource(4))
+	want := make(map[string]storage.RID)
+	var keys []string
+	for i := int64(0); len(want) < 20000; i++ {
+		size := 1 + rng.Intn(40)
+		if rng.Intn(50) == 0 {
+			size = index.MaxKeySize - 4
+		}
+		b := make([]byte, size)
+		rng.Read(b)
+		key, err := index.EncodeKey(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key) > index.MaxKeySize {
+			continue
+		}
+		if _, ok := want[string(key)]; ok {
+			continue
+		}
+		want[string(key)] = ridFor(i)
+		keys = append(keys, string(key))
+		if err := bt.Insert(key, ridFor(i)); err != nil {
+			t.Fatalf("Insert(%x) failed: %v", key, err)
+		}
+	}
+
+	// Delete half in a seeded order, so that a failure can be
+	// reproduced, then check what is left.
+	rng.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	for _, key := range keys[:len(keys)/2] {
+		if err := bt.Delete([]byte(key)); err != nil {
+			t.Fatalf("Delete(%x) failed: %v", key, err)
+		}
+		delete(want, key)
+	}
+	for key, rid := range want {
+		got, err := bt.Search([]byte(key))
+		if err != nil || got != rid {
+			t.Fatalf("Search(%x): expected %v, got %v, %v", key, rid, got, err)
+		}
+	}
+	if leafKeys, _ := checkNodes(t, bt, bp); leafKeys != len(want) {
+		t.Errorf("expected %d keys in leaves, got %d", len(want), leafKeys)
+	}
+
+	tooLong := make([]byte, index.MaxKeySize+1)
+	if err := bt.Insert(tooLong, storage.RID{}); err == nil {
+		t.Errorf("expected a key over MaxKeySize to be rejected")
+	}
+	if err := bt.Insert(nil, storage.RID{}); err == nil {
+		t.Errorf("expected an empty key to be rejected")
+	}
+}
+
+func TestBTreeDuplicateKeys(t *testing.T) {
+	bt, bp := newTestTree(t)
+
+	// Neighbouring string keys whose encodings share prefixes, each with
+	// enough RIDs to span several leaves.
+	var keys [][]byte
+	for _, s := range []string{"a", "a\x00", "ab", "b"} {
+		key, err := index.EncodeKey(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	want := make(map[string][]storage.RID)
+	rng := rand.New(rand.NewSource(5))
+	for _, i := range rng.Perm(4000) {
+		key := keys[i%len(keys)]
+		rid := ridFor(int64(i))
+		if err := bt.InsertEntry(key, rid); err != nil {
+			t.Fatalf("InsertEntry(%x, %v) failed: %v", key, rid, err)
+		}
+		want[string(key)] = append(want[string(key)], rid)
+	}
+
+	// Remove one specific pair and every RID of another key.
+	if err := bt.DeleteEntry(keys[0], ridFor(4)); err != nil {
+		t.Fatalf("DeleteEntry failed: %v", err)
+	}
+	rids := want[string(keys[0])]
+	for i, rid := range rids {
+		if rid == ridFor(4) {
+			want[string(keys[0])] = append(rids[:i:i], rids[i+1:]...)
+			break
+		}
+	}
+	if err := bt.DeleteEntry(keys[0], ridFor(4)); err == nil {
+		t.Errorf("expected deleting a removed pair to fail")
+	}
+	if err := bt.DeleteEntry(keys[1], ridFor(4)); err == nil {
+		t.Errorf("expected deleting a pair under the wrong key to fail")
+	}
+	for _, rid := range want[string(keys[2])] {
+		if err := bt.DeleteEntry(keys[2], rid); err != nil {
+			t.Fatalf("DeleteEntry(%v) failed: %v", rid, err)
+		}
+	}
+	want[string(keys[2])] = nil
+
+	for _, key := range keys {
+		expected := want[string(key)]
+		sort.Slice(expected, func(i, j int) bool {
+			a, b := expected[i], expected[j]
+			return a.PageID < b.PageID || (a.PageID == b.PageID && a.SlotID < b.SlotID)
+		})
+
+		var got []storage.RID
+		it := bt.SearchAll(key)
+		for {
+			entry, rid, ok, err := it.Next()
+			if err != nil {
+				t.Fatalf("SearchAll(%x) failed: %v", key, err)
+			}
+			if !ok {
+				break
+			}
+			if !bytes.Equal(entry, index.EntryKey(key, rid)) {
+				t.Fatalf("SearchAll(%x) returned entry %x for %v", key, entry, rid)
+			}
+			got = append(got, rid)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("SearchAll(%x): expected %d RIDs, got %d", key, len(expected), len(got))
+		}
+	}
+	if leafKeys, _ := checkNodes(t, bt, bp); leafKeys != 4000-1-1000 {
+		t.Errorf("expected %d entries, got %d", 4000-1-1000, leafKeys)
+	}
+}
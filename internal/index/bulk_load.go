@@ -0,0 +1,529 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// DefaultFillFactor is the fraction of each node a bulk load fills,
+// leaving room for later inserts before nodes split.
+const DefaultFillFactor = 0.9
+
+// DefaultSortMemory is how many bytes of pairs a BulkLoader sorts in
+// memory before spilling them to a temporary file.
+const DefaultSortMemory = 64 << 20
+
+// pairOverhead approximates the memory a buffered pair takes beyond its
+// key and RID.
+const pairOverhead = 32
+
+// DuplicateKeyError is returned by BulkLoader.Finish when the same key
+// was added twice to a unique index.
+type DuplicateKeyError struct {
+	Key []byte
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %x", e.Key)
+}
+
+// BulkLoader builds a B+Tree bottom-up from key/RID pairs added in any
+// order. Finish sorts the pairs, spilling sorted runs to temporary files
+// and merging them when they exceed SortMemory, then packs them into
+// leaves filled to the fill factor and builds each level of internal
+// nodes over the one below. This writes every page once, instead of
+// descending from the root and splitting half-full leaves per key.
+type BulkLoader struct {
+	// SortMemory and TempDir may be changed before the first Add. An
+	// empty TempDir means os.TempDir.
+	SortMemory int
+	TempDir    string
+
+	bt         *BTreeIndex
+	fillFactor float64
+	duplicates bool
+
+	pairs []bulkPair
+	size  int
+	runs  []*os.File
+}
+
+type bulkPair struct {
+	key []byte
+	rid storage.RID
+}
+
+// NewBulkLoader prepares to fill bt, which must be empty. fillFactor is
+// the fraction of each node to fill, from 0.5 to 1; nodes less than
+// half full would be merged by the first delete that reached them. If
+// duplicates is set, pairs are stored as InsertEntry would store them,
+// and the tree must be used as an index with duplicate keys.
+func NewBulkLoader(bt *BTreeIndex, fillFactor float64, duplicates bool) (*BulkLoader, error) {
+	if fillFactor < 0.5 || fillFactor > 1 {
+		return nil, fmt.Errorf("fill factor %g is outside [0.5, 1]", fillFactor)
+	}
+	guard, err := bt.bufferPool.FetchPageRead(bt.rootPageID)
+	if err != nil {
+		return nil, err
+	}
+	root := NewBTreeNode(guard.Page())
+	empty := root.IsLeaf() && root.GetNumKeys() == 0
+	guard.Drop()
+	if !empty {
+		return nil, fmt.Errorf("bulk load into a non-empty tree")
+	}
+
+	return &BulkLoader{
+		SortMemory: DefaultSortMemory,
+		bt:         bt,
+		fillFactor: fillFactor,
+		duplicates: duplicates,
+	}, nil
+}
+
+// Add queues a key/RID pair for the tree.
+func (l *BulkLoader) Add(key []byte, rid storage.RID) error {
+	limit := MaxKeySize
+	if l.duplicates {
+		limit = MaxDuplicateKeySize
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("empty key")
+	}
+	if len(key) > limit {
+		return fmt.Errorf("key of %d bytes exceeds the maximum of %d", len(key), limit)
+	}
+	if l.duplicates {
+		key = EntryKey(key, rid)
+	} else {
+		key = bytes.Clone(key)
+	}
+
+	l.pairs = append(l.pairs, bulkPair{key: key, rid: rid})
+	l.size += len(key) + ridSize + pairOverhead
+	if l.size > l.SortMemory {
+		return l.spill()
+	}
+	return nil
+}
+
+// Finish sorts the added pairs and builds the tree from them. It fails
+// with a DuplicateKeyError, leaving the tree empty, if a key was added
+// twice. The loader cannot be used afterwards.
+func (l *BulkLoader) Finish() error {
+	defer l.Close()
+
+	l.sortPairs()
+	var next func() (bulkPair, bool, error)
+	if len(l.runs) == 0 {
+		pos := 0
+		next = func() (bulkPair, bool, error) {
+			if pos == len(l.pairs) {
+				return bulkPair{}, false, nil
+			}
+			pos++
+			return l.pairs[pos-1], true, nil
+		}
+	} else {
+		if err := l.spill(); err != nil {
+			return err
+		}
+		m, err := newRunMerger(l.runs)
+		if err != nil {
+			return err
+		}
+		next = m.next
+	}
+	return l.build(next)
+}
+
+// Close removes the loader's temporary files. It is only needed when
+// Finish is not called.
+func (l *BulkLoader) Close() error {
+	var firstErr error
+	for _, run := range l.runs {
+		run.Close()
+		if err := os.Remove(run.Name()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	l.runs = nil
+	l.pairs = nil
+	return firstErr
+}
+
+func (l *BulkLoader) sortPairs() {
+	sort.Slice(l.pairs, func(i, j int) bool {
+		return bytes.Compare(l.pairs[i].key, l.pairs[j].key) < 0
+	})
+}
+
+// spill writes the buffered pairs to a new temporary file as a sorted
+// run of KeyLen(2), Key, RID(12) records.
+func (l *BulkLoader) spill() error {
+	l.sortPairs()
+	run, err := os.CreateTemp(l.TempDir, "btree-run-*")
+	if err != nil {
+		return err
+	}
+	l.runs = append(l.runs, run)
+
+	w := bufio.NewWriter(run)
+	var buf [keyLenSize + ridSize]byte
+	for _, p := range l.pairs {
+		binary.BigEndian.PutUint16(buf[:keyLenSize], uint16(len(p.key)))
+		if _, err := w.Write(buf[:keyLenSize]); err != nil {
+			return err
+		}
+		if _, err := w.Write(p.key); err != nil {
+			return err
+		}
+		putRID(buf[keyLenSize:], p.rid)
+		if _, err := w.Write(buf[keyLenSize:]); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if _, err := run.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	l.pairs = l.pairs[:0]
+	l.size = 0
+	return nil
+}
+
+// runReader reads back a run written by spill.
+type runReader struct {
+	r    *bufio.Reader
+	head bulkPair
+}
+
+// advance reads the run's next pair into head, returning false at the
+// end of the run.
+func (rr *runReader) advance() (bool, error) {
+	var buf [keyLenSize + ridSize]byte
+	if _, err := io.ReadFull(rr.r, buf[:keyLenSize]); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	key := make([]byte, binary.BigEndian.Uint16(buf[:keyLenSize]))
+	if _, err := io.ReadFull(rr.r, key); err != nil {
+		return false, err
+	}
+	if _, err := io.ReadFull(rr.r, buf[keyLenSize:]); err != nil {
+		return false, err
+	}
+	rr.head = bulkPair{key: key, rid: getRID(buf[keyLenSize:])}
+	return true, nil
+}
+
+// runMerger merges sorted runs into one sorted stream. It is a min-heap
+// of the runs ordered by their next pair.
+type runMerger []*runReader
+
+func newRunMerger(runs []*os.File) (*runMerger, error) {
+	m := make(runMerger, 0, len(runs))
+	for _, run := range runs {
+		rr := &runReader{r: bufio.NewReader(run)}
+		ok, err := rr.advance()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m = append(m, rr)
+		}
+	}
+	heap.Init(&m)
+	return &m, nil
+}
+
+func (m runMerger) Len() int           { return len(m) }
+func (m runMerger) Less(i, j int) bool { return bytes.Compare(m[i].head.key, m[j].head.key) < 0 }
+func (m runMerger) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m *runMerger) Push(x any)        { *m = append(*m, x.(*runReader)) }
+func (m *runMerger) Pop() any {
+	old := *m
+	rr := old[len(old)-1]
+	*m = old[:len(old)-1]
+	return rr
+}
+
+func (m *runMerger) next() (bulkPair, bool, error) {
+	if len(*m) == 0 {
+		return bulkPair{}, false, nil
+	}
+	rr := (*m)[0]
+	p := rr.head
+	ok, err := rr.advance()
+	if err != nil {
+		return bulkPair{}, false, err
+	}
+	if ok {
+		heap.Fix(m, 0)
+	} else {
+		heap.Pop(m)
+	}
+	return p, true, nil
+}
+
+// nodeRef is a node built by the loader and the smallest key under it,
+// which becomes its key in the parent.
+type nodeRef struct {
+	key    []byte
+	pageID storage.PageID
+}
+
+// build packs the sorted pairs from next into leaves, then builds
+// levels of internal nodes until one node, the new root, remains. On
+// error it frees the pages of every level built so far, leaving the
+// tree as it was.
+func (l *BulkLoader) build(next func() (bulkPair, bool, error)) error {
+	var levels []*levelBuilder
+	fail := func(err error) error {
+		for _, level := range levels {
+			level.abort()
+		}
+		return err
+	}
+
+	leaves := l.newLevel(NodeTypeLeaf)
+	levels = append(levels, leaves)
+	var prev []byte
+	var value [ridSize]byte
+	for {
+		p, ok, err := next()
+		if err != nil {
+			return fail(err)
+		}
+		if !ok {
+			break
+		}
+		if prev != nil && bytes.Equal(p.key, prev) {
+			return fail(&DuplicateKeyError{Key: p.key})
+		}
+		prev = p.key
+		putRID(value[:], p.rid)
+		if err := leaves.add(p.key, value[:]); err != nil {
+			return fail(err)
+		}
+	}
+
+	nodes, err := leaves.finish()
+	if err != nil {
+		return fail(err)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	for len(nodes) > 1 {
+		level := l.newLevel(NodeTypeInternal)
+		levels = append(levels, level)
+		var value [childSize]byte
+		for _, ref := range nodes {
+			binary.BigEndian.PutUint64(value[:], uint64(ref.pageID))
+			if err := level.add(ref.key, value[:]); err != nil {
+				return fail(err)
+			}
+		}
+		if nodes, err = level.finish(); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := l.bt.bufferPool.DeletePage(l.bt.rootPageID); err != nil {
+		return fail(err)
+	}
+	l.bt.rootPageID = nodes[0].pageID
+	return nil
+}
+
+// levelBuilder fills the nodes of one level of the tree from left to
+// right.
+type levelBuilder struct {
+	bp       *storage.BufferPool
+	nodeType uint32
+	target   int
+
+	nodes []nodeRef
+	guard storage.WritePageGuard
+	node  *BTreeNode
+	used  int
+}
+
+func (l *BulkLoader) newLevel(nodeType uint32) *levelBuilder {
+	return &levelBuilder{
+		bp:       l.bt.bufferPool,
+		nodeType: nodeType,
+		target:   int(l.fillFactor * float64(storage.PageSize-HeaderSize)),
+	}
+}
+
+// add appends a pair to the level, starting a new node once the current
+// one reaches the fill factor. Every node takes at least two pairs.
+func (lb *levelBuilder) add(key, value []byte) error {
+	size := slotSize + keyLenSize + len(key) + len(value)
+	if lb.node == nil || (lb.used+size > lb.target && lb.node.GetNumKeys() >= 2) ||
+		lb.used+size > lb.node.Capacity() {
+		if err := lb.startNode(); err != nil {
+			return err
+		}
+	}
+	if lb.node.GetNumKeys() == 0 {
+		lb.nodes[len(lb.nodes)-1].key = bytes.Clone(key)
+	}
+	lb.node.insertCell(int(lb.node.GetNumKeys()), key, value)
+	lb.used += size
+	return nil
+}
+
+func (lb *levelBuilder) startNode() error {
+	guard, err := lb.bp.NewPageGuarded()
+	if err != nil {
+		return err
+	}
+	node := NewBTreeNode(guard.Page())
+	node.Init(lb.nodeType)
+	if lb.node != nil {
+		if lb.node.IsLeaf() {
+			lb.node.SetNextPageID(guard.PageID())
+		}
+		lb.guard.Drop()
+	}
+	lb.guard, lb.node, lb.used = guard, node, 0
+	lb.nodes = append(lb.nodes, nodeRef{pageID: guard.PageID()})
+	return nil
+}
+
+// finish completes the level and returns its nodes. A last node left
+// less than half full is merged into its left neighbour if the two fit
+// in one page, as a delete would do, and otherwise takes pairs from it
+// until they are about even. The first node's key becomes the empty key, which
+// sorts before every other, as on the leftmost path of a tree built by
+// inserts. Internal nodes become the parents of their children.
+func (lb *levelBuilder) finish() ([]nodeRef, error) {
+	if lb.node == nil {
+		return nil, nil
+	}
+	lb.guard.Drop()
+	lb.node = nil
+
+	if len(lb.nodes) > 1 {
+		if err := lb.balanceLast(); err != nil {
+			return nil, err
+		}
+	}
+	lb.nodes[0].key = nil
+	if lb.nodeType == NodeTypeInternal {
+		for _, ref := range lb.nodes {
+			if err := lb.adoptChildren(ref.pageID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return lb.nodes, nil
+}
+
+func (lb *levelBuilder) balanceLast() error {
+	leftID := lb.nodes[len(lb.nodes)-2].pageID
+	rightID := lb.nodes[len(lb.nodes)-1].pageID
+	leftGuard, err := lb.bp.FetchPageWrite(leftID)
+	if err != nil {
+		return err
+	}
+	defer leftGuard.Drop()
+	rightGuard, err := lb.bp.FetchPageWrite(rightID)
+	if err != nil {
+		return err
+	}
+	defer rightGuard.Drop()
+	left, right := NewBTreeNode(leftGuard.Page()), NewBTreeNode(rightGuard.Page())
+
+	if !right.Underfull() {
+		return nil
+	}
+	leftUsed, rightUsed := left.UsedBytes(), right.UsedBytes()
+	if leftUsed+rightUsed <= left.Capacity() {
+		if left.IsLeaf() {
+			left.SetNextPageID(right.GetNextPageID())
+		}
+		left.AppendPairs(right)
+		rightGuard.Drop()
+		leftGuard.Drop()
+		lb.nodes = lb.nodes[:len(lb.nodes)-1]
+		return lb.bp.DeletePage(rightID)
+	}
+
+	for {
+		last := int(left.GetNumKeys()) - 1
+		size := left.pairSize(last)
+		if rightUsed+size > leftUsed-size {
+			break
+		}
+		right.InsertPairAt(0, left, last)
+		left.RemoveAt(last)
+		leftUsed -= size
+		rightUsed += size
+	}
+	lb.nodes[len(lb.nodes)-1].key = bytes.Clone(right.GetKey(0))
+	return nil
+}
+
+// adoptChildren records pageID as the parent of each of its children.
+func (lb *levelBuilder) adoptChildren(pageID storage.PageID) error {
+	guard, err := lb.bp.FetchPageRead(pageID)
+	if err != nil {
+		return err
+	}
+	node := NewBTreeNode(guard.Page())
+	children := make([]storage.PageID, node.GetNumKeys())
+	for i := range children {
+		children[i] = node.GetValuePageID(i)
+	}
+	guard.Drop()
+
+	for _, childID := range children {
+		child, err := lb.bp.FetchPageWrite(childID)
+		if err != nil {
+			return err
+		}
+		NewBTreeNode(child.Page()).SetParentPageID(pageID)
+		child.Drop()
+	}
+	return nil
+}
+
+// abort frees the pages of a level that will not be used.
+func (lb *levelBuilder) abort() {
+	if lb.node != nil {
+		lb.guard.Drop()
+		lb.node = nil
+	}
+	for _, ref := range lb.nodes {
+		lb.bp.DeletePage(ref.pageID)
+	}
+	lb.nodes = nil
+}
+
+func putRID(buf []byte, rid storage.RID) {
+	binary.BigEndian.PutUint64(buf[0:8], uint64(rid.PageID))
+	binary.BigEndian.PutUint32(buf[8:12], rid.SlotID)
+}
+
+func getRID(buf []byte) storage.RID {
+	return storage.RID{
+		PageID: storage.PageID(int64(binary.BigEndian.Uint64(buf[0:8]))),
+		SlotID: binary.BigEndian.Uint32(buf[8:12]),
+	}
+}
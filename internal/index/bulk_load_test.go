@@ -0,0 +1,283 @@
+package index_test
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// bulkLoad fills bt with keys, added in the given order.
+func bulkLoad(t *testing.T, bt *index.BTreeIndex, loader *index.BulkLoader, keys []int64) {
+	t.Helper()
+	for _, key := range keys {
+		if err := loader.Add(keyFor(key), ridFor(key)); err != nil {
+			t.Fatalf("Add(%d) failed: %v", key, err)
+		}
+	}
+	if err := loader.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+}
+
+// leafUsage returns the bytes used by each leaf, following the leaf
+// chain from the leftmost leaf.
+func leafUsage(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) []int {
+	t.Helper()
+	pageID := bt.RootPageID()
+	for {
+		page, err := bp.FetchPage(pageID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := index.NewBTreeNode(page)
+		bp.UnpinPage(pageID, false)
+		if node.IsLeaf() {
+			break
+		}
+		pageID = node.GetValuePageID(0)
+	}
+
+	var used []int
+	for pageID != storage.InvalidPageID {
+		page, err := bp.FetchPage(pageID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := index.NewBTreeNode(page)
+		used = append(used, node.UsedBytes())
+		bp.UnpinPage(pageID, false)
+		pageID = node.GetNextPageID()
+	}
+	return used
+}
+
+func TestBulkLoad(t *testing.T) {
+	n := 300000
+	if testing.Short() {
+		n = 50000
+	}
+	for _, fill := range []float64{0.5, 0.7, 1} {
+		for _, count := range []int{0, 1, 2, 500, n} {
+			bt, bp := newTestTree(t)
+			loader, err := index.NewBulkLoader(bt, fill, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := make([]int64, count)
+			for i, k := range rand.New(rand.NewSource(int64(count))).Perm(count) {
+				keys[i] = int64(k)
+			}
+			bulkLoad(t, bt, loader, keys)
+			checkTree(t, bt, bp, keys)
+
+			// Every leaf but the last two is packed to the fill factor,
+			// give or take a pair.
+			used := leafUsage(t, bt, bp)
+			target := int(fill * float64(storage.PageSize-index.HeaderSize))
+			for i := 0; i+2 < len(used); i++ {
+				if used[i] > target || used[i] < target-32 {
+					t.Fatalf("fill %g, %d keys: leaf %d uses %d bytes, expected about %d", fill, count, i, used[i], target)
+				}
+			}
+
+			// The tree takes ordinary inserts and deletes afterwards.
+			for k := int64(count); k < int64(count)+2000; k++ {
+				if err := bt.Insert(keyFor(k), ridFor(k)); err != nil {
+					t.Fatalf("Insert(%d) failed: %v", k, err)
+				}
+				keys = append(keys, k)
+			}
+			for _, k := range keys[:len(keys)/2] {
+				if err := bt.Delete(keyFor(k)); err != nil {
+					t.Fatalf("Delete(%d) failed: %v", k, err)
+				}
+			}
+			checkTree(t, bt, bp, keys[len(keys)/2:])
+		}
+	}
+}
+
+func TestBulkLoadExternalSort(t *testing.T) {
+	bt, bp := newTestTree(t)
+	tempDir := t.TempDir()
+	loader, err := index.NewBulkLoader(bt, index.DefaultFillFactor, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader.SortMemory = 64 << 10
+	loader.TempDir = tempDir
+
+	keys := make([]int64, 100000)
+	for i, k := range rand.New(rand.NewSource(1)).Perm(len(keys)) {
+		keys[i] = int64(k)
+	}
+	for i, key := range keys {
+		if err := loader.Add(keyFor(key), ridFor(key)); err != nil {
+			t.Fatalf("Add(%d) failed: %v", key, err)
+		}
+		if i == len(keys)/2 {
+			if runs, _ := os.ReadDir(tempDir); len(runs) < 10 {
+				t.Fatalf("expected sorted runs to be spilled, found %d files", len(runs))
+			}
+		}
+	}
+	if err := loader.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	checkTree(t, bt, bp, keys)
+
+	if runs, _ := os.ReadDir(tempDir); len(runs) != 0 {
+		t.Errorf("expected temporary runs to be removed, found %d", len(runs))
+	}
+}
+
+func TestBulkLoadDuplicateKey(t *testing.T) {
+	for _, sortMemory := range []int{index.DefaultSortMemory, 4 << 10} {
+		bt, bp := newTestTree(t)
+		tempDir := t.TempDir()
+		loader, err := index.NewBulkLoader(bt, index.DefaultFillFactor, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loader.SortMemory = sortMemory
+		loader.TempDir = tempDir
+		for k := int64(0); k < 5000; k++ {
+			if err := loader.Add(keyFor(k), ridFor(k)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := loader.Add(keyFor(1234), ridFor(1)); err != nil {
+			t.Fatal(err)
+		}
+
+		var dup *index.DuplicateKeyError
+		if err := loader.Finish(); !errors.As(err, &dup) || keyInt(dup.Key) != 1234 {
+			t.Fatalf("expected a duplicate of key 1234, got %v", err)
+		}
+		// The tree is left empty and usable.
+		checkTree(t, bt, bp, nil)
+		if err := bt.Insert(keyFor(1), ridFor(1)); err != nil {
+			t.Fatal(err)
+		}
+		if runs, _ := os.ReadDir(tempDir); len(runs) != 0 {
+			t.Errorf("expected temporary runs to be removed, found %d", len(runs))
+		}
+	}
+}
+
+func TestBulkLoadFreesPagesOnError(t *testing.T) {
+	bt, bp, dm := newTestTreeWithDisk(t)
+	before, err := dm.NumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader, err := index.NewBulkLoader(bt, index.DefaultFillFactor, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := int64(0); k < 50000; k++ {
+		if err := loader.Add(keyFor(k), ridFor(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A pinned root cannot be freed, so Finish fails after building
+	// every level, leaves and internal nodes alike.
+	root := bt.RootPageID()
+	if _, err := bp.FetchPage(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Finish(); err == nil {
+		t.Fatalf("expected Finish to fail while the root is pinned")
+	}
+	bp.UnpinPage(root, false)
+
+	after, err := dm.NumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after-before < 100 {
+		t.Fatalf("expected the load to build a few hundred pages, got %d", after-before)
+	}
+	if free := len(dm.FreePages()); int64(free) != after-before {
+		t.Errorf("expected all %d pages built to be freed, got %d", after-before, free)
+	}
+	if bt.RootPageID() != root {
+		t.Errorf("expected root %d to stay, got %d", root, bt.RootPageID())
+	}
+	checkTree(t, bt, bp, nil)
+}
+
+func TestBulkLoadDuplicates(t *testing.T) {
+	bt, bp := newTestTree(t)
+	loader, err := index.NewBulkLoader(bt, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys 0 to 9, each under 500 RIDs.
+	for _, i := range rand.New(rand.NewSource(2)).Perm(5000) {
+		if err := loader.Add(keyFor(int64(i%10)), ridFor(int64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := loader.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if leafKeys, _ := checkNodes(t, bt, bp); leafKeys != 5000 {
+		t.Fatalf("expected 5000 entries, got %d", leafKeys)
+	}
+
+	for k := int64(0); k < 10; k++ {
+		it := bt.SearchAll(keyFor(k))
+		count := 0
+		for {
+			_, rid, ok, err := it.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				break
+			}
+			if want := ridFor(k + int64(count)*10); rid != want {
+				t.Fatalf("key %d: expected %v, got %v", k, want, rid)
+			}
+			count++
+		}
+		if count != 500 {
+			t.Errorf("key %d: expected 500 RIDs, got %d", k, count)
+		}
+	}
+}
+
+func TestBulkLoadRejects(t *testing.T) {
+	bt, _ := newTestTree(t)
+	for _, fill := range []float64{0, 0.4, 1.1} {
+		if _, err := index.NewBulkLoader(bt, fill, false); err == nil {
+			t.Errorf("expected fill factor %g to be rejected", fill)
+		}
+	}
+
+	if err := bt.Insert(keyFor(1), ridFor(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.NewBulkLoader(bt, 1, false); err == nil {
+		t.Errorf("expected a non-empty tree to be rejected")
+	}
+
+	empty, _ := newTestTree(t)
+	loader, err := index.NewBulkLoader(empty, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	if err := loader.Add(make([]byte, index.MaxDuplicateKeySize+1), storage.RID{}); err == nil {
+		t.Errorf("expected a key over MaxDuplicateKeySize to be rejected")
+	}
+	if err := loader.Add(nil, storage.RID{}); err == nil {
+		t.Errorf("expected an empty key to be rejected")
+	}
+}
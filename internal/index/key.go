@@ -0,0 +1,111 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/benkivuva/my-rdbms/internal/sql"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// Key Encoding:
+// A key is the concatenation of its encoded column values, so tuples of
+// columns compare column by column. Each value starts with a marker
+// byte that sorts NULL before every other value:
+//   NULL:                        0x00
+//   otherwise 0x01 followed by:
+//     INT, BIGINT, DATE,
+//     TIMESTAMP, DECIMAL:        int64 (8 bytes, big-endian, sign bit flipped)
+//     FLOAT, DOUBLE:             float64 bits (8 bytes), sign bit flipped
+//                                for positives and all bits for negatives
+//     BOOLEAN:                   1 byte
+//     VARCHAR, BLOB:             raw bytes with 0x00 escaped as 0x00 0xFF,
+//                                terminated by 0x00 0x01
+// Comparing two encoded keys with bytes.Compare orders them the same way
+// as comparing their values. DECIMAL keys compare correctly only when
+// they share a scale, which holds for values of one column.
+
+const (
+	markerNull  = 0x00
+	markerValue = 0x01
+)
+
+// MaxDuplicateKeySize is the longest key an index with duplicate keys
+// accepts, leaving room for the RID appended by EntryKey.
+const MaxDuplicateKeySize = MaxKeySize - ridSize
+
+// EncodeKey encodes values, each in its column's runtime representation
+// as described in the sql package, as an order-preserving index key.
+func EncodeKey(values ...interface{}) ([]byte, error) {
+	var key []byte
+	for _, v := range values {
+		if v == nil {
+			key = append(key, markerNull)
+			continue
+		}
+		key = append(key, markerValue)
+
+		switch v := v.(type) {
+		case int:
+			key = appendInt64(key, int64(v))
+		case int64:
+			key = appendInt64(key, v)
+		case sql.Date:
+			key = appendInt64(key, int64(v))
+		case sql.Timestamp:
+			key = appendInt64(key, int64(v))
+		case sql.Decimal:
+			key = appendInt64(key, v.Unscaled)
+		case float64:
+			if v == 0 {
+				v = 0 // -0 and +0 are equal
+			}
+			bits := math.Float64bits(v)
+			if bits&(1<<63) != 0 {
+				bits = ^bits
+			} else {
+				bits |= 1 << 63
+			}
+			key = binary.BigEndian.AppendUint64(key, bits)
+		case bool:
+			b := byte(0)
+			if v {
+				b = 1
+			}
+			key = append(key, b)
+		case string:
+			key = appendBytes(key, []byte(v))
+		case []byte:
+			key = appendBytes(key, v)
+		default:
+			return nil, fmt.Errorf("cannot index value %v of type %T", v, v)
+		}
+	}
+	return key, nil
+}
+
+func appendInt64(key []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(key, uint64(v)^(1<<63))
+}
+
+func appendBytes(key, b []byte) []byte {
+	for _, c := range b {
+		key = append(key, c)
+		if c == 0x00 {
+			key = append(key, 0xFF)
+		}
+	}
+	return append(key, 0x00, 0x01)
+}
+
+// EntryKey appends rid to key, making the entries of an index with
+// duplicate keys unique. Entries for one key sort together, by RID.
+// Keys made by EncodeKey for one index are never a prefix of each
+// other, so an entry's key is the entry without its last ridSize bytes.
+func EntryKey(key []byte, rid storage.RID) []byte {
+	entry := make([]byte, 0, len(key)+ridSize)
+	entry = append(entry, key...)
+	entry = binary.BigEndian.AppendUint64(entry, uint64(rid.PageID))
+	return binary.BigEndian.AppendUint32(entry, rid.SlotID)
+}
@@ -0,0 +1,62 @@
+package index_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+func TestEncodeKeyOrder(t *testing.T) {
+	// Each group lists keys of one column type in ascending order.
+	groups := map[string][][]interface{}{
+		"int": {
+			{nil}, {int64(math.MinInt64)}, {-1000}, {int64(-1)}, {0}, {1}, {int64(256)}, {int64(math.MaxInt64)},
+		},
+		"float": {
+			{nil}, {math.Inf(-1)}, {-1e300}, {-1.5}, {-1e-300}, {0.0}, {1e-300}, {2.5}, {1e300}, {math.Inf(1)},
+		},
+		"string": {
+			{nil}, {""}, {"\x00"}, {"\x00\x00"}, {"\x00a"}, {"\x01"}, {"a"}, {"a\x00"}, {"a\x00b"}, {"ab"}, {"b"}, {"\xff"},
+		},
+		"bool":    {{nil}, {false}, {true}},
+		"date":    {{nil}, {sql.Date(-1)}, {sql.Date(0)}, {sql.Date(19000)}},
+		"decimal": {{nil}, {sql.Decimal{Unscaled: -150, Scale: 2}}, {sql.Decimal{Unscaled: 5, Scale: 2}}},
+		// The first column's encoding must not let the second leak into
+		// the comparison.
+		"composite": {
+			{nil, nil}, {nil, ""}, {1, nil}, {1, "a"}, {1, "a\x00"}, {1, "b"}, {2, ""},
+		},
+	}
+	for name, keys := range groups {
+		var prev []byte
+		for i, values := range keys {
+			key, err := index.EncodeKey(values...)
+			if err != nil {
+				t.Fatalf("%s: EncodeKey(%v) failed: %v", name, values, err)
+			}
+			if i > 0 && bytes.Compare(prev, key) >= 0 {
+				t.Errorf("%s: expected %v to sort after %v", name, values, keys[i-1])
+			}
+			prev = key
+		}
+	}
+}
+
+func TestEncodeKeyEquality(t *testing.T) {
+	pos, _ := index.EncodeKey(0.0)
+	neg, _ := index.EncodeKey(math.Copysign(0, -1))
+	if !bytes.Equal(pos, neg) {
+		t.Errorf("expected -0 and +0 to encode alike, got %x and %x", neg, pos)
+	}
+	a, _ := index.EncodeKey(7)
+	b, _ := index.EncodeKey(int64(7))
+	if !bytes.Equal(a, b) {
+		t.Errorf("expected int and int64 to encode alike, got %x and %x", a, b)
+	}
+	if _, err := index.EncodeKey(struct{}{}); err == nil {
+		t.Errorf("expected an unsupported type to fail")
+	}
+}
@@ -0,0 +1,159 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// TreeStats summarizes a tree checked by Verify.
+type TreeStats struct {
+	Depth    int
+	Internal int
+	Leaves   int
+	Entries  int
+}
+
+// Verify walks the whole tree and checks that it is well formed: node
+// headers and cells lie within their pages, keys are strictly ordered
+// within each node and lie between the separators that lead to it, all
+// leaves are at the same depth, the leaf chain links the leaves left to
+// right, and every node records its parent. It returns the first
+// problem found.
+func (bt *BTreeIndex) Verify() (TreeStats, error) {
+	v := &verifier{
+		bt:       bt,
+		seen:     make(map[storage.PageID]bool),
+		depth:    -1,
+		lastLeaf: storage.InvalidPageID,
+	}
+	if err := v.walk(bt.rootPageID, storage.InvalidPageID, nil, nil, 1); err != nil {
+		return TreeStats{}, err
+	}
+	if v.lastNext != storage.InvalidPageID {
+		return TreeStats{}, fmt.Errorf("page %d: last leaf links to page %d", v.lastLeaf, v.lastNext)
+	}
+	v.stats.Depth = v.depth
+	return v.stats, nil
+}
+
+type verifier struct {
+	bt    *BTreeIndex
+	seen  map[storage.PageID]bool
+	depth int
+	stats TreeStats
+
+	// The previous leaf visited and the page it links to.
+	lastLeaf storage.PageID
+	lastNext storage.PageID
+}
+
+// walk checks the subtree at pageID, whose keys must lie in [lo, hi);
+// a nil hi is unbounded.
+func (v *verifier) walk(pageID, parentID storage.PageID, lo, hi []byte, depth int) error {
+	if v.seen[pageID] {
+		return fmt.Errorf("page %d: reached twice", pageID)
+	}
+	v.seen[pageID] = true
+
+	guard, err := v.bt.bufferPool.FetchPageRead(pageID)
+	if err != nil {
+		return fmt.Errorf("page %d: %w", pageID, err)
+	}
+	node := NewBTreeNode(guard.Page())
+	children, err := v.checkNode(node, pageID, parentID, lo, hi, depth)
+	// Copy the separators before the page is unpinned.
+	keys := make([][]byte, len(children))
+	for i := range children {
+		keys[i] = bytes.Clone(node.GetKey(i))
+	}
+	guard.Drop()
+	if err != nil {
+		return err
+	}
+
+	for i, childID := range children {
+		childHi := hi
+		if i+1 < len(children) {
+			childHi = keys[i+1]
+		}
+		if err := v.walk(childID, pageID, keys[i], childHi, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNode checks one node and returns its children, if it is an
+// internal node.
+func (v *verifier) checkNode(node *BTreeNode, pageID, parentID storage.PageID, lo, hi []byte, depth int) ([]storage.PageID, error) {
+	if got := node.GetParentPageID(); got != parentID {
+		return nil, fmt.Errorf("page %d: parent is %d, expected %d", pageID, got, parentID)
+	}
+	nodeType := node.GetNodeType()
+	if nodeType != NodeTypeLeaf && nodeType != NodeTypeInternal {
+		return nil, fmt.Errorf("page %d: unknown node type %d", pageID, nodeType)
+	}
+	if err := node.checkCells(); err != nil {
+		return nil, fmt.Errorf("page %d: %w", pageID, err)
+	}
+
+	count := int(node.GetNumKeys())
+	if count == 0 && !node.IsLeaf() {
+		return nil, fmt.Errorf("page %d: internal node without children", pageID)
+	}
+	for i := 0; i < count; i++ {
+		key := node.GetKey(i)
+		if i > 0 && bytes.Compare(key, node.GetKey(i-1)) <= 0 {
+			return nil, fmt.Errorf("page %d: key %d (%x) is not above key %d (%x)", pageID, i, key, i-1, node.GetKey(i-1))
+		}
+		if bytes.Compare(key, lo) < 0 || (hi != nil && bytes.Compare(key, hi) >= 0) {
+			return nil, fmt.Errorf("page %d: key %x lies outside its separators [%x, %x)", pageID, key, lo, hi)
+		}
+	}
+
+	if !node.IsLeaf() {
+		v.stats.Internal++
+		children := make([]storage.PageID, count)
+		for i := range children {
+			children[i] = node.GetValuePageID(i)
+		}
+		return children, nil
+	}
+
+	v.stats.Leaves++
+	v.stats.Entries += count
+	if v.depth >= 0 && depth != v.depth {
+		return nil, fmt.Errorf("page %d: leaf at depth %d, expected %d", pageID, depth, v.depth)
+	}
+	v.depth = depth
+	if v.stats.Leaves > 1 && v.lastNext != pageID {
+		return nil, fmt.Errorf("page %d: previous leaf %d links to page %d", pageID, v.lastLeaf, v.lastNext)
+	}
+	v.lastLeaf, v.lastNext = pageID, node.GetNextPageID()
+	return nil, nil
+}
+
+// checkCells checks that the slot array and every cell lie within the
+// page and do not overlap, so that the node's keys can be read safely.
+func (n *BTreeNode) checkCells() error {
+	count := int(n.GetNumKeys())
+	slotsEnd := HeaderSize + count*slotSize
+	cellStart := n.getCellStart()
+	if slotsEnd > cellStart || cellStart > storage.PageSize {
+		return fmt.Errorf("%d slots overlap cells starting at %d", count, cellStart)
+	}
+	for i := 0; i < count; i++ {
+		offset := n.getCellOffset(i)
+		if offset < cellStart || offset+keyLenSize > storage.PageSize {
+			return fmt.Errorf("cell %d at offset %d lies outside the cell area", i, offset)
+		}
+		keyLen := int(binary.BigEndian.Uint16(n.data[offset:]))
+		if keyLen > MaxKeySize || offset+keyLenSize+keyLen+n.valueSize() > storage.PageSize {
+			return fmt.Errorf("cell %d at offset %d with a %d-byte key runs past the page", i, offset, keyLen)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package index_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/index"
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// editNode applies edit to the node on pageID and writes it back.
+func editNode(t *testing.T, bp *storage.BufferPool, pageID storage.PageID, edit func(*index.BTreeNode)) {
+	t.Helper()
+	page, err := bp.FetchPage(pageID)
+	if err != nil {
+		t.Fatalf("FetchPage(%d) failed: %v", pageID, err)
+	}
+	edit(index.NewBTreeNode(page))
+	bp.UnpinPage(pageID, true)
+}
+
+// child returns the page of pair i of the internal node on pageID.
+func child(t *testing.T, bp *storage.BufferPool, pageID storage.PageID, i int) storage.PageID {
+	t.Helper()
+	var id storage.PageID
+	editNode(t, bp, pageID, func(n *index.BTreeNode) { id = n.GetValuePageID(i) })
+	return id
+}
+
+func TestBTreeVerify(t *testing.T) {
+	// Three levels: the root, internal nodes and leaves.
+	const count = 40000
+	cases := []struct {
+		name    string
+		corrupt func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool)
+		want    string
+	}{
+		{"intact", func(*testing.T, *index.BTreeIndex, *storage.BufferPool) {}, ""},
+		{"unordered keys", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			leaf := child(t, bp, child(t, bp, bt.RootPageID(), 1), 1)
+			editNode(t, bp, leaf, func(n *index.BTreeNode) { n.SetKey(1, n.GetKey(0)) })
+		}, "is not above key 0"},
+		{"key past separator", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			leaf := child(t, bp, child(t, bp, bt.RootPageID(), 0), 0)
+			editNode(t, bp, leaf, func(n *index.BTreeNode) { n.SetKey(int(n.GetNumKeys())-1, keyFor(count)) })
+		}, "outside its separators"},
+		{"separator past child", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			internal := child(t, bp, bt.RootPageID(), 0)
+			editNode(t, bp, internal, func(n *index.BTreeNode) {
+				n.SetKey(1, append(n.GetKey(1), 0))
+			})
+		}, "outside its separators"},
+		{"broken leaf chain", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			leaf := child(t, bp, child(t, bp, bt.RootPageID(), 0), 0)
+			editNode(t, bp, leaf, func(n *index.BTreeNode) { n.SetNextPageID(leaf) })
+		}, "links to page"},
+		{"last leaf linked", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			last := bt.RootPageID()
+			for i := 0; i < 2; i++ {
+				editNode(t, bp, last, func(n *index.BTreeNode) { last = n.GetValuePageID(int(n.GetNumKeys()) - 1) })
+			}
+			editNode(t, bp, last, func(n *index.BTreeNode) { n.SetNextPageID(bt.RootPageID()) })
+		}, "last leaf links"},
+		{"wrong parent", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			leaf := child(t, bp, child(t, bp, bt.RootPageID(), 1), 2)
+			editNode(t, bp, leaf, func(n *index.BTreeNode) { n.SetParentPageID(bt.RootPageID()) })
+		}, "parent is"},
+		{"shared child", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			internal := child(t, bp, bt.RootPageID(), 0)
+			first := child(t, bp, internal, 0)
+			editNode(t, bp, internal, func(n *index.BTreeNode) { n.SetValuePageID(1, first) })
+		}, "reached twice"},
+		{"internal node without children", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			editNode(t, bp, child(t, bp, bt.RootPageID(), 1), func(n *index.BTreeNode) { n.SetNumKeys(0) })
+		}, "without children"},
+		{"slots over cells", func(t *testing.T, bt *index.BTreeIndex, bp *storage.BufferPool) {
+			leaf := child(t, bp, child(t, bp, bt.RootPageID(), 1), 0)
+			editNode(t, bp, leaf, func(n *index.BTreeNode) { n.SetNumKeys(5000) })
+		}, "overlap cells"},
+	}
+
+	for _, tc := range cases {
+		bt, bp := newTestTree(t)
+		for k := int64(0); k < count; k++ {
+			if err := bt.Insert(keyFor(k), ridFor(k)); err != nil {
+				t.Fatalf("Insert(%d) failed: %v", k, err)
+			}
+		}
+		tc.corrupt(t, bt, bp)
+
+		stats, err := bt.Verify()
+		if tc.want == "" {
+			if err != nil {
+				t.Fatalf("%s: Verify failed: %v", tc.name, err)
+			}
+			if stats.Entries != count || stats.Depth != 3 || stats.Internal < 2 || stats.Leaves < 100 {
+				t.Errorf("%s: unexpected stats %+v", tc.name, stats)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+// TestBTreeVerifyLeafDepth builds a tree by hand with one leaf directly
+// under the root and another a level further down.
+func TestBTreeVerifyLeafDepth(t *testing.T) {
+	_, bp := newTestTree(t)
+	var pages []storage.PageID
+	newNode := func(nodeType uint32, parent storage.PageID) (storage.PageID, *index.BTreeNode) {
+		page, err := bp.NewPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, page.ID)
+		node := index.NewBTreeNode(page)
+		node.Init(nodeType)
+		node.SetParentPageID(parent)
+		return page.ID, node
+	}
+
+	rootID, root := newNode(index.NodeTypeInternal, storage.InvalidPageID)
+	midID, mid := newNode(index.NodeTypeInternal, rootID)
+	deepID, deep := newNode(index.NodeTypeLeaf, midID)
+	shallowID, shallow := newNode(index.NodeTypeLeaf, rootID)
+	deep.InsertLeaf(keyFor(1), ridFor(1))
+	deep.SetNextPageID(shallowID)
+	shallow.InsertLeaf(keyFor(5), ridFor(5))
+	mid.InsertInternal(nil, deepID)
+	root.InsertInternal(nil, midID)
+	root.InsertInternal(keyFor(5), shallowID)
+	for _, id := range pages {
+		bp.UnpinPage(id, true)
+	}
+
+	bt, err := index.NewBTreeIndex(bp, rootID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bt.Verify(); err == nil || !strings.Contains(err.Error(), "leaf at depth 2, expected 3") {
+		t.Errorf("expected a leaf depth error, got %v", err)
+	}
+}
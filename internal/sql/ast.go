@@ -1,90 +1,156 @@
-package sql
-
-// StatementType represents the type of SQL statement.
-type StatementType int
-
-const (
-	StmtCreate StatementType = iota
-	StmtInsert
-	StmtSelect
-	StmtDelete
-	StmtUpdate
-)
-
-type Statement interface {
-	Type() StatementType
-}
-
-type FieldType int
-
-const (
-	TypeInt FieldType = iota
-	TypeVarchar
-)
-
-type ColumnDef struct {
-	Name string
-	Type FieldType
-}
-
-// CreateTableStatement: CREATE TABLE <name> (col1 type, col2 type)
-type CreateTableStatement struct {
-	TableName string
-	Columns   []ColumnDef
-}
-
-func (s *CreateTableStatement) Type() StatementType { return StmtCreate }
-
-// InsertStatement: INSERT INTO <name> VALUES (...)
-type InsertStatement struct {
-	TableName string
-	Values    []interface{}
-}
-
-func (s *InsertStatement) Type() StatementType { return StmtInsert }
-
-// JoinClause represents a JOIN ... ON ... clause
-type JoinClause struct {
-	JoinTable    string
-	OnLeftField  string
-	OnRightField string
-}
-
-// SelectStatement: SELECT * FROM <name> [JOIN table ON ...] [WHERE ...]
-type SelectStatement struct {
-	TableName string
-	Fields    []string
-	Join      *JoinClause
-	Where     *WhereClause
-}
-
-type WhereClause struct {
-	Field string
-	Op    string
-	Value interface{}
-}
-
-func (s *SelectStatement) Type() StatementType { return StmtSelect }
-
-// DeleteStatement: DELETE FROM <name> WHERE ...
-type DeleteStatement struct {
-	TableName string
-	Where     *WhereClause
-}
-
-func (s *DeleteStatement) Type() StatementType { return StmtDelete }
-
-// SetClause represents a SET col = val assignment
-type SetClause struct {
-	Column string
-	Value  interface{}
-}
-
-// UpdateStatement: UPDATE <name> SET col=val WHERE ...
-type UpdateStatement struct {
-	TableName string
-	Sets      []SetClause
-	Where     *WhereClause
-}
-
-func (s *UpdateStatement) Type() StatementType { return StmtUpdate }
+package sql
+
+import "fmt"
+
+// StatementType represents the type of SQL statement.
+type StatementType int
+
+const (
+	StmtCreate StatementType = iota
+	StmtInsert
+	StmtSelect
+	StmtDelete
+	StmtUpdate
+	StmtCreateIndex
+	StmtDropIndex
+)
+
+type Statement interface {
+	Type() StatementType
+}
+
+type FieldType int
+
+const (
+	TypeInt FieldType = iota
+	TypeVarchar
+	TypeBigInt
+	TypeBoolean
+	TypeFloat
+	TypeDouble
+	TypeDecimal
+	TypeDate
+	TypeTimestamp
+	TypeBlob
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case TypeInt:
+		return "INT"
+	case TypeVarchar:
+		return "VARCHAR"
+	case TypeBigInt:
+		return "BIGINT"
+	case TypeBoolean:
+		return "BOOLEAN"
+	case TypeFloat:
+		return "FLOAT"
+	case TypeDouble:
+		return "DOUBLE"
+	case TypeDecimal:
+		return "DECIMAL"
+	case TypeDate:
+		return "DATE"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeBlob:
+		return "BLOB"
+	}
+	return fmt.Sprintf("FieldType(%d)", int(t))
+}
+
+// ColumnDef describes a table column.
+// Length limits VARCHAR columns (0 means unlimited); Precision and
+// Scale apply to DECIMAL columns. Columns are nullable unless NotNull is set.
+type ColumnDef struct {
+	Name      string
+	Type      FieldType
+	Length    int
+	Precision int
+	Scale     int
+	NotNull   bool
+}
+
+// CreateTableStatement: CREATE TABLE <name> (col1 type, col2 type)
+type CreateTableStatement struct {
+	TableName string
+	Columns   []ColumnDef
+}
+
+func (s *CreateTableStatement) Type() StatementType { return StmtCreate }
+
+// CreateIndexStatement: CREATE [UNIQUE] INDEX <name> ON <table> (<column>, ...)
+type CreateIndexStatement struct {
+	IndexName string
+	TableName string
+	Columns   []string
+	Unique    bool
+}
+
+func (s *CreateIndexStatement) Type() StatementType { return StmtCreateIndex }
+
+// DropIndexStatement: DROP INDEX <name>
+type DropIndexStatement struct {
+	IndexName string
+}
+
+func (s *DropIndexStatement) Type() StatementType { return StmtDropIndex }
+
+// InsertStatement: INSERT INTO <name> VALUES (...)
+type InsertStatement struct {
+	TableName string
+	Values    []interface{}
+}
+
+func (s *InsertStatement) Type() StatementType { return StmtInsert }
+
+// JoinClause represents a JOIN ... ON ... clause
+type JoinClause struct {
+	JoinTable    string
+	OnLeftField  *ColumnRef
+	OnRightField *ColumnRef
+}
+
+// SelectItem is one entry of a SELECT list: an expression with an
+// optional AS alias, or * (all columns) when Star is set. Table
+// qualifies a star, as in users.*.
+type SelectItem struct {
+	Expr  Expr
+	Alias string
+	Star  bool
+	Table string
+}
+
+// SelectStatement: SELECT <items> FROM <name> [JOIN table ON ...] [WHERE ...]
+type SelectStatement struct {
+	TableName string
+	Fields    []SelectItem
+	Join      *JoinClause
+	Where     Expr
+}
+
+func (s *SelectStatement) Type() StatementType { return StmtSelect }
+
+// DeleteStatement: DELETE FROM <name> WHERE ...
+type DeleteStatement struct {
+	TableName string
+	Where     Expr
+}
+
+func (s *DeleteStatement) Type() StatementType { return StmtDelete }
+
+// SetClause represents a SET col = expr assignment
+type SetClause struct {
+	Column string
+	Value  Expr
+}
+
+// UpdateStatement: UPDATE <name> SET col=val WHERE ...
+type UpdateStatement struct {
+	TableName string
+	Sets      []SetClause
+	Where     Expr
+}
+
+func (s *UpdateStatement) Type() StatementType { return StmtUpdate }
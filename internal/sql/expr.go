@@ -0,0 +1,350 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a node in an expression tree, e.g. a WHERE condition.
+type Expr interface {
+	String() string
+}
+
+// Literal is a constant value; a nil Value is the NULL literal.
+type Literal struct {
+	Value interface{}
+}
+
+func (e *Literal) String() string {
+	if s, ok := e.Value.(string); ok {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return FormatValue(e.Value)
+}
+
+// ColumnRef names a column, optionally qualified by its table.
+type ColumnRef struct {
+	Table string
+	Name  string
+}
+
+func (e *ColumnRef) String() string {
+	if e.Table != "" {
+		return e.Table + "." + e.Name
+	}
+	return e.Name
+}
+
+// BinaryExpr applies an infix operator: AND, OR, comparisons or arithmetic.
+type BinaryExpr struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+func (e *BinaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+}
+
+// UnaryExpr applies a prefix operator: NOT or unary minus.
+type UnaryExpr struct {
+	Op      string
+	Operand Expr
+}
+
+func (e *UnaryExpr) String() string {
+	if e.Op == "NOT" {
+		return fmt.Sprintf("(NOT %s)", e.Operand)
+	}
+	return fmt.Sprintf("(%s%s)", e.Op, e.Operand)
+}
+
+// IsNullExpr tests an expression for NULL: expr IS [NOT] NULL.
+type IsNullExpr struct {
+	Expr Expr
+	Not  bool
+}
+
+func (e *IsNullExpr) String() string {
+	if e.Not {
+		return fmt.Sprintf("(%s IS NOT NULL)", e.Expr)
+	}
+	return fmt.Sprintf("(%s IS NULL)", e.Expr)
+}
+
+// LikeExpr matches a string against a pattern in which % stands for
+// any sequence of characters and _ for exactly one: expr [NOT] LIKE pattern.
+type LikeExpr struct {
+	Expr    Expr
+	Pattern Expr
+	Not     bool
+}
+
+func (e *LikeExpr) String() string {
+	return fmt.Sprintf("(%s %sLIKE %s)", e.Expr, notPrefix(e.Not), e.Pattern)
+}
+
+// InExpr tests membership in a list: expr [NOT] IN (a, b, ...).
+type InExpr struct {
+	Expr Expr
+	List []Expr
+	Not  bool
+}
+
+func (e *InExpr) String() string {
+	items := make([]string, len(e.List))
+	for i, item := range e.List {
+		items[i] = item.String()
+	}
+	return fmt.Sprintf("(%s %sIN (%s))", e.Expr, notPrefix(e.Not), strings.Join(items, ", "))
+}
+
+// BetweenExpr tests an inclusive range: expr [NOT] BETWEEN low AND high.
+type BetweenExpr struct {
+	Expr Expr
+	Low  Expr
+	High Expr
+	Not  bool
+}
+
+func (e *BetweenExpr) String() string {
+	return fmt.Sprintf("(%s %sBETWEEN %s AND %s)", e.Expr, notPrefix(e.Not), e.Low, e.High)
+}
+
+func notPrefix(not bool) string {
+	if not {
+		return "NOT "
+	}
+	return ""
+}
+
+// Operator precedence, lowest first. Zero means "not a binary operator".
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precCompare
+	precAdditive
+	precMultiplicative
+	precUnary
+)
+
+var binaryPrecedence = map[string]int{
+	"OR":      precOr,
+	"AND":     precAnd,
+	"=":       precCompare,
+	"<":       precCompare,
+	">":       precCompare,
+	"<=":      precCompare,
+	">=":      precCompare,
+	"<>":      precCompare,
+	"!=":      precCompare,
+	"IS":      precCompare,
+	"LIKE":    precCompare,
+	"IN":      precCompare,
+	"BETWEEN": precCompare,
+	// NOT after an operand introduces NOT LIKE, NOT IN or NOT BETWEEN.
+	"NOT": precCompare,
+	"+":   precAdditive,
+	"-":   precAdditive,
+	"*":   precMultiplicative,
+	"/":   precMultiplicative,
+	"%":   precMultiplicative,
+}
+
+// parseExpr parses an expression starting at the current token using
+// precedence climbing. Only operators binding at least as tightly as
+// minPrec are consumed. On return the current token is the last token
+// of the expression.
+func (p *Parser) parseExpr(minPrec int) (Expr, error) {
+	left, err := p.parsePrefix()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseInfix(left, minPrec)
+}
+
+// parseInfix continues an expression whose first operand, left, has
+// already been parsed.
+func (p *Parser) parseInfix(left Expr, minPrec int) (Expr, error) {
+	for {
+		prec := p.peekPrecedence()
+		if prec == 0 || prec < minPrec {
+			return left, nil
+		}
+		p.nextToken()
+		op := p.curToken.Value
+
+		if op == "IS" {
+			not := false
+			if p.peekToken.Value == "NOT" {
+				p.nextToken()
+				not = true
+			}
+			if err := p.expectPeek(TokenKeyword, "NULL"); err != nil {
+				return nil, err
+			}
+			left = &IsNullExpr{Expr: left, Not: not}
+			continue
+		}
+
+		not := false
+		if op == "NOT" {
+			p.nextToken()
+			op = p.curToken.Value
+			if p.curToken.Type != TokenKeyword || (op != "LIKE" && op != "IN" && op != "BETWEEN") {
+				return nil, fmt.Errorf("expected LIKE, IN or BETWEEN after NOT, got %v", p.curToken)
+			}
+			not = true
+		}
+
+		if op == "IN" {
+			list, err := p.parseExprList()
+			if err != nil {
+				return nil, err
+			}
+			left = &InExpr{Expr: left, List: list, Not: not}
+			continue
+		}
+
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		// Left-associative: the right operand only takes tighter operators.
+		right, err := p.parseExpr(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "LIKE":
+			left = &LikeExpr{Expr: left, Pattern: right, Not: not}
+		case "BETWEEN":
+			if err := p.expectPeek(TokenKeyword, "AND"); err != nil {
+				return nil, err
+			}
+			if err := p.nextToken(); err != nil {
+				return nil, err
+			}
+			high, err := p.parseExpr(prec + 1)
+			if err != nil {
+				return nil, err
+			}
+			left = &BetweenExpr{Expr: left, Low: right, High: high, Not: not}
+		case "!=":
+			left = &BinaryExpr{Op: "<>", Left: left, Right: right}
+		default:
+			left = &BinaryExpr{Op: op, Left: left, Right: right}
+		}
+	}
+}
+
+// parseExprList parses a parenthesized, comma-separated list of
+// expressions starting at the token before "(".
+func (p *Parser) parseExprList() ([]Expr, error) {
+	if err := p.expectPeek(TokenSymbol, "("); err != nil {
+		return nil, err
+	}
+	var list []Expr
+	for {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		item, err := p.parseExpr(precOr)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+
+		if p.peekToken.Type == TokenSymbol && p.peekToken.Value == "," {
+			p.nextToken()
+			continue
+		}
+		if err := p.expectPeek(TokenSymbol, ")"); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+}
+
+func (p *Parser) peekPrecedence() int {
+	if p.peekToken.Type != TokenSymbol && p.peekToken.Type != TokenKeyword {
+		return 0
+	}
+	return binaryPrecedence[p.peekToken.Value]
+}
+
+// parsePrefix parses an operand: a literal, column reference,
+// parenthesized expression, NOT or unary minus.
+func (p *Parser) parsePrefix() (Expr, error) {
+	tok := p.curToken
+
+	switch {
+	case tok.Type == TokenKeyword && tok.Value == "NOT":
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseExpr(precNot)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "NOT", Operand: operand}, nil
+
+	case tok.Type == TokenSymbol && tok.Value == "-":
+		if p.peekToken.Type == TokenLiteral {
+			val, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			return &Literal{Value: val}, nil
+		}
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseExpr(precUnary)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "-", Operand: operand}, nil
+
+	case tok.Type == TokenSymbol && tok.Value == "(":
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseExpr(precOr)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPeek(TokenSymbol, ")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case tok.Type == TokenIdentifier:
+		return p.parseColumnRef()
+	}
+
+	val, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &Literal{Value: val}, nil
+}
+
+// parseColumnRef parses "col" or "table.col" starting at the current
+// identifier token.
+func (p *Parser) parseColumnRef() (*ColumnRef, error) {
+	if p.curToken.Type != TokenIdentifier {
+		return nil, fmt.Errorf("expected column name, got %v", p.curToken)
+	}
+	ref := &ColumnRef{Name: p.curToken.Value}
+	if p.peekToken.Type == TokenSymbol && p.peekToken.Value == "." {
+		p.nextToken()
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return nil, err
+		}
+		ref.Table = ref.Name
+		ref.Name = p.curToken.Value
+	}
+	return ref, nil
+}
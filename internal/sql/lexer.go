@@ -1,103 +1,161 @@
-package sql
-
-import (
-	"fmt"
-	"strings"
-	"unicode"
-)
-
-type TokenType int
-
-const (
-	TokenKeyword TokenType = iota
-	TokenIdentifier
-	TokenLiteral // "string" or 123
-	TokenSymbol  // = , ( ) *
-	TokenEOF
-)
-
-type Token struct {
-	Type  TokenType
-	Value string
-}
-
-type Lexer struct {
-	input string
-	pos   int
-}
-
-func NewLexer(input string) *Lexer {
-	return &Lexer{input: input}
-}
-
-func (l *Lexer) NextToken() (Token, error) {
-	l.skipWhitespace()
-	if l.pos >= len(l.input) {
-		return Token{Type: TokenEOF}, nil
-	}
-
-	ch := l.input[l.pos]
-
-	if isAlpha(ch) {
-		return l.scanIdentifier()
-	}
-	if isDigit(ch) {
-		return l.scanNumber()
-	}
-	if ch == '"' || ch == '\'' {
-		return l.scanString(ch)
-	}
-
-	l.pos++
-	return Token{Type: TokenSymbol, Value: string(ch)}, nil
-}
-
-func (l *Lexer) skipWhitespace() {
-	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
-		l.pos++
-	}
-}
-
-func (l *Lexer) scanIdentifier() (Token, error) {
-	start := l.pos
-	for l.pos < len(l.input) && (isAlpha(l.input[l.pos]) || isDigit(l.input[l.pos]) || l.input[l.pos] == '_') {
-		l.pos++
-	}
-	val := l.input[start:l.pos]
-	// Check keywords
-	switch strings.ToUpper(val) {
-	case "CREATE", "TABLE", "INSERT", "INTO", "VALUES", "SELECT", "FROM", "WHERE", "DELETE", "AND", "INT", "VARCHAR", "JOIN", "ON", "UPDATE", "SET":
-		return Token{Type: TokenKeyword, Value: strings.ToUpper(val)}, nil
-	}
-	return Token{Type: TokenIdentifier, Value: val}, nil
-}
-
-func (l *Lexer) scanNumber() (Token, error) {
-	start := l.pos
-	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
-		l.pos++
-	}
-	return Token{Type: TokenLiteral, Value: l.input[start:l.pos]}, nil
-}
-
-func (l *Lexer) scanString(quote byte) (Token, error) {
-	l.pos++ // skip start quote
-	start := l.pos
-	for l.pos < len(l.input) && l.input[l.pos] != quote {
-		l.pos++
-	}
-	if l.pos >= len(l.input) {
-		return Token{}, fmt.Errorf("unterminated string")
-	}
-	val := l.input[start:l.pos]
-	l.pos++ // skip end quote
-	return Token{Type: TokenLiteral, Value: val}, nil
-}
-
-func isAlpha(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
-}
-
-func isDigit(ch byte) bool {
-	return ch >= '0' && ch <= '9'
-}
+package sql
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type TokenType int
+
+const (
+	TokenKeyword TokenType = iota
+	TokenIdentifier
+	TokenLiteral // 123, 1.5 or 2e10
+	TokenSymbol  // = , ( ) * <= >= <> !=
+	TokenEOF
+	TokenString // 'string' or "string"
+	TokenBlob   // X'DEADBEEF', Value holds the decoded bytes
+)
+
+type Token struct {
+	Type  TokenType
+	Value string
+}
+
+type Lexer struct {
+	input string
+	pos   int
+}
+
+func NewLexer(input string) *Lexer {
+	return &Lexer{input: input}
+}
+
+func (l *Lexer) NextToken() (Token, error) {
+	l.skipWhitespace()
+	if l.pos >= len(l.input) {
+		return Token{Type: TokenEOF}, nil
+	}
+
+	ch := l.input[l.pos]
+
+	if (ch == 'x' || ch == 'X') && l.pos+1 < len(l.input) && l.input[l.pos+1] == '\'' {
+		return l.scanBlob()
+	}
+	if isAlpha(ch) {
+		return l.scanIdentifier()
+	}
+	if isDigit(ch) {
+		return l.scanNumber()
+	}
+	if ch == '"' || ch == '\'' {
+		return l.scanString(ch)
+	}
+
+	if l.pos+1 < len(l.input) {
+		switch op := l.input[l.pos : l.pos+2]; op {
+		case "<=", ">=", "<>", "!=":
+			l.pos += 2
+			return Token{Type: TokenSymbol, Value: op}, nil
+		}
+	}
+
+	l.pos++
+	return Token{Type: TokenSymbol, Value: string(ch)}, nil
+}
+
+func (l *Lexer) skipWhitespace() {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+}
+
+func (l *Lexer) scanIdentifier() (Token, error) {
+	start := l.pos
+	for l.pos < len(l.input) && (isAlpha(l.input[l.pos]) || isDigit(l.input[l.pos]) || l.input[l.pos] == '_') {
+		l.pos++
+	}
+	val := l.input[start:l.pos]
+	// Check keywords
+	switch strings.ToUpper(val) {
+	case "CREATE", "TABLE", "INSERT", "INTO", "VALUES", "SELECT", "FROM", "WHERE", "DELETE", "AND", "INT", "VARCHAR", "JOIN", "ON", "UPDATE", "SET",
+		"BIGINT", "BOOLEAN", "FLOAT", "DOUBLE", "DECIMAL", "DATE", "TIMESTAMP", "BLOB", "TRUE", "FALSE",
+		"NULL", "NOT", "IS", "OR", "LIKE", "IN", "BETWEEN", "AS", "UNIQUE", "INDEX", "DROP":
+		return Token{Type: TokenKeyword, Value: strings.ToUpper(val)}, nil
+	}
+	return Token{Type: TokenIdentifier, Value: val}, nil
+}
+
+func (l *Lexer) scanNumber() (Token, error) {
+	start := l.pos
+	l.skipDigits()
+	if l.pos+1 < len(l.input) && l.input[l.pos] == '.' && isDigit(l.input[l.pos+1]) {
+		l.pos++
+		l.skipDigits()
+	}
+	if l.pos < len(l.input) && (l.input[l.pos] == 'e' || l.input[l.pos] == 'E') {
+		exp := l.pos + 1
+		if exp < len(l.input) && (l.input[exp] == '+' || l.input[exp] == '-') {
+			exp++
+		}
+		if exp < len(l.input) && isDigit(l.input[exp]) {
+			l.pos = exp
+			l.skipDigits()
+		}
+	}
+	return Token{Type: TokenLiteral, Value: l.input[start:l.pos]}, nil
+}
+
+func (l *Lexer) skipDigits() {
+	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+		l.pos++
+	}
+}
+
+// scanString reads a quoted string. A doubled quote inside the
+// string stands for a single quote character.
+func (l *Lexer) scanString(quote byte) (Token, error) {
+	l.pos++ // skip start quote
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.input) {
+			return Token{}, fmt.Errorf("unterminated string")
+		}
+		ch := l.input[l.pos]
+		l.pos++
+		if ch == quote {
+			if l.pos < len(l.input) && l.input[l.pos] == quote {
+				sb.WriteByte(quote)
+				l.pos++
+				continue
+			}
+			break
+		}
+		sb.WriteByte(ch)
+	}
+	return Token{Type: TokenString, Value: sb.String()}, nil
+}
+
+// scanBlob reads a hex blob literal such as X'CAFE'.
+func (l *Lexer) scanBlob() (Token, error) {
+	l.pos++ // skip X
+	tok, err := l.scanString('\'')
+	if err != nil {
+		return Token{}, err
+	}
+	data, err := hex.DecodeString(tok.Value)
+	if err != nil {
+		return Token{}, fmt.Errorf("invalid blob literal X'%s'", tok.Value)
+	}
+	return Token{Type: TokenBlob, Value: string(data)}, nil
+}
+
+func isAlpha(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
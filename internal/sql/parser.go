@@ -1,332 +1,590 @@
-package sql
-
-import (
-	"fmt"
-	"strconv"
-)
-
-type Parser struct {
-	lexer     *Lexer
-	curToken  Token
-	peekToken Token
-}
-
-func NewParser(l *Lexer) (*Parser, error) {
-	p := &Parser{lexer: l}
-	// Read two tokens to setup cur and peek
-	t, err := l.NextToken()
-	if err != nil {
-		return nil, err
-	}
-	p.curToken = t
-	t, err = l.NextToken()
-	if err != nil {
-		return nil, err
-	}
-	p.peekToken = t
-	return p, nil
-}
-
-func (p *Parser) nextToken() error {
-	p.curToken = p.peekToken
-	t, err := p.lexer.NextToken()
-	if err != nil {
-		return err
-	}
-	p.peekToken = t
-	return nil
-}
-
-func (p *Parser) Parse() (Statement, error) {
-	if p.curToken.Type == TokenKeyword {
-		switch p.curToken.Value {
-		case "CREATE":
-			return p.parseCreate()
-		case "INSERT":
-			return p.parseInsert()
-		case "SELECT":
-			return p.parseSelect()
-		case "DELETE":
-			return p.parseDelete()
-		case "UPDATE":
-			return p.parseUpdate()
-		}
-	}
-	return nil, fmt.Errorf("unexpected token %v", p.curToken)
-}
-
-// CREATE TABLE name (col type, ...)
-func (p *Parser) parseCreate() (*CreateTableStatement, error) {
-	if err := p.expectPeek(TokenKeyword, "TABLE"); err != nil {
-		return nil, err
-	}
-	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-		return nil, err
-	}
-	tableName := p.curToken.Value
-
-	if err := p.expectPeek(TokenSymbol, "("); err != nil {
-		return nil, err
-	}
-
-	cols := []ColumnDef{}
-	for p.curToken.Value != ")" { 
-        // We are at '(' or ',' here usually? No, expectPeek advances.
-        // After '(', we expect Identifier (Column Name).
-		if err := p.nextToken(); err != nil {
-            return nil, err
-        }
-        if p.curToken.Type == TokenSymbol && p.curToken.Value == ")" {
-            break
-        }
-        if p.curToken.Type != TokenIdentifier {
-            return nil, fmt.Errorf("expected column name, got %v", p.curToken)
-        }
-        colName := p.curToken.Value
-        
-        if err := p.expectPeek(TokenKeyword, ""); err != nil {
-            return nil, fmt.Errorf("expected type")
-        }
-        typeStr := p.curToken.Value
-        var fieldType FieldType
-        if typeStr == "INT" {
-            fieldType = TypeInt
-        } else if typeStr == "VARCHAR" {
-            fieldType = TypeVarchar
-        } else {
-            return nil, fmt.Errorf("unknown type %s", typeStr)
-        }
-        
-        cols = append(cols, ColumnDef{Name: colName, Type: fieldType})
-
-        // Next should be ',' or ')'
-        if p.peekToken.Value == "," {
-            p.nextToken()
-        } else if p.peekToken.Value != ")" {
-             return nil, fmt.Errorf("expected , or ) got %v", p.peekToken)
-        }
-	}
-    // Consume ')'
-     if p.curToken.Value != ")" {
-         p.nextToken()
-     }
-
-	return &CreateTableStatement{TableName: tableName, Columns: cols}, nil
-}
-
-// INSERT INTO name VALUES (v1, v2)
-func (p *Parser) parseInsert() (*InsertStatement, error) {
-	if err := p.expectPeek(TokenKeyword, "INTO"); err != nil {
-		return nil, err
-	}
-	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-		return nil, err
-	}
-	tableName := p.curToken.Value
-
-	if err := p.expectPeek(TokenKeyword, "VALUES"); err != nil {
-		return nil, err
-	}
-	if err := p.expectPeek(TokenSymbol, "("); err != nil {
-		return nil, err
-	}
-
-	values := []interface{}{}
-	for {
-		if err := p.nextToken(); err != nil {
-            return nil, err
-        }
-        if p.curToken.Value == ")" {
-            break
-        }
-        
-        // Parse Value
-        if p.curToken.Type == TokenLiteral {
-            // Check if int or string
-            if val, err := strconv.ParseInt(p.curToken.Value, 10, 64); err == nil {
-                values = append(values, int(val)) // Use int
-            } else {
-                values = append(values, p.curToken.Value)
-            }
-        } else {
-            return nil, fmt.Errorf("expected literal, got %v", p.curToken)
-        }
-        
-        if p.peekToken.Value == "," {
-            p.nextToken()
-        } else if p.peekToken.Value != ")" {
-             return nil, fmt.Errorf("expected , or )")
-        }
-	}
-     if p.curToken.Value != ")" {
-         p.nextToken()
-     }
-
-	return &InsertStatement{TableName: tableName, Values: values}, nil
-}
-
-// SELECT * FROM name [JOIN table ON t1.c=t2.c] [WHERE ...]
-func (p *Parser) parseSelect() (*SelectStatement, error) {
-	fields := []string{}
-	p.nextToken()
-
-	for p.curToken.Value != "FROM" && p.curToken.Type != TokenEOF {
-		fields = append(fields, p.curToken.Value)
-		if p.peekToken.Value == "," {
-			p.nextToken()
-		}
-		p.nextToken()
-	}
-
-	if p.curToken.Value != "FROM" {
-		return nil, fmt.Errorf("expected FROM")
-	}
-
-	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-		return nil, err
-	}
-	tableName := p.curToken.Value
-
-	var join *JoinClause
-	if p.peekToken.Value == "JOIN" {
-		p.nextToken()
-		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-			return nil, err
-		}
-		joinTable := p.curToken.Value
-		if err := p.expectPeek(TokenKeyword, "ON"); err != nil {
-			return nil, err
-		}
-		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-			return nil, err
-		}
-		leftField := p.curToken.Value
-		p.nextToken() // skip =
-		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-			return nil, err
-		}
-		rightField := p.curToken.Value
-		join = &JoinClause{JoinTable: joinTable, OnLeftField: leftField, OnRightField: rightField}
-	}
-
-	var where *WhereClause
-	if p.peekToken.Value == "WHERE" {
-		p.nextToken()
-		w, err := p.parseWhere()
-		if err != nil {
-			return nil, err
-		}
-		where = w
-	}
-
-	return &SelectStatement{TableName: tableName, Fields: fields, Join: join, Where: where}, nil
-}
-
-// DELETE FROM name WHERE ...
-func (p *Parser) parseDelete() (*DeleteStatement, error) {
-    if err := p.expectPeek(TokenKeyword, "FROM"); err != nil {
-        return nil, err
-    }
-    if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-        return nil, err
-    }
-    tableName := p.curToken.Value
-     
-    var where *WhereClause
-    if p.peekToken.Value == "WHERE" {
-        p.nextToken() 
-        w, err := p.parseWhere()
-        if err != nil {
-            return nil, err
-        }
-        where = w
-    }
-    return &DeleteStatement{TableName: tableName, Where: where}, nil
-}
-
-func (p *Parser) parseWhere() (*WhereClause, error) {
-    // Identifier Op Value
-    if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-        return nil, err
-    }
-    field := p.curToken.Value
-    
-    if err := p.nextToken(); err != nil {
-        return nil, err
-    }
-    op := p.curToken.Value // =, <, >
-    
-    if err := p.nextToken(); err != nil {
-        return nil, err
-    }
-    valStr := p.curToken.Value
-    var val interface{}
-    if v, err := strconv.ParseInt(valStr, 10, 64); err == nil {
-        val = int(v)
-    } else {
-        val = valStr
-    }
-    
-    return &WhereClause{Field: field, Op: op, Value: val}, nil
-}
-
-// UPDATE table SET col=val [, col2=val2] WHERE ...
-func (p *Parser) parseUpdate() (*UpdateStatement, error) {
-	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-		return nil, err
-	}
-	tableName := p.curToken.Value
-
-	if err := p.expectPeek(TokenKeyword, "SET"); err != nil {
-		return nil, err
-	}
-
-	sets := []SetClause{}
-	for {
-		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
-			return nil, err
-		}
-		col := p.curToken.Value
-
-		p.nextToken() // skip =
-
-		if err := p.nextToken(); err != nil {
-			return nil, err
-		}
-		valStr := p.curToken.Value
-		var val interface{}
-		if v, err := strconv.ParseInt(valStr, 10, 64); err == nil {
-			val = int(v)
-		} else {
-			val = valStr
-		}
-		sets = append(sets, SetClause{Column: col, Value: val})
-
-		if p.peekToken.Value != "," {
-			break
-		}
-		p.nextToken()
-	}
-
-	var where *WhereClause
-	if p.peekToken.Value == "WHERE" {
-		p.nextToken()
-		w, err := p.parseWhere()
-		if err != nil {
-			return nil, err
-		}
-		where = w
-	}
-
-	return &UpdateStatement{TableName: tableName, Sets: sets, Where: where}, nil
-}
-
-func (p *Parser) expectPeek(t TokenType, val string) error {
-	if p.peekToken.Type != t {
-		return fmt.Errorf("expected token type %v, got %v", t, p.peekToken.Type)
-	}
-	if val != "" && p.peekToken.Value != val {
-		return fmt.Errorf("expected token val %s, got %s", val, p.peekToken.Value)
-	}
-	return p.nextToken()
-}
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Parser struct {
+	lexer     *Lexer
+	curToken  Token
+	peekToken Token
+}
+
+func NewParser(l *Lexer) (*Parser, error) {
+	p := &Parser{lexer: l}
+	// Read two tokens to setup cur and peek
+	t, err := l.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	p.curToken = t
+	t, err = l.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	p.peekToken = t
+	return p, nil
+}
+
+func (p *Parser) nextToken() error {
+	p.curToken = p.peekToken
+	t, err := p.lexer.NextToken()
+	if err != nil {
+		return err
+	}
+	p.peekToken = t
+	return nil
+}
+
+// Parse parses a single statement, optionally followed by a semicolon,
+// and fails if anything else follows it.
+func (p *Parser) Parse() (Statement, error) {
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+	if p.peekToken.Type == TokenSymbol && p.peekToken.Value == ";" {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peekToken.Type != TokenEOF {
+		return nil, fmt.Errorf("unexpected token %v after end of statement", p.peekToken)
+	}
+	return stmt, nil
+}
+
+// parseStatement parses one statement, leaving the parser on its last
+// token.
+func (p *Parser) parseStatement() (Statement, error) {
+	if p.curToken.Type == TokenKeyword {
+		switch p.curToken.Value {
+		case "CREATE":
+			return p.parseCreate()
+		case "INSERT":
+			return p.parseInsert()
+		case "SELECT":
+			return p.parseSelect()
+		case "DELETE":
+			return p.parseDelete()
+		case "UPDATE":
+			return p.parseUpdate()
+		case "DROP":
+			return p.parseDrop()
+		}
+	}
+	return nil, fmt.Errorf("unexpected token %v", p.curToken)
+}
+
+// parseCreate dispatches on the object being created.
+func (p *Parser) parseCreate() (Statement, error) {
+	switch p.peekToken.Value {
+	case "UNIQUE", "INDEX":
+		return p.parseCreateIndex()
+	}
+	return p.parseCreateTable()
+}
+
+// CREATE TABLE name (col type, ...)
+func (p *Parser) parseCreateTable() (*CreateTableStatement, error) {
+	if err := p.expectPeek(TokenKeyword, "TABLE"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	tableName := p.curToken.Value
+
+	if err := p.expectPeek(TokenSymbol, "("); err != nil {
+		return nil, err
+	}
+
+	cols := []ColumnDef{}
+	for {
+		// At '(' or ','; the column name follows.
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		if p.curToken.Type == TokenSymbol && p.curToken.Value == ")" {
+			break
+		}
+		if p.curToken.Type != TokenIdentifier {
+			return nil, fmt.Errorf("expected column name, got %v", p.curToken)
+		}
+		colName := p.curToken.Value
+
+		col, err := p.parseColumnType(colName)
+		if err != nil {
+			return nil, err
+		}
+		if p.peekToken.Value == "NOT" {
+			p.nextToken()
+			if err := p.expectPeek(TokenKeyword, "NULL"); err != nil {
+				return nil, err
+			}
+			col.NotNull = true
+		} else if p.peekToken.Value == "NULL" {
+			p.nextToken()
+		}
+		cols = append(cols, col)
+
+		// Next should be ',' or ')'
+		if p.peekToken.Value == "," {
+			p.nextToken()
+		} else if p.peekToken.Value == ")" {
+			p.nextToken()
+			break
+		} else {
+			return nil, fmt.Errorf("expected , or ) got %v", p.peekToken)
+		}
+	}
+
+	return &CreateTableStatement{TableName: tableName, Columns: cols}, nil
+}
+
+// CREATE [UNIQUE] INDEX name ON table (column)
+func (p *Parser) parseCreateIndex() (*CreateIndexStatement, error) {
+	stmt := &CreateIndexStatement{}
+	if p.peekToken.Value == "UNIQUE" {
+		p.nextToken()
+		stmt.Unique = true
+	}
+	if err := p.expectPeek(TokenKeyword, "INDEX"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	stmt.IndexName = p.curToken.Value
+
+	if err := p.expectPeek(TokenKeyword, "ON"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	stmt.TableName = p.curToken.Value
+
+	if err := p.expectPeek(TokenSymbol, "("); err != nil {
+		return nil, err
+	}
+	for {
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return nil, err
+		}
+		stmt.Columns = append(stmt.Columns, p.curToken.Value)
+
+		if p.peekToken.Type != TokenSymbol || p.peekToken.Value != "," {
+			break
+		}
+		p.nextToken()
+	}
+	if err := p.expectPeek(TokenSymbol, ")"); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// DROP INDEX name
+func (p *Parser) parseDrop() (*DropIndexStatement, error) {
+	if err := p.expectPeek(TokenKeyword, "INDEX"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	return &DropIndexStatement{IndexName: p.curToken.Value}, nil
+}
+
+// INSERT INTO name VALUES (v1, v2)
+func (p *Parser) parseInsert() (*InsertStatement, error) {
+	if err := p.expectPeek(TokenKeyword, "INTO"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	tableName := p.curToken.Value
+
+	if err := p.expectPeek(TokenKeyword, "VALUES"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPeek(TokenSymbol, "("); err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{}
+	for {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		if p.curToken.Value == ")" {
+			break
+		}
+
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+
+		if p.peekToken.Value == "," {
+			p.nextToken()
+		} else if p.peekToken.Value != ")" {
+			return nil, fmt.Errorf("expected , or )")
+		}
+	}
+	if p.curToken.Value != ")" {
+		p.nextToken()
+	}
+
+	return &InsertStatement{TableName: tableName, Values: values}, nil
+}
+
+// SELECT items FROM name [JOIN table ON t1.c=t2.c] [WHERE ...]
+func (p *Parser) parseSelect() (*SelectStatement, error) {
+	fields := []SelectItem{}
+	for {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, item)
+
+		if p.peekToken.Type != TokenSymbol || p.peekToken.Value != "," {
+			break
+		}
+		p.nextToken()
+	}
+
+	if err := p.expectPeek(TokenKeyword, "FROM"); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	tableName := p.curToken.Value
+
+	var join *JoinClause
+	if p.peekToken.Value == "JOIN" {
+		p.nextToken()
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return nil, err
+		}
+		joinTable := p.curToken.Value
+		if err := p.expectPeek(TokenKeyword, "ON"); err != nil {
+			return nil, err
+		}
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return nil, err
+		}
+		leftField, err := p.parseColumnRef()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPeek(TokenSymbol, "="); err != nil {
+			return nil, err
+		}
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return nil, err
+		}
+		rightField, err := p.parseColumnRef()
+		if err != nil {
+			return nil, err
+		}
+		join = &JoinClause{JoinTable: joinTable, OnLeftField: leftField, OnRightField: rightField}
+	}
+
+	var where Expr
+	if p.peekToken.Value == "WHERE" {
+		p.nextToken()
+		w, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		where = w
+	}
+
+	return &SelectStatement{TableName: tableName, Fields: fields, Join: join, Where: where}, nil
+}
+
+// DELETE FROM name WHERE ...
+func (p *Parser) parseDelete() (*DeleteStatement, error) {
+	if err := p.expectPeek(TokenKeyword, "FROM"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	tableName := p.curToken.Value
+
+	var where Expr
+	if p.peekToken.Value == "WHERE" {
+		p.nextToken()
+		w, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		where = w
+	}
+	return &DeleteStatement{TableName: tableName, Where: where}, nil
+}
+
+// parseWhere parses the condition following WHERE, which is the current token.
+func (p *Parser) parseWhere() (Expr, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+	return p.parseExpr(precOr)
+}
+
+// UPDATE table SET col=val [, col2=val2] WHERE ...
+func (p *Parser) parseUpdate() (*UpdateStatement, error) {
+	if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+		return nil, err
+	}
+	tableName := p.curToken.Value
+
+	if err := p.expectPeek(TokenKeyword, "SET"); err != nil {
+		return nil, err
+	}
+
+	sets := []SetClause{}
+	for {
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return nil, err
+		}
+		col := p.curToken.Value
+
+		if err := p.expectPeek(TokenSymbol, "="); err != nil {
+			return nil, err
+		}
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+		val, err := p.parseExpr(precOr)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, SetClause{Column: col, Value: val})
+
+		if p.peekToken.Value != "," {
+			break
+		}
+		p.nextToken()
+	}
+
+	var where Expr
+	if p.peekToken.Value == "WHERE" {
+		p.nextToken()
+		w, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		where = w
+	}
+
+	return &UpdateStatement{TableName: tableName, Sets: sets, Where: where}, nil
+}
+
+// parseSelectItem parses *, table.* or an expression with an optional
+// AS alias, starting at the current token.
+func (p *Parser) parseSelectItem() (SelectItem, error) {
+	if p.curToken.Type == TokenSymbol && p.curToken.Value == "*" {
+		return SelectItem{Star: true}, nil
+	}
+
+	var expr Expr
+	var err error
+	if p.curToken.Type == TokenIdentifier && p.peekToken.Type == TokenSymbol && p.peekToken.Value == "." {
+		// table.* or the start of an expression on table.col
+		table := p.curToken.Value
+		p.nextToken()
+		if p.peekToken.Type == TokenSymbol && p.peekToken.Value == "*" {
+			p.nextToken()
+			return SelectItem{Star: true, Table: table}, nil
+		}
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return SelectItem{}, err
+		}
+		expr, err = p.parseInfix(&ColumnRef{Table: table, Name: p.curToken.Value}, precOr)
+	} else {
+		expr, err = p.parseExpr(precOr)
+	}
+	if err != nil {
+		return SelectItem{}, err
+	}
+
+	item := SelectItem{Expr: expr}
+	if p.peekToken.Type == TokenKeyword && p.peekToken.Value == "AS" {
+		p.nextToken()
+		if err := p.expectPeek(TokenIdentifier, ""); err != nil {
+			return SelectItem{}, err
+		}
+		item.Alias = p.curToken.Value
+	}
+	return item, nil
+}
+
+// parseColumnType parses a column type with optional parameters,
+// e.g. VARCHAR(64) or DECIMAL(10,2). The current token is the column name.
+func (p *Parser) parseColumnType(name string) (ColumnDef, error) {
+	if err := p.expectPeek(TokenKeyword, ""); err != nil {
+		return ColumnDef{}, fmt.Errorf("expected type for column %s", name)
+	}
+
+	col := ColumnDef{Name: name}
+	switch p.curToken.Value {
+	case "INT":
+		col.Type = TypeInt
+	case "BIGINT":
+		col.Type = TypeBigInt
+	case "VARCHAR":
+		col.Type = TypeVarchar
+	case "BOOLEAN":
+		col.Type = TypeBoolean
+	case "FLOAT":
+		col.Type = TypeFloat
+	case "DOUBLE":
+		col.Type = TypeDouble
+	case "DECIMAL":
+		col.Type = TypeDecimal
+		col.Precision = 10
+	case "DATE":
+		col.Type = TypeDate
+	case "TIMESTAMP":
+		col.Type = TypeTimestamp
+	case "BLOB":
+		col.Type = TypeBlob
+	default:
+		return ColumnDef{}, fmt.Errorf("unknown type %s", p.curToken.Value)
+	}
+
+	if p.peekToken.Value != "(" {
+		return col, nil
+	}
+	p.nextToken()
+
+	params := []int{}
+	for {
+		if err := p.expectPeek(TokenLiteral, ""); err != nil {
+			return ColumnDef{}, fmt.Errorf("expected type parameter for column %s", name)
+		}
+		n, err := strconv.Atoi(p.curToken.Value)
+		if err != nil {
+			return ColumnDef{}, fmt.Errorf("invalid type parameter %s for column %s", p.curToken.Value, name)
+		}
+		params = append(params, n)
+		if p.peekToken.Value != "," {
+			break
+		}
+		p.nextToken()
+	}
+	if err := p.expectPeek(TokenSymbol, ")"); err != nil {
+		return ColumnDef{}, err
+	}
+
+	switch {
+	case col.Type == TypeVarchar && len(params) == 1:
+		if params[0] <= 0 {
+			return ColumnDef{}, fmt.Errorf("VARCHAR length must be positive for column %s", name)
+		}
+		col.Length = params[0]
+	case col.Type == TypeDecimal && len(params) <= 2:
+		col.Precision = params[0]
+		if len(params) == 2 {
+			col.Scale = params[1]
+		}
+		if col.Precision < 1 || col.Precision > MaxDecimalPrecision {
+			return ColumnDef{}, fmt.Errorf("DECIMAL precision must be between 1 and %d for column %s", MaxDecimalPrecision, name)
+		}
+		if col.Scale < 0 || col.Scale > col.Precision {
+			return ColumnDef{}, fmt.Errorf("DECIMAL scale must be between 0 and the precision for column %s", name)
+		}
+	default:
+		return ColumnDef{}, fmt.Errorf("type %s does not take %d parameters", col.Type, len(params))
+	}
+	return col, nil
+}
+
+// parseValue parses a literal value starting at the current token:
+// numbers, strings, NULL (returned as nil), TRUE/FALSE, DATE '...', TIMESTAMP '...' and X'..' blobs.
+func (p *Parser) parseValue() (interface{}, error) {
+	switch p.curToken.Type {
+	case TokenString:
+		return p.curToken.Value, nil
+	case TokenBlob:
+		return []byte(p.curToken.Value), nil
+	case TokenLiteral:
+		return parseNumber(p.curToken.Value)
+	case TokenSymbol:
+		if p.curToken.Value == "-" && p.peekToken.Type == TokenLiteral {
+			p.nextToken()
+			return parseNumber("-" + p.curToken.Value)
+		}
+	case TokenKeyword:
+		switch p.curToken.Value {
+		case "NULL":
+			return nil, nil
+		case "TRUE":
+			return true, nil
+		case "FALSE":
+			return false, nil
+		case "DATE":
+			if err := p.expectPeek(TokenString, ""); err != nil {
+				return nil, fmt.Errorf("expected string after DATE")
+			}
+			return ParseDate(p.curToken.Value)
+		case "TIMESTAMP":
+			if err := p.expectPeek(TokenString, ""); err != nil {
+				return nil, fmt.Errorf("expected string after TIMESTAMP")
+			}
+			return ParseTimestamp(p.curToken.Value)
+		}
+	}
+	return nil, fmt.Errorf("expected literal, got %v", p.curToken)
+}
+
+// parseNumber converts a numeric literal: integers become int,
+// literals with a fraction become Decimal and exponents become float64.
+func parseNumber(s string) (interface{}, error) {
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", s)
+		}
+		return f, nil
+	}
+	if strings.Contains(s, ".") {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil {
+				return nil, err
+			}
+			return f, nil
+		}
+		return d, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", s)
+	}
+	return int(v), nil
+}
+
+func (p *Parser) expectPeek(t TokenType, val string) error {
+	if p.peekToken.Type != t {
+		return fmt.Errorf("expected token type %v, got %v", t, p.peekToken.Type)
+	}
+	if val != "" && p.peekToken.Value != val {
+		return fmt.Errorf("expected token val %s, got %s", val, p.peekToken.Value)
+	}
+	return p.nextToken()
+}
@@ -0,0 +1,334 @@
+package sql_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/sql"
+)
+
+func parse(t *testing.T, input string) sql.Statement {
+	t.Helper()
+	p, err := sql.NewParser(sql.NewLexer(input))
+	if err != nil {
+		t.Fatalf("NewParser(%q) failed: %v", input, err)
+	}
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", input, err)
+	}
+	return stmt
+}
+
+func TestParseColumnTypes(t *testing.T) {
+	stmt := parse(t, "CREATE TABLE payments (id BIGINT, email VARCHAR(64), paid BOOLEAN, ratio FLOAT, score DOUBLE, amount DECIMAL(10,2), due DATE, created TIMESTAMP, receipt BLOB, note VARCHAR)")
+	create, ok := stmt.(*sql.CreateTableStatement)
+	if !ok {
+		t.Fatalf("Expected CreateTableStatement, got %T", stmt)
+	}
+
+	want := []sql.ColumnDef{
+		{Name: "id", Type: sql.TypeBigInt},
+		{Name: "email", Type: sql.TypeVarchar, Length: 64},
+		{Name: "paid", Type: sql.TypeBoolean},
+		{Name: "ratio", Type: sql.TypeFloat},
+		{Name: "score", Type: sql.TypeDouble},
+		{Name: "amount", Type: sql.TypeDecimal, Precision: 10, Scale: 2},
+		{Name: "due", Type: sql.TypeDate},
+		{Name: "created", Type: sql.TypeTimestamp},
+		{Name: "receipt", Type: sql.TypeBlob},
+		{Name: "note", Type: sql.TypeVarchar},
+	}
+	if !reflect.DeepEqual(create.Columns, want) {
+		t.Errorf("Columns mismatch:\nexpected %+v\ngot      %+v", want, create.Columns)
+	}
+}
+
+func TestParseInvalidColumnTypes(t *testing.T) {
+	inputs := []string{
+		"CREATE TABLE t (a DECIMAL(30,2))",
+		"CREATE TABLE t (a DECIMAL(4,5))",
+		"CREATE TABLE t (a INT(3))",
+		"CREATE TABLE t (a VARCHAR(0))",
+	}
+	for _, input := range inputs {
+		p, err := sql.NewParser(sql.NewLexer(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Parse(); err == nil {
+			t.Errorf("Expected error parsing %q", input)
+		}
+	}
+}
+
+func TestParseRejectsTrailingTokens(t *testing.T) {
+	inputs := []string{
+		"SELECT * FROM t WHERE id = 1 garbage junk",
+		"DELETE FROM t WHERE a = 1 ) )",
+		"SELECT * FROM t WHERE (a = 1))",
+		"UPDATE t SET a = 1 WHERE id = 2 3",
+		"INSERT INTO t VALUES (1, 'a'), (2, 'b')",
+		"SELECT * FROM t; SELECT * FROM u",
+		"SELECT * FROM t;;",
+	}
+	for _, input := range inputs {
+		p, err := sql.NewParser(sql.NewLexer(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Parse(); err == nil {
+			t.Errorf("Expected error parsing %q", input)
+		}
+	}
+
+	// A single trailing semicolon is allowed, and a parenthesised type
+	// on the last column still closes the column list.
+	parse(t, "SELECT * FROM t WHERE (a = 1);")
+	stmt := parse(t, "CREATE TABLE t (id INT, name VARCHAR(10));").(*sql.CreateTableStatement)
+	if len(stmt.Columns) != 2 || stmt.Columns[1].Length != 10 {
+		t.Errorf("Unexpected columns %+v", stmt.Columns)
+	}
+}
+
+func TestParseLiterals(t *testing.T) {
+	stmt := parse(t, "INSERT INTO t VALUES (1, -2, 1.50, 2e3, 'it''s', TRUE, FALSE, DATE '2026-01-01', TIMESTAMP '2026-01-01 09:30:00', X'CAFE', '42')")
+	insert := stmt.(*sql.InsertStatement)
+
+	want := []interface{}{
+		1,
+		-2,
+		sql.Decimal{Unscaled: 150, Scale: 2},
+		2000.0,
+		"it's",
+		true,
+		false,
+		sql.Date(20454),
+		sql.Timestamp(1767259800000000),
+		[]byte{0xca, 0xfe},
+		"42",
+	}
+	if !reflect.DeepEqual(insert.Values, want) {
+		t.Errorf("Values mismatch:\nexpected %#v\ngot      %#v", want, insert.Values)
+	}
+}
+
+func TestValueFormatting(t *testing.T) {
+	cases := []struct {
+		val  interface{}
+		want string
+	}{
+		{sql.Decimal{Unscaled: -5, Scale: 2}, "-0.05"},
+		{sql.Decimal{Unscaled: 12345, Scale: 2}, "123.45"},
+		{sql.Date(20454), "2026-01-01"},
+		{sql.Timestamp(1767259800000000), "2026-01-01 09:30:00"},
+		{sql.Timestamp(1767259800000123), "2026-01-01 09:30:00.000123"},
+		{[]byte{0xca, 0xfe}, `\xcafe`},
+		{1.5, "1.5"},
+		{true, "true"},
+		{nil, "NULL"},
+	}
+	for _, c := range cases {
+		if got := sql.FormatValue(c.val); got != c.want {
+			t.Errorf("FormatValue(%#v) = %q, want %q", c.val, got, c.want)
+		}
+	}
+}
+
+func TestDecimalRescale(t *testing.T) {
+	cases := []struct {
+		in    sql.Decimal
+		scale int
+		want  string
+	}{
+		{sql.Decimal{Unscaled: 1249, Scale: 3}, 1, "1.2"},
+		{sql.Decimal{Unscaled: -1249, Scale: 3}, 1, "-1.2"},
+		{sql.Decimal{Unscaled: 125, Scale: 2}, 1, "1.3"},
+		{sql.Decimal{Unscaled: -125, Scale: 2}, 1, "-1.3"},
+		{sql.Decimal{Unscaled: 12449, Scale: 4}, 1, "1.2"},
+		{sql.Decimal{Unscaled: -4, Scale: 1}, 0, "0"},
+		{sql.Decimal{Unscaled: 15, Scale: 1}, 3, "1.500"},
+		{sql.Decimal{Unscaled: math.MinInt64, Scale: 18}, 0, "-9"},
+		{sql.Decimal{Unscaled: 5e18, Scale: 19}, 0, "1"},
+		{sql.Decimal{Unscaled: math.MaxInt64, Scale: 20}, 0, "0"},
+	}
+	for _, c := range cases {
+		got, err := c.in.Rescale(c.scale)
+		if err != nil {
+			t.Fatalf("Rescale(%s, %d) failed: %v", c.in, c.scale, err)
+		}
+		if got.String() != c.want {
+			t.Errorf("Rescale(%s, %d) = %s, want %s", c.in, c.scale, got, c.want)
+		}
+	}
+}
+
+func TestParseNulls(t *testing.T) {
+	create := parse(t, "CREATE TABLE t (id INT NOT NULL, name VARCHAR(10) NULL, note VARCHAR)").(*sql.CreateTableStatement)
+	if !create.Columns[0].NotNull || create.Columns[1].NotNull || create.Columns[2].NotNull {
+		t.Errorf("Unexpected NOT NULL flags: %+v", create.Columns)
+	}
+
+	insert := parse(t, "INSERT INTO t VALUES (1, NULL, 'x')").(*sql.InsertStatement)
+	if insert.Values[1] != nil {
+		t.Errorf("Expected NULL literal to parse as nil, got %#v", insert.Values[1])
+	}
+
+	for input, want := range map[string]string{
+		"SELECT * FROM t WHERE name IS NULL":     "(name IS NULL)",
+		"SELECT * FROM t WHERE name IS NOT NULL": "(name IS NOT NULL)",
+		"SELECT * FROM t WHERE name = NULL":      "(name = NULL)",
+	} {
+		sel := parse(t, input).(*sql.SelectStatement)
+		if sel.Where == nil || sel.Where.String() != want {
+			t.Errorf("%s: expected %s, got %v", input, want, sel.Where)
+		}
+	}
+}
+
+func TestParseWherePrecedence(t *testing.T) {
+	cases := map[string]string{
+		"a = 1 AND b = 2 OR c = 3":                      "(((a = 1) AND (b = 2)) OR (c = 3))",
+		"a = 1 AND (b = 2 OR c = 3)":                    "((a = 1) AND ((b = 2) OR (c = 3)))",
+		"NOT a = 1 AND b IS NOT NULL":                   "((NOT (a = 1)) AND (b IS NOT NULL))",
+		"a + 2 * 3 > t.b - 1":                           "((a + (2 * 3)) > (t.b - 1))",
+		"a - 1 - 2 = -3":                                "(((a - 1) - 2) = -3)",
+		"age > 18 AND (city = 'Nairobi' OR vip = TRUE)": "((age > 18) AND ((city = 'Nairobi') OR (vip = true)))",
+	}
+	for cond, want := range cases {
+		sel := parse(t, "SELECT * FROM t WHERE "+cond).(*sql.SelectStatement)
+		if got := sel.Where.String(); got != want {
+			t.Errorf("WHERE %s:\nexpected %s\ngot      %s", cond, want, got)
+		}
+	}
+}
+
+func TestParseComparisonOperators(t *testing.T) {
+	cases := map[string]string{
+		"a <= 1 AND b >= 2":                     "((a <= 1) AND (b >= 2))",
+		"a <> 1 OR a != 2":                      "((a <> 1) OR (a <> 2))",
+		"a<=b":                                  "(a <= b)",
+		"name LIKE 'B%' AND name NOT LIKE '_x'": "((name LIKE 'B%') AND (name NOT LIKE '_x'))",
+		"id IN (1, 2 + 1, b) OR id NOT IN (4)":  "((id IN (1, (2 + 1), b)) OR (id NOT IN (4)))",
+		"age BETWEEN 18 AND 30 + 5 AND vip":     "((age BETWEEN 18 AND (30 + 5)) AND vip)",
+		"age NOT BETWEEN a - 1 AND 10 OR NOT x": "((age NOT BETWEEN (a - 1) AND 10) OR (NOT x))",
+		"NOT a IN (1)":                          "(NOT (a IN (1)))",
+	}
+	for cond, want := range cases {
+		sel := parse(t, "SELECT * FROM t WHERE "+cond).(*sql.SelectStatement)
+		if got := sel.Where.String(); got != want {
+			t.Errorf("WHERE %s:\nexpected %s\ngot      %s", cond, want, got)
+		}
+	}
+
+	for _, cond := range []string{"a NOT = 1", "a IN 1", "a IN (1, 2", "a BETWEEN 1 OR 2", "a IN ()"} {
+		p, err := sql.NewParser(sql.NewLexer("SELECT * FROM t WHERE " + cond))
+		if err != nil {
+			continue
+		}
+		if _, err := p.Parse(); err == nil {
+			t.Errorf("WHERE %s: expected parse error", cond)
+		}
+	}
+}
+
+func TestParseUpdateExpressions(t *testing.T) {
+	upd := parse(t, "UPDATE t SET a = a + 1, b = 'x', c = NULL WHERE id = 1").(*sql.UpdateStatement)
+	got := []string{}
+	for _, set := range upd.Sets {
+		got = append(got, set.Column+" = "+set.Value.String())
+	}
+	want := []string{"a = (a + 1)", "b = 'x'", "c = NULL"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if upd.Where == nil || upd.Where.String() != "(id = 1)" {
+		t.Errorf("unexpected WHERE %v", upd.Where)
+	}
+}
+
+func TestParseSelectList(t *testing.T) {
+	sel := parse(t, "SELECT *, users.*, id, users.name, users.age * 2 + 1 AS double_age, -balance FROM users").(*sql.SelectStatement)
+
+	type item struct {
+		expr  string
+		alias string
+		star  bool
+		table string
+	}
+	got := []item{}
+	for _, f := range sel.Fields {
+		it := item{alias: f.Alias, star: f.Star, table: f.Table}
+		if f.Expr != nil {
+			it.expr = f.Expr.String()
+		}
+		got = append(got, it)
+	}
+	want := []item{
+		{star: true},
+		{star: true, table: "users"},
+		{expr: "id"},
+		{expr: "users.name"},
+		{expr: "((users.age * 2) + 1)", alias: "double_age"},
+		{expr: "(-balance)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Select list mismatch:\nexpected %+v\ngot      %+v", want, got)
+	}
+
+	for _, input := range []string{"SELECT FROM t", "SELECT a, FROM t", "SELECT a AS FROM t", "SELECT t. FROM t", "SELECT a b FROM t"} {
+		p, err := sql.NewParser(sql.NewLexer(input))
+		if err != nil {
+			continue
+		}
+		if _, err := p.Parse(); err == nil {
+			t.Errorf("Expected error parsing %q", input)
+		}
+	}
+}
+
+func TestParseJoinQualifiedColumns(t *testing.T) {
+	sel := parse(t, "SELECT * FROM users JOIN orders ON users.id = orders.user_id").(*sql.SelectStatement)
+	if sel.Join == nil || sel.Join.OnLeftField.String() != "users.id" || sel.Join.OnRightField.String() != "orders.user_id" {
+		t.Errorf("Unexpected join clause %+v", sel.Join)
+	}
+}
+
+func TestParseIndexStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		want  sql.Statement
+	}{
+		{"CREATE INDEX orders_user ON orders (user_id)",
+			&sql.CreateIndexStatement{IndexName: "orders_user", TableName: "orders", Columns: []string{"user_id"}}},
+		{"CREATE UNIQUE INDEX users_email ON users(email)",
+			&sql.CreateIndexStatement{IndexName: "users_email", TableName: "users", Columns: []string{"email"}, Unique: true}},
+		{"CREATE UNIQUE INDEX orders_day ON orders (user_id, placed_on)",
+			&sql.CreateIndexStatement{IndexName: "orders_day", TableName: "orders", Columns: []string{"user_id", "placed_on"}, Unique: true}},
+		{"DROP INDEX users_email", &sql.DropIndexStatement{IndexName: "users_email"}},
+	}
+	for _, tt := range tests {
+		if got := parse(t, tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.input, tt.want, got)
+		}
+	}
+
+	for _, input := range []string{
+		"CREATE UNIQUE TABLE t (id INT)",
+		"CREATE INDEX ON users (email)",
+		"CREATE INDEX i ON users email",
+		"CREATE INDEX i ON users (a, b",
+		"CREATE INDEX i ON users (a,)",
+		"CREATE INDEX i ON users ()",
+		"DROP TABLE users",
+	} {
+		p, err := sql.NewParser(sql.NewLexer(input))
+		if err != nil {
+			t.Fatalf("NewParser(%q) failed: %v", input, err)
+		}
+		if _, err := p.Parse(); err == nil {
+			t.Errorf("%s: expected parse error", input)
+		}
+	}
+}
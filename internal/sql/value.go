@@ -0,0 +1,224 @@
+package sql
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runtime value representation by column type:
+//   INT: int, BIGINT: int64, BOOLEAN: bool, FLOAT/DOUBLE: float64,
+//   DECIMAL: Decimal, DATE: Date, TIMESTAMP: Timestamp,
+//   VARCHAR: string, BLOB: []byte
+
+// MaxDecimalPrecision is the largest precision that fits in a Decimal.
+const MaxDecimalPrecision = 18
+
+// Decimal is a fixed-point number: Unscaled * 10^-Scale.
+type Decimal struct {
+	Unscaled int64
+	Scale    int
+}
+
+// ParseDecimal parses a plain decimal literal such as "-12.50".
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	neg := false
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		neg = digits[0] == '-'
+		digits = digits[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	u, err := strconv.ParseUint(intPart+fracPart, 10, 63)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	d := Decimal{Unscaled: int64(u), Scale: len(fracPart)}
+	if neg {
+		d.Unscaled = -d.Unscaled
+	}
+	return d, nil
+}
+
+// Rescale converts d to the given scale, rounding half away from zero.
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	u := d.Unscaled
+	for s := d.Scale; s < scale; s++ {
+		if u > math.MaxInt64/10 || u < math.MinInt64/10 {
+			return Decimal{}, fmt.Errorf("decimal %s overflows at scale %d", d, scale)
+		}
+		u *= 10
+	}
+	if d.Scale <= scale {
+		return Decimal{Unscaled: u, Scale: scale}, nil
+	}
+
+	// Divide once and round on the whole remainder; rounding digit by
+	// digit would round 1.249 up to 1.3. Work on the magnitude in uint64,
+	// which holds 10^19, and every int64 is below half of 10^20.
+	shift := d.Scale - scale
+	if shift > 19 {
+		return Decimal{Scale: scale}, nil
+	}
+	div := uint64(1)
+	for i := 0; i < shift; i++ {
+		div *= 10
+	}
+	mag := uint64(u)
+	if u < 0 {
+		mag = -mag
+	}
+	q, rem := mag/div, mag%div
+	if rem >= div-rem {
+		q++
+	}
+	u = int64(q)
+	if d.Unscaled < 0 {
+		u = -u
+	}
+	return Decimal{Unscaled: u, Scale: scale}, nil
+}
+
+// Digits returns the number of significant digits in the unscaled value.
+func (d Decimal) Digits() int {
+	u := d.Unscaled
+	if u < 0 {
+		u = -u
+	}
+	n := 1
+	for u >= 10 {
+		u /= 10
+		n++
+	}
+	return n
+}
+
+// Rat returns d as an exact rational number.
+func (d Decimal) Rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(d.Unscaled), denom)
+}
+
+// Float64 returns the nearest float64 to d.
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+func (d Decimal) String() string {
+	if d.Scale == 0 {
+		return strconv.FormatInt(d.Unscaled, 10)
+	}
+	sign := ""
+	u := d.Unscaled
+	if u < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absUint64(u), 10)
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	cut := len(digits) - d.Scale
+	return sign + digits[:cut] + "." + digits[cut:]
+}
+
+func absUint64(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+// Date is a calendar date stored as days since 1970-01-01.
+type Date int32
+
+const dateLayout = "2006-01-02"
+
+// ParseDate parses a date in YYYY-MM-DD form.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q", s)
+	}
+	return DateFromTime(t), nil
+}
+
+// DateFromTime returns the date containing t, in UTC.
+func DateFromTime(t time.Time) Date {
+	days := t.UTC().Unix() / 86400
+	if t.UTC().Unix() < 0 && t.UTC().Unix()%86400 != 0 {
+		days--
+	}
+	return Date(days)
+}
+
+// Time returns midnight UTC on the date.
+func (d Date) Time() time.Time {
+	return time.Unix(int64(d)*86400, 0).UTC()
+}
+
+func (d Date) String() string {
+	return d.Time().Format(dateLayout)
+}
+
+// Timestamp is a point in time stored as microseconds since the Unix epoch, UTC.
+type Timestamp int64
+
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	dateLayout,
+}
+
+// ParseTimestamp parses a timestamp such as "2026-01-01 09:30:00".
+// Values without a zone are taken to be UTC.
+func ParseTimestamp(s string) (Timestamp, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return TimestampFromTime(t), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// TimestampFromTime converts t to a Timestamp, truncating to microseconds.
+func TimestampFromTime(t time.Time) Timestamp {
+	return Timestamp(t.UnixMicro())
+}
+
+// Time returns the timestamp as a UTC time.
+func (ts Timestamp) Time() time.Time {
+	return time.UnixMicro(int64(ts)).UTC()
+}
+
+func (ts Timestamp) String() string {
+	return ts.Time().Format("2006-01-02 15:04:05.999999")
+}
+
+// FormatValue renders a runtime value for display.
+func FormatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return `\x` + hex.EncodeToString(val)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
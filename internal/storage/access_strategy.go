@@ -0,0 +1,44 @@
+package storage
+
+// scanRingDivisor sizes the ring of a scan strategy as a fraction of the
+// pool, so that a table of up to a quarter of the pool is still cached
+// whole, while a larger one cycles through a quarter of the pool.
+const scanRingDivisor = 4
+
+// AccessStrategy confines the pages a reader brings into the buffer pool
+// to a small ring of frames, like PostgreSQL's BAS_BULKREAD. Once the
+// ring is full, each page the reader misses replaces the page it read
+// one lap of the ring earlier, instead of the page the pool's replacer
+// would choose. A sequential scan of a large table therefore leaves the
+// rest of the pool, such as the upper levels of the B+Trees, in place.
+//
+// Pages already cached when the reader fetches them are not added to the
+// ring. A ring page that is pinned when its turn comes stays cached and
+// the replacer picks the victim instead. An AccessStrategy belongs to
+// one reader and one pool.
+type AccessStrategy struct {
+	ring []PageID
+	next int
+}
+
+// NewRingStrategy creates a strategy that cycles through size frames.
+func NewRingStrategy(size int) *AccessStrategy {
+	ring := make([]PageID, max(size, 1))
+	for i := range ring {
+		ring[i] = InvalidPageID
+	}
+	return &AccessStrategy{ring: ring}
+}
+
+// NewScanStrategy creates a strategy for a sequential scan, with a ring
+// of a quarter of the pool.
+func (bp *BufferPool) NewScanStrategy() *AccessStrategy {
+	return NewRingStrategy(len(bp.frames) / scanRingDivisor)
+}
+
+// add puts a page just read through the strategy in the current ring
+// slot and advances to the next.
+func (s *AccessStrategy) add(id PageID) {
+	s.ring[s.next] = id
+	s.next = (s.next + 1) % len(s.ring)
+}
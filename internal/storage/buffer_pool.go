@@ -1,133 +1,455 @@
-package storage
-
-import (
-	"errors"
-	"fmt"
-	"sync"
-)
-
-// BufferPool manages the in-memory cache of pages.
-type BufferPool struct {
-	diskManager *DiskManager
-	pages       map[PageID]*Page
-	capacity    int
-	mu          sync.Mutex
-}
-
-// NewBufferPool creates a buffer pool with the specified capacity.
-func NewBufferPool(capacity int, diskManager *DiskManager) *BufferPool {
-	return &BufferPool{
-		diskManager: diskManager,
-		pages:       make(map[PageID]*Page),
-		capacity:    capacity,
-	}
-}
-
-// FetchPage returns a page, reading from disk if not cached.
-func (bp *BufferPool) FetchPage(pageID PageID) (*Page, error) {
-	bp.mu.Lock()
-	defer bp.mu.Unlock()
-
-	if page, ok := bp.pages[pageID]; ok {
-		page.PinCount++
-		return page, nil
-	}
-
-	if len(bp.pages) >= bp.capacity {
-		if err := bp.evict(); err != nil {
-			return nil, fmt.Errorf("buffer pool full: %w", err)
-		}
-	}
-
-	page := NewPage(pageID)
-	if err := bp.diskManager.ReadPage(pageID, page); err != nil {
-		return nil, err
-	}
-
-	page.PinCount = 1
-	bp.pages[pageID] = page
-	return page, nil
-}
-
-// UnpinPage decrements the pin count and optionally marks dirty.
-func (bp *BufferPool) UnpinPage(pageID PageID, isDirty bool) {
-	bp.mu.Lock()
-	defer bp.mu.Unlock()
-
-	if page, ok := bp.pages[pageID]; ok {
-		if page.PinCount > 0 {
-			page.PinCount--
-		}
-		if isDirty {
-			page.IsDirty = true
-		}
-	}
-}
-
-// FlushPage writes a dirty page to disk.
-func (bp *BufferPool) FlushPage(pageID PageID) error {
-	bp.mu.Lock()
-	defer bp.mu.Unlock()
-	return bp.flushPage(pageID)
-}
-
-func (bp *BufferPool) flushPage(pageID PageID) error {
-	if page, ok := bp.pages[pageID]; ok {
-		if page.IsDirty {
-			if err := bp.diskManager.WritePage(page); err != nil {
-				return err
-			}
-			page.IsDirty = false
-		}
-	}
-	return nil
-}
-
-// NewPage allocates a new page in the buffer pool.
-func (bp *BufferPool) NewPage() (*Page, error) {
-	bp.mu.Lock()
-	defer bp.mu.Unlock()
-
-	if len(bp.pages) >= bp.capacity {
-		if err := bp.evict(); err != nil {
-			return nil, fmt.Errorf("buffer pool full: %w", err)
-		}
-	}
-
-	pageID, err := bp.diskManager.AllocatePage()
-	if err != nil {
-		return nil, err
-	}
-
-	page := NewPage(pageID)
-	page.PinCount = 1
-	bp.pages[pageID] = page
-
-	return page, nil
-}
-
-func (bp *BufferPool) evict() error {
-	for id, page := range bp.pages {
-		if page.PinCount == 0 {
-			if err := bp.flushPage(id); err != nil {
-				return err
-			}
-			delete(bp.pages, id)
-			return nil
-		}
-	}
-	return errors.New("all pages are pinned")
-}
-
-// FlushAll writes all dirty pages to disk.
-func (bp *BufferPool) FlushAll() error {
-	bp.mu.Lock()
-	defer bp.mu.Unlock()
-
-	for id := range bp.pages {
-		if err := bp.flushPage(id); err != nil {
-			return err
-		}
-	}
-	return nil
-}
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// pageTableShards is the number of partitions of the page table. Each
+// has its own lock, so fetches of different pages rarely contend.
+const pageTableShards = 16
+
+// BufferPool manages the in-memory cache of pages. It holds a fixed
+// array of frames, allocated up front, and a page table mapping each
+// cached page to its frame. A page read from disk or created goes into a
+// free frame; when there is none, the replacer chooses an unpinned page
+// to write back and evict, and its frame is reused. A *Page returned by
+// the pool is therefore only valid while it is pinned.
+//
+// The pool is safe for concurrent use. The page table is split into
+// shards by page ID, and each shard's lock also guards the pin count,
+// dirty flag and loading state of the frames its pages occupy. mu
+// guards the replacer and the free list, and is only ever taken after a
+// shard lock. Disk reads and writes happen with no lock held: the frame
+// is marked loading, and a goroutine that wants its page waits on the
+// frame's io lock and then looks the page up again.
+type BufferPool struct {
+	diskManager *DiskManager
+	frames      []frame
+	shards      [pageTableShards]pageTableShard
+	mu          sync.Mutex
+	freeFrames  []int
+	replacer    Replacer
+	stats       poolCounters
+	tracker     pinTracker
+}
+
+// frame is a slot of the pool holding one page.
+type frame struct {
+	page Page
+	// loading is set while the page is being read in or written back,
+	// with io held.
+	loading bool
+	io      sync.Mutex
+}
+
+// pageTableShard maps the cached pages of one partition to their frames.
+type pageTableShard struct {
+	mu    sync.Mutex
+	pages map[PageID]int
+}
+
+type poolCounters struct {
+	hits, misses, evictions atomic.Int64
+}
+
+// BufferPoolStats counts how often FetchPage found a page in the pool
+// and how often pages were evicted to make room.
+type BufferPoolStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+// HitRate returns the fraction of fetches served from the pool.
+func (s BufferPoolStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewBufferPool creates a buffer pool with the specified capacity that
+// evicts the least recently used page.
+func NewBufferPool(capacity int, diskManager *DiskManager) *BufferPool {
+	return NewBufferPoolWithReplacer(capacity, diskManager, NewLRUReplacer())
+}
+
+// NewBufferPoolWithReplacer creates a buffer pool whose eviction policy
+// is replacer, which must not be shared with another pool.
+func NewBufferPoolWithReplacer(capacity int, diskManager *DiskManager, replacer Replacer) *BufferPool {
+	bp := &BufferPool{
+		diskManager: diskManager,
+		frames:      make([]frame, capacity),
+		freeFrames:  make([]int, capacity),
+		replacer:    replacer,
+	}
+	for i := range bp.shards {
+		bp.shards[i].pages = make(map[PageID]int, capacity/pageTableShards+1)
+	}
+	// Hand out the frames in order, lowest first.
+	for i := range bp.frames {
+		bp.frames[i].page.ID = InvalidPageID
+		bp.freeFrames[i] = capacity - 1 - i
+	}
+	return bp
+}
+
+// Stats returns the pool's counters.
+func (bp *BufferPool) Stats() BufferPoolStats {
+	return BufferPoolStats{
+		Hits:      bp.stats.hits.Load(),
+		Misses:    bp.stats.misses.Load(),
+		Evictions: bp.stats.evictions.Load(),
+	}
+}
+
+func (bp *BufferPool) shard(pageID PageID) *pageTableShard {
+	return &bp.shards[uint64(pageID)%pageTableShards]
+}
+
+// FetchPage returns a page, reading from disk if not cached.
+func (bp *BufferPool) FetchPage(pageID PageID) (*Page, error) {
+	return bp.FetchPageWithStrategy(pageID, nil)
+}
+
+// FetchPageWithStrategy is FetchPage for a reader that makes room for
+// the pages it misses through strategy; see AccessStrategy. A nil
+// strategy leaves the choice to the replacer.
+func (bp *BufferPool) FetchPageWithStrategy(pageID PageID, strategy *AccessStrategy) (*Page, error) {
+	for {
+		page, busy := bp.pinCached(pageID)
+		if page != nil {
+			bp.stats.hits.Add(1)
+			bp.recordPin(pageID)
+			return page, nil
+		}
+		if busy != nil {
+			busy.io.Lock()
+			busy.io.Unlock()
+			continue
+		}
+
+		i, err := bp.takeFrame(strategy)
+		if err != nil {
+			return nil, err
+		}
+		page, err = bp.readInto(pageID, i)
+		if err != nil {
+			return nil, err
+		}
+		if page == nil {
+			continue
+		}
+		if strategy != nil {
+			strategy.add(pageID)
+		}
+		bp.recordPin(pageID)
+		return page, nil
+	}
+}
+
+// pinCached pins pageID if it is cached. If the page is being read in or
+// written back, it returns the frame to wait for instead.
+func (bp *BufferPool) pinCached(pageID PageID) (*Page, *frame) {
+	sh := bp.shard(pageID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	i, ok := sh.pages[pageID]
+	if !ok {
+		return nil, nil
+	}
+	f := &bp.frames[i]
+	if f.loading {
+		return nil, f
+	}
+	f.page.PinCount++
+	bp.mu.Lock()
+	bp.replacer.RecordAccess(pageID)
+	bp.replacer.SetEvictable(pageID, false)
+	bp.mu.Unlock()
+	return &f.page, nil
+}
+
+// readInto reads pageID from disk into frame i, which the caller has
+// taken, and returns it pinned. If another goroutine has cached the page
+// meanwhile, it frees the frame and returns nil to look again.
+func (bp *BufferPool) readInto(pageID PageID, i int) (*Page, error) {
+	f := &bp.frames[i]
+	sh := bp.shard(pageID)
+	sh.mu.Lock()
+	if _, ok := sh.pages[pageID]; ok {
+		sh.mu.Unlock()
+		bp.freeFrame(i)
+		return nil, nil
+	}
+	sh.pages[pageID] = i
+	f.loading = true
+	f.io.Lock()
+	sh.mu.Unlock()
+	bp.stats.misses.Add(1)
+
+	err := bp.diskManager.ReadPage(pageID, &f.page)
+
+	sh.mu.Lock()
+	f.loading = false
+	if err != nil {
+		delete(sh.pages, pageID)
+		f.page.ID = InvalidPageID
+	} else {
+		f.page.PinCount = 1
+		f.page.IsDirty = false
+		bp.mu.Lock()
+		bp.replacer.RecordAccess(pageID)
+		bp.mu.Unlock()
+	}
+	sh.mu.Unlock()
+	f.io.Unlock()
+
+	if err != nil {
+		bp.freeFrame(i)
+		return nil, err
+	}
+	return &f.page, nil
+}
+
+// takeFrame returns an empty frame for a page about to be read or
+// created: the frame of the strategy's next ring page, a free frame, or
+// the frame of a page the replacer evicts, in that order of preference.
+func (bp *BufferPool) takeFrame(strategy *AccessStrategy) (int, error) {
+	if strategy != nil {
+		if i, ok, _ := bp.evictPage(strategy.ring[strategy.next]); ok {
+			return i, nil
+		}
+	}
+	for {
+		bp.mu.Lock()
+		if n := len(bp.freeFrames); n > 0 {
+			i := bp.freeFrames[n-1]
+			bp.freeFrames = bp.freeFrames[:n-1]
+			bp.mu.Unlock()
+			return i, nil
+		}
+		id, ok := bp.replacer.Evict()
+		bp.mu.Unlock()
+		if !ok {
+			return 0, fmt.Errorf("buffer pool full: %w", errors.New("all pages are pinned"))
+		}
+
+		i, ok, err := bp.evictPage(id)
+		if err != nil {
+			return 0, fmt.Errorf("buffer pool full: %w", err)
+		}
+		if ok {
+			return i, nil
+		}
+	}
+}
+
+// evictPage writes back and drops pageID, if it is cached and unpinned,
+// and returns its frame. ok is false if the page cannot be evicted. A
+// page that fails to write is kept, and remains evictable.
+func (bp *BufferPool) evictPage(pageID PageID) (frame int, ok bool, err error) {
+	sh := bp.shard(pageID)
+	sh.mu.Lock()
+	i, cached := sh.pages[pageID]
+	if !cached {
+		sh.mu.Unlock()
+		return 0, false, nil
+	}
+	f := &bp.frames[i]
+	if f.loading || f.page.PinCount > 0 {
+		// The page was pinned after the replacer chose it. Track it
+		// again in case the replacer already let it go.
+		if !f.loading {
+			bp.mu.Lock()
+			bp.replacer.RecordAccess(pageID)
+			bp.mu.Unlock()
+		}
+		sh.mu.Unlock()
+		return 0, false, nil
+	}
+	bp.mu.Lock()
+	bp.replacer.Remove(pageID)
+	bp.mu.Unlock()
+
+	if f.page.IsDirty {
+		f.loading = true
+		f.io.Lock()
+		sh.mu.Unlock()
+		err = bp.diskManager.WritePage(&f.page)
+		sh.mu.Lock()
+		f.loading = false
+		f.io.Unlock()
+	}
+	if err != nil {
+		bp.mu.Lock()
+		bp.replacer.RecordAccess(pageID)
+		bp.replacer.SetEvictable(pageID, true)
+		bp.mu.Unlock()
+		sh.mu.Unlock()
+		return 0, false, err
+	}
+	delete(sh.pages, pageID)
+	f.page.ID = InvalidPageID
+	f.page.IsDirty = false
+	sh.mu.Unlock()
+	bp.stats.evictions.Add(1)
+	return i, true, nil
+}
+
+func (bp *BufferPool) freeFrame(i int) {
+	bp.mu.Lock()
+	bp.freeFrames = append(bp.freeFrames, i)
+	bp.mu.Unlock()
+}
+
+// UnpinPage decrements the pin count and optionally marks dirty.
+func (bp *BufferPool) UnpinPage(pageID PageID, isDirty bool) {
+	sh := bp.shard(pageID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if i, ok := sh.pages[pageID]; ok && !bp.frames[i].loading {
+		page := &bp.frames[i].page
+		if isDirty {
+			page.IsDirty = true
+		}
+		if page.PinCount > 0 {
+			bp.recordUnpin(pageID)
+		}
+		bp.unpin(page)
+	}
+}
+
+// unpin drops a pin on a cached page; its shard lock must be held.
+func (bp *BufferPool) unpin(page *Page) {
+	if page.PinCount > 0 {
+		page.PinCount--
+		if page.PinCount == 0 {
+			bp.mu.Lock()
+			bp.replacer.SetEvictable(page.ID, true)
+			bp.mu.Unlock()
+		}
+	}
+}
+
+// FlushPage writes a dirty page to disk. It holds the page's read latch
+// while writing, so the caller must not hold its write latch.
+func (bp *BufferPool) FlushPage(pageID PageID) error {
+	sh := bp.shard(pageID)
+	sh.mu.Lock()
+	i, ok := sh.pages[pageID]
+	if !ok || bp.frames[i].loading || !bp.frames[i].page.IsDirty {
+		sh.mu.Unlock()
+		return nil
+	}
+	// Pin the page so it stays put while it is written.
+	page := &bp.frames[i].page
+	page.PinCount++
+	sh.mu.Unlock()
+
+	page.RLatch()
+	sh.mu.Lock()
+	page.IsDirty = false
+	sh.mu.Unlock()
+	err := bp.diskManager.WritePage(page)
+	page.RUnlatch()
+
+	sh.mu.Lock()
+	if err != nil {
+		page.IsDirty = true
+	}
+	bp.unpin(page)
+	sh.mu.Unlock()
+	return err
+}
+
+// NewPage allocates a new page in the buffer pool.
+func (bp *BufferPool) NewPage() (*Page, error) {
+	i, err := bp.takeFrame(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pageID, err := bp.diskManager.AllocatePage()
+	if err != nil {
+		bp.freeFrame(i)
+		return nil, err
+	}
+
+	page := &bp.frames[i].page
+	page.Clear()
+	page.ID = pageID
+	page.IsDirty = false
+
+	sh := bp.shard(pageID)
+	sh.mu.Lock()
+	page.PinCount = 1
+	sh.pages[pageID] = i
+	bp.mu.Lock()
+	bp.replacer.RecordAccess(pageID)
+	bp.mu.Unlock()
+	sh.mu.Unlock()
+
+	bp.recordPin(pageID)
+	return page, nil
+}
+
+// DeletePage drops a page from the pool without writing it back and
+// returns it to the disk manager for reuse. The page must be unpinned.
+func (bp *BufferPool) DeletePage(pageID PageID) error {
+	sh := bp.shard(pageID)
+	for {
+		sh.mu.Lock()
+		i, ok := sh.pages[pageID]
+		if !ok {
+			sh.mu.Unlock()
+			break
+		}
+		f := &bp.frames[i]
+		if f.loading {
+			sh.mu.Unlock()
+			f.io.Lock()
+			f.io.Unlock()
+			continue
+		}
+		if f.page.PinCount > 0 {
+			sh.mu.Unlock()
+			return fmt.Errorf("cannot delete page %d: page is pinned", pageID)
+		}
+		delete(sh.pages, pageID)
+		f.page.ID = InvalidPageID
+		f.page.IsDirty = false
+		bp.mu.Lock()
+		bp.replacer.Remove(pageID)
+		bp.freeFrames = append(bp.freeFrames, i)
+		bp.mu.Unlock()
+		sh.mu.Unlock()
+		break
+	}
+	bp.diskManager.DeallocatePage(pageID)
+	return nil
+}
+
+// FlushAll writes all dirty pages to disk.
+func (bp *BufferPool) FlushAll() error {
+	var ids []PageID
+	for i := range bp.shards {
+		sh := &bp.shards[i]
+		sh.mu.Lock()
+		for id := range sh.pages {
+			ids = append(ids, id)
+		}
+		sh.mu.Unlock()
+	}
+	for _, id := range ids {
+		if err := bp.FlushPage(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
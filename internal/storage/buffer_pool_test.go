@@ -0,0 +1,469 @@
+package storage_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+// newPagedFile creates a database file holding n pages, the i-th page
+// starting with the byte i%256.
+func newPagedFile(t testing.TB, n int) *storage.DiskManager {
+	t.Helper()
+	dm, err := storage.NewDiskManager(filepath.Join(t.TempDir(), "pool.db"))
+	if err != nil {
+		t.Fatalf("NewDiskManager failed: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+
+	bp := storage.NewBufferPool(16, dm)
+	for i := 0; i < n; i++ {
+		page, err := bp.NewPage()
+		if err != nil {
+			t.Fatalf("NewPage failed: %v", err)
+		}
+		page.Data[0] = byte(page.ID)
+		bp.UnpinPage(page.ID, true)
+	}
+	if err := bp.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	return dm
+}
+
+func TestBufferPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	dm := newPagedFile(t, 10)
+	bp := storage.NewBufferPool(3, dm)
+
+	fetch := func(id storage.PageID) {
+		t.Helper()
+		page, err := bp.FetchPage(id)
+		if err != nil {
+			t.Fatalf("FetchPage(%d) failed: %v", id, err)
+		}
+		if page.Data[0] != byte(id) {
+			t.Fatalf("page %d holds the data of page %d", id, page.Data[0])
+		}
+		bp.UnpinPage(id, false)
+	}
+	for _, id := range []storage.PageID{0, 1, 2, 0, 3, 0, 2} {
+		fetch(id)
+	}
+	// Page 3 evicted page 1, the least recently used; 0 and 2 were hits.
+	want := storage.BufferPoolStats{Hits: 3, Misses: 4, Evictions: 1}
+	if got := bp.Stats(); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	fetch(1)
+	if got := bp.Stats(); got.Misses != 5 || got.Evictions != 2 {
+		t.Errorf("expected page 1 to miss, got %+v", got)
+	}
+}
+
+func TestBufferPoolReusesFrames(t *testing.T) {
+	dm := newPagedFile(t, 0)
+	bp := storage.NewBufferPool(2, dm)
+
+	newPage := func() *storage.Page {
+		t.Helper()
+		page, err := bp.NewPage()
+		if err != nil {
+			t.Fatalf("NewPage failed: %v", err)
+		}
+		for i, b := range page.Data {
+			if b != 0 {
+				t.Fatalf("new page %d has byte %d set", page.ID, i)
+			}
+		}
+		for i := range page.Data {
+			page.Data[i] = 0xff
+		}
+		return page
+	}
+	a, b := newPage(), newPage()
+	bp.UnpinPage(a.ID, true)
+	bp.UnpinPage(b.ID, true)
+
+	// A deleted page's frame goes back on the free list.
+	if err := bp.DeletePage(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	c := newPage()
+	if c != a {
+		t.Errorf("expected page %d to reuse the frame of deleted page %d", c.ID, a.ID)
+	}
+	if got := bp.Stats().Evictions; got != 0 {
+		t.Errorf("expected no evictions with a free frame, got %d", got)
+	}
+
+	// An evicted page's frame is reused once written back.
+	bp.UnpinPage(c.ID, true)
+	d := newPage()
+	if d != b {
+		t.Errorf("expected page %d to reuse the frame of evicted page %d", d.ID, b.ID)
+	}
+	bp.UnpinPage(d.ID, true)
+	page, err := bp.FetchPage(b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.ID != b.ID || page.Data[0] != 0xff {
+		t.Errorf("expected page %d to be written back before its frame was reused", b.ID)
+	}
+	bp.UnpinPage(page.ID, false)
+}
+
+func TestBufferPoolReplacers(t *testing.T) {
+	for _, tc := range replacers {
+		dm := newPagedFile(t, 200)
+		bp := storage.NewBufferPoolWithReplacer(8, dm, tc.new())
+
+		// Pinned pages survive any amount of traffic.
+		var pinned []*storage.Page
+		for id := storage.PageID(0); id < 3; id++ {
+			page, err := bp.FetchPage(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pinned = append(pinned, page)
+		}
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			id := storage.PageID(3 + rng.Intn(197))
+			page, err := bp.FetchPage(id)
+			if err != nil {
+				t.Fatalf("%s: FetchPage(%d) failed: %v", tc.name, id, err)
+			}
+			if page.Data[0] != byte(id) {
+				t.Fatalf("%s: page %d holds the data of page %d", tc.name, id, page.Data[0])
+			}
+			page.Data[1]++
+			bp.UnpinPage(id, true)
+		}
+		for _, page := range pinned {
+			if again, _ := bp.FetchPage(page.ID); again != page {
+				t.Errorf("%s: pinned page %d was evicted", tc.name, page.ID)
+			}
+			bp.UnpinPage(page.ID, false)
+			bp.UnpinPage(page.ID, false)
+		}
+
+		stats := bp.Stats()
+		if stats.Hits+stats.Misses != 5006 || stats.Evictions != stats.Misses-8 {
+			t.Errorf("%s: inconsistent counters %+v", tc.name, stats)
+		}
+
+		// Filling the pool with pinned pages leaves nothing to evict.
+		for id := storage.PageID(0); id < 8; id++ {
+			if _, err := bp.FetchPage(id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := bp.FetchPage(100); err == nil {
+			t.Errorf("%s: expected a full pool of pinned pages to fail", tc.name)
+		}
+	}
+}
+
+// TestBufferPoolConcurrentAccess runs goroutines that fetch pages and
+// increment counters in them under write latches, read them under read
+// latches, scan through ring strategies, flush, and create and delete
+// pages, all through a pool too small to hold the file. Run it with
+// -race.
+func TestBufferPoolConcurrentAccess(t *testing.T) {
+	const pages, workers = 64, 16
+	ops := 2000
+	if testing.Short() {
+		ops = 300
+	}
+	for _, tc := range replacers {
+		dm := newPagedFile(t, pages)
+		// Each worker pins one page at a time, so some frames are always
+		// free to evict.
+		bp := storage.NewBufferPoolWithReplacer(workers+8, dm, tc.new())
+		var increments [pages]atomic.Int64
+		var fetches atomic.Int64
+
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rng := rand.New(rand.NewSource(seed))
+				ring := storage.NewRingStrategy(4)
+				for i := 0; i < ops; i++ {
+					id := storage.PageID(rng.Intn(pages))
+					var strategy *storage.AccessStrategy
+					op := rng.Intn(20)
+					switch {
+					case op == 0:
+						page, err := bp.NewPage()
+						if err != nil {
+							errs <- err
+							return
+						}
+						page.WLatch()
+						page.Data[0] = 0xff
+						page.WUnlatch()
+						bp.UnpinPage(page.ID, true)
+						if err := bp.DeletePage(page.ID); err != nil {
+							errs <- err
+							return
+						}
+						continue
+					case op == 1:
+						if err := bp.FlushPage(id); err != nil {
+							errs <- err
+							return
+						}
+						continue
+					case op < 5:
+						id = storage.PageID(i % pages)
+						strategy = ring
+					}
+
+					page, err := bp.FetchPageWithStrategy(id, strategy)
+					if err != nil {
+						errs <- err
+						return
+					}
+					fetches.Add(1)
+					if op < 12 {
+						page.RLatch()
+						got := page.Data[0]
+						page.RUnlatch()
+						bp.UnpinPage(id, false)
+						if got != byte(id) {
+							errs <- fmt.Errorf("fetched page %d holds page %d", id, got)
+							return
+						}
+						continue
+					}
+					page.WLatch()
+					n := binary.BigEndian.Uint64(page.Data[8:])
+					binary.BigEndian.PutUint64(page.Data[8:], n+1)
+					page.WUnlatch()
+					increments[id].Add(1)
+					bp.UnpinPage(id, true)
+				}
+			}(int64(w))
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if stats := bp.Stats(); stats.Hits+stats.Misses != fetches.Load() {
+			t.Errorf("%s: %d hits and %d misses for %d fetches", tc.name, stats.Hits, stats.Misses, fetches.Load())
+		}
+		if err := bp.FlushAll(); err != nil {
+			t.Fatal(err)
+		}
+		// Every increment reached the disk, and every pin was released.
+		fresh := storage.NewBufferPool(4, dm)
+		for id := storage.PageID(0); id < pages; id++ {
+			page, err := fresh.FetchPage(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := binary.BigEndian.Uint64(page.Data[8:]), increments[id].Load(); got != uint64(want) {
+				t.Errorf("%s: page %d: expected %d increments, found %d", tc.name, id, want, got)
+			}
+			fresh.UnpinPage(id, false)
+
+			page, err = bp.FetchPage(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.PinCount != 1 {
+				t.Errorf("%s: page %d left with %d pins", tc.name, id, page.PinCount-1)
+			}
+			bp.UnpinPage(id, false)
+		}
+	}
+}
+
+// TestFetchPageAllocs checks that once every frame has held a page,
+// fetches reuse frames and allocate nothing, whether they hit or miss.
+func TestFetchPageAllocs(t *testing.T) {
+	dm := newPagedFile(t, 64)
+	for _, tc := range replacers {
+		bp := storage.NewBufferPoolWithReplacer(16, dm, tc.new())
+		fetch := func(id storage.PageID) {
+			if _, err := bp.FetchPage(id); err != nil {
+				t.Fatal(err)
+			}
+			bp.UnpinPage(id, false)
+		}
+		// Fill the pool and let the replacer's bookkeeping reach its
+		// working size.
+		for i := 0; i < 1000; i++ {
+			fetch(storage.PageID(i % 64))
+		}
+
+		next := 0
+		if allocs := testing.AllocsPerRun(1000, func() {
+			fetch(storage.PageID(next % 64))
+			next++
+		}); allocs != 0 {
+			t.Errorf("%s: expected no allocations per miss, got %g", tc.name, allocs)
+		}
+		if allocs := testing.AllocsPerRun(1000, func() { fetch(storage.PageID((next - 1) % 64)) }); allocs != 0 {
+			t.Errorf("%s: expected no allocations per hit, got %g", tc.name, allocs)
+		}
+	}
+}
+
+// Hot pages read by point lookups, and the rest of the file.
+const (
+	benchPages    = 2048
+	benchHotPages = 96
+	benchPoolSize = 128
+)
+
+// benchmarkWorkload replays a page access pattern against each replacer
+// and reports the pool's hit rate.
+func benchmarkWorkload(b *testing.B, next func(rng *rand.Rand, i int) storage.PageID) {
+	dm := newPagedFile(b, benchPages)
+	for _, tc := range replacers {
+		b.Run(tc.name, func(b *testing.B) {
+			bp := storage.NewBufferPoolWithReplacer(benchPoolSize, dm, tc.new())
+			rng := rand.New(rand.NewSource(1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := next(rng, i)
+				if _, err := bp.FetchPage(id); err != nil {
+					b.Fatal(err)
+				}
+				bp.UnpinPage(id, false)
+			}
+			b.ReportMetric(100*bp.Stats().HitRate(), "hit%")
+		})
+	}
+}
+
+// BenchmarkPointLookups sends 90% of accesses to a hot set that fits in
+// the pool and the rest anywhere in the file.
+func BenchmarkPointLookups(b *testing.B) {
+	benchmarkWorkload(b, func(rng *rand.Rand, i int) storage.PageID {
+		if rng.Intn(10) == 0 {
+			return storage.PageID(rng.Intn(benchPages))
+		}
+		return storage.PageID(rng.Intn(benchHotPages))
+	})
+}
+
+// BenchmarkScanWithLookups interleaves the same hot point lookups with
+// sequential scans of the whole file, which flood an LRU pool with
+// pages that are never read again.
+func BenchmarkScanWithLookups(b *testing.B) {
+	benchmarkWorkload(b, func(rng *rand.Rand, i int) storage.PageID {
+		if i%2 == 0 {
+			return storage.PageID(rng.Intn(benchHotPages))
+		}
+		return storage.PageID(benchHotPages + (i/2)%(benchPages-benchHotPages))
+	})
+}
+
+func TestScanStrategy(t *testing.T) {
+	dm := newPagedFile(t, 0)
+	bp := storage.NewBufferPool(40, dm)
+
+	// Eight hot pages, standing in for the upper levels of an index.
+	var hot []storage.PageID
+	for i := 0; i < 8; i++ {
+		page, err := bp.NewPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hot = append(hot, page.ID)
+		bp.UnpinPage(page.ID, true)
+	}
+	// A heap several times the size of the pool.
+	th, err := storage.NewTableHeap(bp, storage.InvalidPageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const rows = 1000
+	for i := 0; i < rows; i++ {
+		if _, err := th.InsertTuple(make([]byte, 500)); err != nil {
+			t.Fatalf("InsertTuple(%d) failed: %v", i, err)
+		}
+	}
+
+	// fetchHot reads the hot pages and returns how many missed.
+	fetchHot := func() int64 {
+		t.Helper()
+		before := bp.Stats().Misses
+		for _, id := range hot {
+			if _, err := bp.FetchPage(id); err != nil {
+				t.Fatal(err)
+			}
+			bp.UnpinPage(id, false)
+		}
+		return bp.Stats().Misses - before
+	}
+	scan := func(it *storage.TableIterator) {
+		t.Helper()
+		count := 0
+		for {
+			data, _, err := it.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data == nil {
+				break
+			}
+			count++
+		}
+		if count != rows {
+			t.Fatalf("expected %d rows, got %d", rows, count)
+		}
+	}
+
+	fetchHot()
+	it := th.ScanIterator()
+	scan(it)
+	if missed := fetchHot(); missed != 0 {
+		t.Errorf("expected a ring scan to leave the hot pages cached, %d missed", missed)
+	}
+	it.Rewind()
+	scan(it)
+	if missed := fetchHot(); missed != 0 {
+		t.Errorf("expected a rewound ring scan to leave the hot pages cached, %d missed", missed)
+	}
+
+	scan(th.Iterator())
+	if missed := fetchHot(); missed != int64(len(hot)) {
+		t.Errorf("expected a plain scan to evict the hot pages, %d of %d missed", missed, len(hot))
+	}
+}
+
+// BenchmarkFetchPageMiss cycles through twice as many pages as the pool
+// holds, so that every fetch evicts a page and reuses its frame.
+func BenchmarkFetchPageMiss(b *testing.B) {
+	dm := newPagedFile(b, 2*benchPoolSize)
+	for _, tc := range replacers {
+		b.Run(tc.name, func(b *testing.B) {
+			bp := storage.NewBufferPoolWithReplacer(benchPoolSize, dm, tc.new())
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := storage.PageID(i % (2 * benchPoolSize))
+				if _, err := bp.FetchPage(id); err != nil {
+					b.Fatal(err)
+				}
+				bp.UnpinPage(id, false)
+			}
+		})
+	}
+}
@@ -1,51 +1,70 @@
-package storage
-
-import "encoding/binary"
-
-const (
-	PageSize      = 4096
-	InvalidPageID PageID = -1
-)
-
-// PageID uniquely identifies a page on disk.
-type PageID int64
-
-// Page represents a fixed-size block of data.
-type Page struct {
-	ID       PageID
-	PinCount int32
-	IsDirty  bool
-	Data     [PageSize]byte
-}
-
-// NewPage creates a new empty page.
-func NewPage(id PageID) *Page {
-	return &Page{ID: id}
-}
-
-// GetData returns the page data as a byte slice.
-func (p *Page) GetData() []byte {
-	return p.Data[:]
-}
-
-// Copy copies data into the page.
-func (p *Page) Copy(data []byte) {
-	copy(p.Data[:], data)
-}
-
-// Clear zeros out the page data.
-func (p *Page) Clear() {
-	for i := range p.Data {
-		p.Data[i] = 0
-	}
-}
-
-// SetInt writes an int32 at the given offset.
-func (p *Page) SetInt(offset int, val int32) {
-	binary.BigEndian.PutUint32(p.Data[offset:], uint32(val))
-}
-
-// GetInt reads an int32 from the given offset.
-func (p *Page) GetInt(offset int) int32 {
-	return int32(binary.BigEndian.Uint32(p.Data[offset:]))
-}
+package storage
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+const (
+	PageSize      = 4096
+	InvalidPageID PageID = -1
+)
+
+// PageID uniquely identifies a page on disk.
+type PageID int64
+
+// Page represents a fixed-size block of data. Goroutines sharing a page
+// through the buffer pool coordinate with its latch: RLatch to read the
+// data and WLatch to modify it, held only while the page is pinned.
+type Page struct {
+	ID       PageID
+	PinCount int32
+	IsDirty  bool
+	Data     [PageSize]byte
+	latch    sync.RWMutex
+}
+
+// NewPage creates a new empty page.
+func NewPage(id PageID) *Page {
+	return &Page{ID: id}
+}
+
+// GetData returns the page data as a byte slice.
+func (p *Page) GetData() []byte {
+	return p.Data[:]
+}
+
+// Copy copies data into the page.
+func (p *Page) Copy(data []byte) {
+	copy(p.Data[:], data)
+}
+
+// Clear zeros out the page data.
+func (p *Page) Clear() {
+	for i := range p.Data {
+		p.Data[i] = 0
+	}
+}
+
+// SetInt writes an int32 at the given offset.
+func (p *Page) SetInt(offset int, val int32) {
+	binary.BigEndian.PutUint32(p.Data[offset:], uint32(val))
+}
+
+// GetInt reads an int32 from the given offset.
+func (p *Page) GetInt(offset int) int32 {
+	return int32(binary.BigEndian.Uint32(p.Data[offset:]))
+}
+
+// RLatch acquires the page's latch for reading.
+func (p *Page) RLatch() { p.latch.RLock() }
+
+// RUnlatch releases a read latch.
+func (p *Page) RUnlatch() { p.latch.RUnlock() }
+
+// WLatch acquires the page's latch for writing, excluding readers and
+// other writers.
+func (p *Page) WLatch() { p.latch.Lock() }
+
+// WUnlatch releases a write latch.
+func (p *Page) WUnlatch() { p.latch.Unlock() }
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ReadPageGuard holds a page pinned and read-latched until Drop is
+// called. The usual pattern is
+//
+//	guard, err := bp.FetchPageRead(id)
+//	if err != nil {
+//		return err
+//	}
+//	defer guard.Drop()
+//
+// so that the page is released on every path.
+type ReadPageGuard struct {
+	bp   *BufferPool
+	page *Page
+}
+
+// FetchPageRead fetches a page and read-latches it.
+func (bp *BufferPool) FetchPageRead(pageID PageID) (ReadPageGuard, error) {
+	page, err := bp.FetchPage(pageID)
+	if err != nil {
+		return ReadPageGuard{}, err
+	}
+	page.RLatch()
+	return ReadPageGuard{bp: bp, page: page}, nil
+}
+
+// FetchPageReadWithStrategy is like FetchPageRead but fetches the page
+// through FetchPageWithStrategy.
+func (bp *BufferPool) FetchPageReadWithStrategy(pageID PageID, strategy *AccessStrategy) (ReadPageGuard, error) {
+	page, err := bp.FetchPageWithStrategy(pageID, strategy)
+	if err != nil {
+		return ReadPageGuard{}, err
+	}
+	page.RLatch()
+	return ReadPageGuard{bp: bp, page: page}, nil
+}
+
+// Page returns the guarded page, which must not be modified.
+func (g *ReadPageGuard) Page() *Page { return g.page }
+
+// PageID returns the ID of the guarded page.
+func (g *ReadPageGuard) PageID() PageID { return g.page.ID }
+
+// Drop releases the latch and the pin. Dropping a guard twice, or the
+// zero guard, does nothing.
+func (g *ReadPageGuard) Drop() {
+	if g.page == nil {
+		return
+	}
+	g.page.RUnlatch()
+	g.bp.UnpinPage(g.page.ID, false)
+	g.page = nil
+}
+
+// WritePageGuard holds a page pinned and write-latched until Drop is
+// called, which marks the page dirty.
+type WritePageGuard struct {
+	bp   *BufferPool
+	page *Page
+}
+
+// FetchPageWrite fetches a page and write-latches it.
+func (bp *BufferPool) FetchPageWrite(pageID PageID) (WritePageGuard, error) {
+	page, err := bp.FetchPage(pageID)
+	if err != nil {
+		return WritePageGuard{}, err
+	}
+	page.WLatch()
+	return WritePageGuard{bp: bp, page: page}, nil
+}
+
+// NewPageGuarded allocates a new page like NewPage and write-latches
+// it.
+func (bp *BufferPool) NewPageGuarded() (WritePageGuard, error) {
+	page, err := bp.NewPage()
+	if err != nil {
+		return WritePageGuard{}, err
+	}
+	page.WLatch()
+	return WritePageGuard{bp: bp, page: page}, nil
+}
+
+// Page returns the guarded page.
+func (g *WritePageGuard) Page() *Page { return g.page }
+
+// PageID returns the ID of the guarded page.
+func (g *WritePageGuard) PageID() PageID { return g.page.ID }
+
+// Drop releases the latch and the pin and marks the page dirty.
+// Dropping a guard twice, or the zero guard, does nothing.
+func (g *WritePageGuard) Drop() {
+	if g.page == nil {
+		return
+	}
+	g.page.WUnlatch()
+	g.bp.UnpinPage(g.page.ID, true)
+	g.page = nil
+}
+
+// pinTracker records where each pin was taken while pin tracking is on.
+type pinTracker struct {
+	on   atomic.Bool
+	mu   sync.Mutex
+	pins map[PageID][][]uintptr
+}
+
+// TrackPins turns pin tracking on or off. While it is on, the pool
+// records the call stack that took each pin, so that LeakedPins can
+// report pins that were never released. Tracking slows every fetch and
+// is meant for tests and debugging.
+func (bp *BufferPool) TrackPins(on bool) {
+	bp.tracker.mu.Lock()
+	defer bp.tracker.mu.Unlock()
+	bp.tracker.pins = nil
+	if on {
+		bp.tracker.pins = make(map[PageID][][]uintptr)
+	}
+	bp.tracker.on.Store(on)
+}
+
+// LeakedPins returns an error listing the pins taken since tracking was
+// turned on that are still held, each with the stack that took it, or
+// nil if there are none. When a page was pinned more than once, the
+// most recent pins are taken to be the ones released first.
+func (bp *BufferPool) LeakedPins() error {
+	bp.tracker.mu.Lock()
+	defer bp.tracker.mu.Unlock()
+
+	var b strings.Builder
+	count := 0
+	for id, stacks := range bp.tracker.pins {
+		for _, stack := range stacks {
+			count++
+			fmt.Fprintf(&b, "\npage %d pinned at:", id)
+			frames := runtime.CallersFrames(stack)
+			for {
+				frame, more := frames.Next()
+				// Start at the caller of the pool.
+				if !strings.Contains(frame.Function, "storage.(*BufferPool).") &&
+					!strings.Contains(frame.Function, "storage.(*ReadPageGuard).") &&
+					!strings.Contains(frame.Function, "storage.(*WritePageGuard).") {
+					fmt.Fprintf(&b, "\n\t%s\n\t\t%s:%d", frame.Function, frame.File, frame.Line)
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d pins leaked:%s", count, b.String())
+}
+
+// recordPin notes a pin of pageID if tracking is on.
+func (bp *BufferPool) recordPin(pageID PageID) {
+	if !bp.tracker.on.Load() {
+		return
+	}
+	stack := make([]uintptr, 32)
+	stack = stack[:runtime.Callers(2, stack)]
+	bp.tracker.mu.Lock()
+	if bp.tracker.pins != nil {
+		bp.tracker.pins[pageID] = append(bp.tracker.pins[pageID], stack)
+	}
+	bp.tracker.mu.Unlock()
+}
+
+// recordUnpin forgets the most recent tracked pin of pageID.
+func (bp *BufferPool) recordUnpin(pageID PageID) {
+	if !bp.tracker.on.Load() {
+		return
+	}
+	bp.tracker.mu.Lock()
+	defer bp.tracker.mu.Unlock()
+	stacks := bp.tracker.pins[pageID]
+	switch len(stacks) {
+	case 0:
+	case 1:
+		delete(bp.tracker.pins, pageID)
+	default:
+		bp.tracker.pins[pageID] = stacks[:len(stacks)-1]
+	}
+}
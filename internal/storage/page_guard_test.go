@@ -0,0 +1,118 @@
+package storage_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+func TestPageGuards(t *testing.T) {
+	bp := storage.NewBufferPool(4, newPagedFile(t, 2))
+
+	read, err := bp.FetchPageRead(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := read.Page()
+	if page.Data[0] != 0 || page.PinCount != 1 {
+		t.Fatalf("expected page 0 pinned once, got byte %d with %d pins", page.Data[0], page.PinCount)
+	}
+	read.Drop()
+	read.Drop()
+	if page.PinCount != 0 || page.IsDirty {
+		t.Fatalf("expected a clean unpinned page after Drop, got %d pins, dirty %v", page.PinCount, page.IsDirty)
+	}
+
+	write, err := bp.FetchPageWrite(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page = write.Page()
+	page.Data[1] = 42
+	write.Drop()
+	write.Drop()
+	if page.PinCount != 0 || !page.IsDirty {
+		t.Fatalf("expected a dirty unpinned page after Drop, got %d pins, dirty %v", page.PinCount, page.IsDirty)
+	}
+
+	created, err := bp.NewPageGuarded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.PageID()
+	created.Drop()
+	if err := bp.DeletePage(id); err != nil {
+		t.Fatalf("expected new page to be unpinned after Drop: %v", err)
+	}
+
+	// Dropping the zero guard does nothing.
+	var zero storage.ReadPageGuard
+	zero.Drop()
+}
+
+func TestLeakedPins(t *testing.T) {
+	bp := storage.NewBufferPool(8, newPagedFile(t, 0))
+	bp.TrackPins(true)
+
+	th, err := storage.NewTableHeap(bp, storage.InvalidPageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rids []storage.RID
+	for i := 0; i < 200; i++ {
+		rid, err := th.InsertTuple(bytes.Repeat([]byte{byte(i)}, 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rids = append(rids, rid)
+	}
+	if _, err := th.UpdateTuple(rids[0], bytes.Repeat([]byte("z"), 500)); err != nil {
+		t.Fatal(err)
+	}
+	if err := th.DeleteTuple(rids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := th.GetTuple(rids[1]); err == nil {
+		t.Fatalf("expected deleted tuple to be gone")
+	}
+	it := th.ScanIterator()
+	for {
+		data, _, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data == nil {
+			break
+		}
+	}
+	if err := bp.LeakedPins(); err != nil {
+		t.Fatalf("expected no leaked pins, got %v", err)
+	}
+
+	// A pin that is never released is reported with the stack that
+	// took it.
+	if _, err := bp.FetchPage(rids[2].PageID); err != nil {
+		t.Fatal(err)
+	}
+	err = bp.LeakedPins()
+	if err == nil {
+		t.Fatalf("expected a leaked pin to be reported")
+	}
+	msg := err.Error()
+	for _, want := range []string{"1 pins leaked", fmt.Sprintf("page %d pinned at", rids[2].PageID), "TestLeakedPins", "page_guard_test.go"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in %q", want, msg)
+		}
+	}
+	if strings.Contains(msg, "FetchPage") {
+		t.Errorf("expected buffer pool frames to be left out of %q", msg)
+	}
+
+	bp.UnpinPage(rids[2].PageID, false)
+	if err := bp.LeakedPins(); err != nil {
+		t.Fatalf("expected no leaked pins after unpinning, got %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package storage
+
+import "math"
+
+// Replacer decides which cached page the buffer pool evicts when it
+// needs room. The pool reports every access to a page and whether the
+// page may be evicted, which it may be while nothing has it pinned.
+// Replacers are not safe for concurrent use; the pool serializes calls.
+type Replacer interface {
+	// RecordAccess notes a fetch of the page, starting to track it if
+	// it is new.
+	RecordAccess(id PageID)
+	// SetEvictable marks whether a tracked page may be evicted.
+	SetEvictable(id PageID, evictable bool)
+	// Evict chooses an evictable page and stops tracking it. ok is
+	// false if no page is evictable.
+	Evict() (id PageID, ok bool)
+	// Remove stops tracking a page, whether or not it is evictable.
+	Remove(id PageID)
+	// Size returns the number of evictable pages.
+	Size() int
+}
+
+// LRUReplacer evicts the evictable page that was accessed least
+// recently.
+type LRUReplacer struct {
+	// head links every tracked page in a ring, least recently accessed
+	// first after head.
+	head      lruEntry
+	entries   map[PageID]*lruEntry
+	free      *lruEntry // untracked entries for reuse, linked by next
+	evictable int
+}
+
+type lruEntry struct {
+	id         PageID
+	evictable  bool
+	prev, next *lruEntry
+}
+
+// NewLRUReplacer creates an empty LRU replacer.
+func NewLRUReplacer() *LRUReplacer {
+	r := &LRUReplacer{entries: make(map[PageID]*lruEntry)}
+	r.head.prev, r.head.next = &r.head, &r.head
+	return r
+}
+
+func (r *LRUReplacer) RecordAccess(id PageID) {
+	entry, ok := r.entries[id]
+	if ok {
+		entry.prev.next, entry.next.prev = entry.next, entry.prev
+	} else if entry = r.free; entry != nil {
+		r.free = entry.next
+		*entry = lruEntry{id: id}
+		r.entries[id] = entry
+	} else {
+		entry = &lruEntry{id: id}
+		r.entries[id] = entry
+	}
+	entry.prev, entry.next = r.head.prev, &r.head
+	r.head.prev.next = entry
+	r.head.prev = entry
+}
+
+func (r *LRUReplacer) SetEvictable(id PageID, evictable bool) {
+	entry, ok := r.entries[id]
+	if !ok {
+		return
+	}
+	if entry.evictable != evictable {
+		entry.evictable = evictable
+		if evictable {
+			r.evictable++
+		} else {
+			r.evictable--
+		}
+	}
+}
+
+// Evict walks the pages from least recently accessed, passing over the
+// pinned ones.
+func (r *LRUReplacer) Evict() (PageID, bool) {
+	for entry := r.head.next; entry != &r.head; entry = entry.next {
+		if entry.evictable {
+			id := entry.id
+			r.Remove(id)
+			return id, true
+		}
+	}
+	return InvalidPageID, false
+}
+
+func (r *LRUReplacer) Remove(id PageID) {
+	entry, ok := r.entries[id]
+	if !ok {
+		return
+	}
+	if entry.evictable {
+		r.evictable--
+	}
+	entry.prev.next, entry.next.prev = entry.next, entry.prev
+	delete(r.entries, id)
+	entry.prev, entry.next = nil, r.free
+	r.free = entry
+}
+
+func (r *LRUReplacer) Size() int { return r.evictable }
+
+// ClockReplacer approximates LRU with the CLOCK algorithm: tracked
+// pages sit on a ring with a reference bit that each access sets. The
+// clock hand sweeps the ring, clearing set bits, and evicts the first
+// evictable page whose bit is already clear.
+type ClockReplacer struct {
+	ring      []clockEntry
+	slots     map[PageID]int
+	free      []int
+	hand      int
+	evictable int
+}
+
+type clockEntry struct {
+	id         PageID
+	used       bool
+	referenced bool
+	evictable  bool
+}
+
+// NewClockReplacer creates an empty CLOCK replacer. capacity is the
+// number of pages it is expected to track, normally the pool's size.
+func NewClockReplacer(capacity int) *ClockReplacer {
+	return &ClockReplacer{
+		ring:  make([]clockEntry, 0, capacity),
+		slots: make(map[PageID]int, capacity),
+	}
+}
+
+func (r *ClockReplacer) RecordAccess(id PageID) {
+	if slot, ok := r.slots[id]; ok {
+		r.ring[slot].referenced = true
+		return
+	}
+	entry := clockEntry{id: id, used: true, referenced: true}
+	if n := len(r.free); n > 0 {
+		slot := r.free[n-1]
+		r.free = r.free[:n-1]
+		r.ring[slot] = entry
+		r.slots[id] = slot
+		return
+	}
+	r.ring = append(r.ring, entry)
+	r.slots[id] = len(r.ring) - 1
+}
+
+func (r *ClockReplacer) SetEvictable(id PageID, evictable bool) {
+	slot, ok := r.slots[id]
+	if !ok {
+		return
+	}
+	entry := &r.ring[slot]
+	if entry.evictable != evictable {
+		entry.evictable = evictable
+		if evictable {
+			r.evictable++
+		} else {
+			r.evictable--
+		}
+	}
+}
+
+// Evict sweeps at most twice around the ring: once to clear reference
+// bits and once more to find a page whose bit it cleared.
+func (r *ClockReplacer) Evict() (PageID, bool) {
+	if r.evictable == 0 {
+		return InvalidPageID, false
+	}
+	for range 2*len(r.ring) + 1 {
+		entry := &r.ring[r.hand]
+		r.hand = (r.hand + 1) % len(r.ring)
+		if !entry.used || !entry.evictable {
+			continue
+		}
+		if entry.referenced {
+			entry.referenced = false
+			continue
+		}
+		id := entry.id
+		r.Remove(id)
+		return id, true
+	}
+	return InvalidPageID, false
+}
+
+func (r *ClockReplacer) Remove(id PageID) {
+	slot, ok := r.slots[id]
+	if !ok {
+		return
+	}
+	if r.ring[slot].evictable {
+		r.evictable--
+	}
+	r.ring[slot] = clockEntry{}
+	r.free = append(r.free, slot)
+	delete(r.slots, id)
+}
+
+func (r *ClockReplacer) Size() int { return r.evictable }
+
+// LRUKReplacer implements LRU-K: it evicts the evictable page whose K-th
+// most recent access is furthest in the past. Pages accessed fewer than
+// K times count as infinitely far and go first, earliest first access
+// first. A page read once by a scan is therefore evicted before pages
+// that are accessed repeatedly.
+type LRUKReplacer struct {
+	k         int
+	now       uint64
+	pages     map[PageID]*lruKEntry
+	free      []*lruKEntry // untracked entries for reuse
+	evictable int
+}
+
+type lruKEntry struct {
+	// history holds the page's last k access times, oldest first.
+	history   []uint64
+	evictable bool
+}
+
+// NewLRUKReplacer creates an empty LRU-K replacer; k must be at least 1.
+// LRU-1 is plain LRU.
+func NewLRUKReplacer(k int) *LRUKReplacer {
+	return &LRUKReplacer{k: max(k, 1), pages: make(map[PageID]*lruKEntry)}
+}
+
+func (r *LRUKReplacer) RecordAccess(id PageID) {
+	r.now++
+	entry, ok := r.pages[id]
+	if !ok {
+		if n := len(r.free); n > 0 {
+			entry = r.free[n-1]
+			r.free = r.free[:n-1]
+		} else {
+			entry = &lruKEntry{history: make([]uint64, 0, r.k)}
+		}
+		r.pages[id] = entry
+	}
+	if len(entry.history) == r.k {
+		copy(entry.history, entry.history[1:])
+		entry.history = entry.history[:r.k-1]
+	}
+	entry.history = append(entry.history, r.now)
+}
+
+func (r *LRUKReplacer) SetEvictable(id PageID, evictable bool) {
+	entry, ok := r.pages[id]
+	if !ok {
+		return
+	}
+	if entry.evictable != evictable {
+		entry.evictable = evictable
+		if evictable {
+			r.evictable++
+		} else {
+			r.evictable--
+		}
+	}
+}
+
+// Evict scans the tracked pages for the largest backward K-distance.
+func (r *LRUKReplacer) Evict() (PageID, bool) {
+	victim := InvalidPageID
+	var victimDist, victimOldest uint64
+	for id, entry := range r.pages {
+		if !entry.evictable {
+			continue
+		}
+		dist := uint64(math.MaxUint64)
+		if len(entry.history) == r.k {
+			dist = r.now - entry.history[0]
+		}
+		oldest := entry.history[0]
+		if victim == InvalidPageID || dist > victimDist || (dist == victimDist && oldest < victimOldest) {
+			victim, victimDist, victimOldest = id, dist, oldest
+		}
+	}
+	if victim == InvalidPageID {
+		return InvalidPageID, false
+	}
+	r.Remove(victim)
+	return victim, true
+}
+
+func (r *LRUKReplacer) Remove(id PageID) {
+	entry, ok := r.pages[id]
+	if !ok {
+		return
+	}
+	if entry.evictable {
+		r.evictable--
+	}
+	delete(r.pages, id)
+	entry.history = entry.history[:0]
+	entry.evictable = false
+	r.free = append(r.free, entry)
+}
+
+func (r *LRUKReplacer) Size() int { return r.evictable }
@@ -0,0 +1,122 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/benkivuva/my-rdbms/internal/storage"
+)
+
+var replacers = []struct {
+	name string
+	new  func() storage.Replacer
+}{
+	{"lru", func() storage.Replacer { return storage.NewLRUReplacer() }},
+	{"clock", func() storage.Replacer { return storage.NewClockReplacer(8) }},
+	{"lru-2", func() storage.Replacer { return storage.NewLRUKReplacer(2) }},
+}
+
+// evictAll evicts until the replacer has nothing left to evict.
+func evictAll(r storage.Replacer) []storage.PageID {
+	var out []storage.PageID
+	for {
+		id, ok := r.Evict()
+		if !ok {
+			return out
+		}
+		out = append(out, id)
+	}
+}
+
+func equalIDs(a, b []storage.PageID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReplacerContract(t *testing.T) {
+	for _, tc := range replacers {
+		r := tc.new()
+		for id := storage.PageID(1); id <= 5; id++ {
+			r.RecordAccess(id)
+			r.SetEvictable(id, true)
+		}
+		r.SetEvictable(2, false)
+		r.SetEvictable(2, false)
+		r.Remove(4)
+		r.SetEvictable(9, true) // untracked pages are ignored
+		if r.Size() != 3 {
+			t.Errorf("%s: expected 3 evictable pages, got %d", tc.name, r.Size())
+		}
+
+		got := evictAll(r)
+		if len(got) != 3 || r.Size() != 0 {
+			t.Fatalf("%s: expected 3 evictions, got %v", tc.name, got)
+		}
+		for _, id := range got {
+			if id == 2 || id == 4 {
+				t.Errorf("%s: evicted page %d, which is pinned or removed", tc.name, id)
+			}
+		}
+
+		r.SetEvictable(2, true)
+		if id, ok := r.Evict(); !ok || id != 2 {
+			t.Errorf("%s: expected page 2 once unpinned, got %d, %v", tc.name, id, ok)
+		}
+	}
+}
+
+func TestReplacerOrder(t *testing.T) {
+	cases := []struct {
+		replacer storage.Replacer
+		accesses []storage.PageID
+		want     []storage.PageID
+	}{
+		// Least recently accessed first.
+		{storage.NewLRUReplacer(), []storage.PageID{1, 2, 3, 1}, []storage.PageID{2, 3, 1}},
+		// The first sweep clears every bit and the second evicts in ring
+		// order.
+		{storage.NewClockReplacer(4), []storage.PageID{1, 2, 3}, []storage.PageID{1, 2, 3}},
+		// Pages seen once go first, then the oldest second-to-last access.
+		{storage.NewLRUKReplacer(2), []storage.PageID{1, 2, 3, 2, 3, 4, 1}, []storage.PageID{4, 1, 2, 3}},
+		{storage.NewLRUKReplacer(2), []storage.PageID{1, 2, 3}, []storage.PageID{1, 2, 3}},
+	}
+	for i, tc := range cases {
+		for _, id := range tc.accesses {
+			tc.replacer.RecordAccess(id)
+			tc.replacer.SetEvictable(id, true)
+		}
+		if got := evictAll(tc.replacer); !equalIDs(got, tc.want) {
+			t.Errorf("case %d: expected eviction order %v, got %v", i, tc.want, got)
+		}
+	}
+}
+
+func TestClockSecondChance(t *testing.T) {
+	r := storage.NewClockReplacer(4)
+	for id := storage.PageID(1); id <= 3; id++ {
+		r.RecordAccess(id)
+		r.SetEvictable(id, true)
+	}
+	if id, _ := r.Evict(); id != 1 {
+		t.Fatalf("expected page 1, got %d", id)
+	}
+	// Page 2 is referenced again after the sweep cleared its bit, so the
+	// hand passes it once more and takes page 3.
+	r.RecordAccess(2)
+	if id, _ := r.Evict(); id != 3 {
+		t.Fatalf("expected page 3, got %d", id)
+	}
+	// A new page takes a freed slot with its bit set, so the hand
+	// reaches page 2, whose bit is clear, first.
+	r.RecordAccess(4)
+	r.SetEvictable(4, true)
+	if got := evictAll(r); !equalIDs(got, []storage.PageID{2, 4}) {
+		t.Errorf("expected pages 2 and 4, got %v", got)
+	}
+}
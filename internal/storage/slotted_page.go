@@ -1,113 +1,147 @@
-package storage
-
-import (
-	"encoding/binary"
-	"fmt"
-)
-
-// SlottedPage Layout (4KB):
-// Header (12 bytes):
-//   [0-7]:   NextPageID (int64)
-//   [8-9]:   NumSlots (uint16)
-//   [10-11]: FreeSpacePointer (uint16)
-// Slot Array: [Offset(2), Length(2)] per slot
-// Tuple Data: grows from end of page toward header
-
-const (
-	OffsetNextPageID = 0
-	OffsetNumSlots   = 8
-	OffsetFreeSpace  = 10
-	SizeOfHeader     = 12
-	SizeOfSlot       = 4
-)
-
-// SlottedPage provides tuple storage within a fixed-size page.
-type SlottedPage struct {
-	page *Page
-}
-
-// NewSlottedPage wraps a page for slotted access.
-func NewSlottedPage(page *Page) *SlottedPage {
-	sp := &SlottedPage{page: page}
-	if sp.GetNumSlots() == 0 && sp.GetFreeSpacePointer() == 0 {
-		sp.SetFreeSpacePointer(PageSize)
-	}
-	return sp
-}
-
-func (sp *SlottedPage) GetNumSlots() uint16 {
-	return binary.BigEndian.Uint16(sp.page.Data[OffsetNumSlots:])
-}
-
-func (sp *SlottedPage) SetNumSlots(num uint16) {
-	binary.BigEndian.PutUint16(sp.page.Data[OffsetNumSlots:], num)
-}
-
-func (sp *SlottedPage) GetFreeSpacePointer() uint16 {
-	return binary.BigEndian.Uint16(sp.page.Data[OffsetFreeSpace:])
-}
-
-func (sp *SlottedPage) SetFreeSpacePointer(ptr uint16) {
-	binary.BigEndian.PutUint16(sp.page.Data[OffsetFreeSpace:], ptr)
-}
-
-func (sp *SlottedPage) GetSlot(slotIdx int) (uint16, uint16) {
-	offset := SizeOfHeader + slotIdx*SizeOfSlot
-	sOff := binary.BigEndian.Uint16(sp.page.Data[offset:])
-	sLen := binary.BigEndian.Uint16(sp.page.Data[offset+2:])
-	return sOff, sLen
-}
-
-func (sp *SlottedPage) SetSlot(slotIdx int, sOff, sLen uint16) {
-	offset := SizeOfHeader + slotIdx*SizeOfSlot
-	binary.BigEndian.PutUint16(sp.page.Data[offset:], sOff)
-	binary.BigEndian.PutUint16(sp.page.Data[offset+2:], sLen)
-}
-
-// InsertTuple adds data to the page. Returns slot ID or error if full.
-func (sp *SlottedPage) InsertTuple(data []byte) (int, error) {
-	needed := len(data)
-	if needed > PageSize {
-		return -1, fmt.Errorf("tuple too large")
-	}
-
-	numSlots := int(sp.GetNumSlots())
-	freePtr := int(sp.GetFreeSpacePointer())
-	usedHeader := SizeOfHeader + numSlots*SizeOfSlot
-	available := freePtr - usedHeader
-
-	if available < SizeOfSlot+needed {
-		return -1, fmt.Errorf("no space")
-	}
-
-	newFreePtr := freePtr - needed
-	copy(sp.page.Data[newFreePtr:freePtr], data)
-	sp.SetFreeSpacePointer(uint16(newFreePtr))
-
-	sp.SetSlot(numSlots, uint16(newFreePtr), uint16(needed))
-	sp.SetNumSlots(uint16(numSlots + 1))
-
-	return numSlots, nil
-}
-
-// GetTuple reads data from the given slot.
-func (sp *SlottedPage) GetTuple(slotIdx int) []byte {
-	if slotIdx >= int(sp.GetNumSlots()) {
-		return nil
-	}
-	off, length := sp.GetSlot(slotIdx)
-	if length == 0 {
-		return nil
-	}
-	return sp.page.Data[off : off+length]
-}
-
-// DeleteTuple marks a slot as deleted by setting its length to 0.
-func (sp *SlottedPage) DeleteTuple(slotIdx int) bool {
-	if slotIdx >= int(sp.GetNumSlots()) {
-		return false
-	}
-	off, _ := sp.GetSlot(slotIdx)
-	sp.SetSlot(slotIdx, off, 0)
-	return true
-}
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// SlottedPage Layout (4KB):
+// Header (12 bytes):
+//   [0-7]:   NextPageID (int64)
+//   [8-9]:   NumSlots (uint16)
+//   [10-11]: FreeSpacePointer (uint16)
+// Slot Array: [Offset(2), Length(2)] per slot
+// Tuple Data: grows from end of page toward header
+
+const (
+	OffsetNextPageID = 0
+	OffsetNumSlots   = 8
+	OffsetFreeSpace  = 10
+	SizeOfHeader     = 12
+	SizeOfSlot       = 4
+
+	// MaxTupleSize is the largest tuple that fits on an empty page.
+	MaxTupleSize = PageSize - SizeOfHeader - SizeOfSlot
+)
+
+// SlottedPage provides tuple storage within a fixed-size page.
+type SlottedPage struct {
+	page *Page
+}
+
+// NewSlottedPage wraps a page for slotted access.
+func NewSlottedPage(page *Page) *SlottedPage {
+	sp := &SlottedPage{page: page}
+	if sp.GetNumSlots() == 0 && sp.GetFreeSpacePointer() == 0 {
+		sp.SetFreeSpacePointer(PageSize)
+	}
+	return sp
+}
+
+func (sp *SlottedPage) GetNumSlots() uint16 {
+	return binary.BigEndian.Uint16(sp.page.Data[OffsetNumSlots:])
+}
+
+func (sp *SlottedPage) SetNumSlots(num uint16) {
+	binary.BigEndian.PutUint16(sp.page.Data[OffsetNumSlots:], num)
+}
+
+func (sp *SlottedPage) GetFreeSpacePointer() uint16 {
+	return binary.BigEndian.Uint16(sp.page.Data[OffsetFreeSpace:])
+}
+
+func (sp *SlottedPage) SetFreeSpacePointer(ptr uint16) {
+	binary.BigEndian.PutUint16(sp.page.Data[OffsetFreeSpace:], ptr)
+}
+
+func (sp *SlottedPage) GetSlot(slotIdx int) (uint16, uint16) {
+	offset := SizeOfHeader + slotIdx*SizeOfSlot
+	sOff := binary.BigEndian.Uint16(sp.page.Data[offset:])
+	sLen := binary.BigEndian.Uint16(sp.page.Data[offset+2:])
+	return sOff, sLen
+}
+
+func (sp *SlottedPage) SetSlot(slotIdx int, sOff, sLen uint16) {
+	offset := SizeOfHeader + slotIdx*SizeOfSlot
+	binary.BigEndian.PutUint16(sp.page.Data[offset:], sOff)
+	binary.BigEndian.PutUint16(sp.page.Data[offset+2:], sLen)
+}
+
+// InsertTuple adds data to the page. Returns slot ID or error if full.
+func (sp *SlottedPage) InsertTuple(data []byte) (int, error) {
+	needed := len(data)
+	if needed > MaxTupleSize {
+		return -1, fmt.Errorf("tuple too large")
+	}
+
+	if !sp.HasRoom(needed) {
+		return -1, fmt.Errorf("no space")
+	}
+
+	numSlots := int(sp.GetNumSlots())
+	freePtr := int(sp.GetFreeSpacePointer())
+	newFreePtr := freePtr - needed
+	copy(sp.page.Data[newFreePtr:freePtr], data)
+	sp.SetFreeSpacePointer(uint16(newFreePtr))
+
+	sp.SetSlot(numSlots, uint16(newFreePtr), uint16(needed))
+	sp.SetNumSlots(uint16(numSlots + 1))
+
+	return numSlots, nil
+}
+
+// HasRoom reports whether InsertTuple can add a tuple of n bytes.
+func (sp *SlottedPage) HasRoom(n int) bool {
+	usedHeader := SizeOfHeader + int(sp.GetNumSlots())*SizeOfSlot
+	return int(sp.GetFreeSpacePointer())-usedHeader >= SizeOfSlot+n
+}
+
+// GetTuple reads data from the given slot.
+func (sp *SlottedPage) GetTuple(slotIdx int) []byte {
+	if slotIdx >= int(sp.GetNumSlots()) {
+		return nil
+	}
+	off, length := sp.GetSlot(slotIdx)
+	if length == 0 {
+		return nil
+	}
+	return sp.page.Data[off : off+length]
+}
+
+// DeleteTuple marks a slot as deleted by setting its length to 0.
+func (sp *SlottedPage) DeleteTuple(slotIdx int) bool {
+	if slotIdx >= int(sp.GetNumSlots()) {
+		return false
+	}
+	off, _ := sp.GetSlot(slotIdx)
+	sp.SetSlot(slotIdx, off, 0)
+	return true
+}
+
+// UpdateTuple replaces the data in a slot, keeping its slot ID. Data
+// that fits in the old space is written in place; larger data is moved
+// to the page's free space. Returns false if the slot is empty or the
+// page lacks room.
+func (sp *SlottedPage) UpdateTuple(slotIdx int, data []byte) bool {
+	if sp.GetTuple(slotIdx) == nil {
+		return false
+	}
+	off, length := sp.GetSlot(slotIdx)
+	if len(data) <= int(length) {
+		copy(sp.page.Data[off:], data)
+		sp.SetSlot(slotIdx, off, uint16(len(data)))
+		return true
+	}
+
+	freePtr := int(sp.GetFreeSpacePointer())
+	usedHeader := SizeOfHeader + int(sp.GetNumSlots())*SizeOfSlot
+	if freePtr-usedHeader < len(data) {
+		return false
+	}
+
+	newFreePtr := freePtr - len(data)
+	copy(sp.page.Data[newFreePtr:freePtr], data)
+	sp.SetFreeSpacePointer(uint16(newFreePtr))
+	sp.SetSlot(slotIdx, uint16(newFreePtr), uint16(len(data)))
+	return true
+}
@@ -1,173 +1,238 @@
-package storage
-
-import (
-	"encoding/binary"
-	"fmt"
-)
-
-// GetNextPageID returns the next page ID in the linked list.
-func (sp *SlottedPage) GetNextPageID() PageID {
-	return PageID(int64(binary.BigEndian.Uint64(sp.page.Data[OffsetNextPageID:])))
-}
-
-// SetNextPageID sets the next page ID in the linked list.
-func (sp *SlottedPage) SetNextPageID(pid PageID) {
-	binary.BigEndian.PutUint64(sp.page.Data[OffsetNextPageID:], uint64(pid))
-}
-
-// TableHeap manages a collection of slotted pages as a linked list.
-type TableHeap struct {
-	bufferPool  *BufferPool
-	firstPageID PageID
-}
-
-// NewTableHeap creates a new table heap or loads an existing one.
-// Pass InvalidPageID to create a new heap.
-func NewTableHeap(bp *BufferPool, firstPageID PageID) (*TableHeap, error) {
-	th := &TableHeap{
-		bufferPool:  bp,
-		firstPageID: firstPageID,
-	}
-
-	if th.firstPageID == InvalidPageID {
-		p, err := bp.NewPage()
-		if err != nil {
-			return nil, err
-		}
-		sp := NewSlottedPage(p)
-		sp.SetNextPageID(InvalidPageID)
-		th.firstPageID = p.ID
-		bp.UnpinPage(p.ID, true)
-	}
-	return th, nil
-}
-
-// InsertTuple inserts a tuple into the heap and returns its RID.
-func (th *TableHeap) InsertTuple(data []byte) (RID, error) {
-	currPageID := th.firstPageID
-
-	for {
-		page, err := th.bufferPool.FetchPage(currPageID)
-		if err != nil {
-			return RID{}, err
-		}
-		sp := NewSlottedPage(page)
-
-		slotID, err := sp.InsertTuple(data)
-		if err == nil {
-			th.bufferPool.UnpinPage(currPageID, true)
-			return RID{PageID: currPageID, SlotID: uint32(slotID)}, nil
-		}
-
-		nextID := sp.GetNextPageID()
-		if nextID == InvalidPageID {
-			newPage, err := th.bufferPool.NewPage()
-			if err != nil {
-				th.bufferPool.UnpinPage(currPageID, false)
-				return RID{}, err
-			}
-			newSP := NewSlottedPage(newPage)
-			newSP.SetNextPageID(InvalidPageID)
-
-			sp.SetNextPageID(newPage.ID)
-			th.bufferPool.UnpinPage(currPageID, true)
-
-			slotID, err := newSP.InsertTuple(data)
-			if err != nil {
-				th.bufferPool.UnpinPage(newPage.ID, false)
-				return RID{}, err
-			}
-			th.bufferPool.UnpinPage(newPage.ID, true)
-			return RID{PageID: newPage.ID, SlotID: uint32(slotID)}, nil
-		}
-
-		th.bufferPool.UnpinPage(currPageID, false)
-		currPageID = nextID
-	}
-}
-
-// GetTuple retrieves a tuple by its RID.
-func (th *TableHeap) GetTuple(rid RID) ([]byte, error) {
-	page, err := th.bufferPool.FetchPage(rid.PageID)
-	if err != nil {
-		return nil, err
-	}
-	defer th.bufferPool.UnpinPage(rid.PageID, false)
-
-	sp := NewSlottedPage(page)
-	data := sp.GetTuple(int(rid.SlotID))
-	if data == nil {
-		return nil, fmt.Errorf("tuple not found")
-	}
-
-	out := make([]byte, len(data))
-	copy(out, data)
-	return out, nil
-}
-
-// DeleteTuple marks a tuple as deleted by its RID.
-func (th *TableHeap) DeleteTuple(rid RID) error {
-	page, err := th.bufferPool.FetchPage(rid.PageID)
-	if err != nil {
-		return err
-	}
-	sp := NewSlottedPage(page)
-	if !sp.DeleteTuple(int(rid.SlotID)) {
-		th.bufferPool.UnpinPage(rid.PageID, false)
-		return fmt.Errorf("tuple not found")
-	}
-	th.bufferPool.UnpinPage(rid.PageID, true)
-	return nil
-}
-
-// TableIterator iterates over all tuples in the heap.
-type TableIterator struct {
-	tableHeap  *TableHeap
-	currPageID PageID
-	currSlot   int
-}
-
-// Iterator returns a new iterator starting from the first page.
-func (th *TableHeap) Iterator() *TableIterator {
-	return &TableIterator{
-		tableHeap:  th,
-		currPageID: th.firstPageID,
-		currSlot:   0,
-	}
-}
-
-// Next returns the next tuple, or nil when exhausted.
-func (it *TableIterator) Next() ([]byte, RID, error) {
-	for {
-		if it.currPageID == InvalidPageID {
-			return nil, RID{}, nil
-		}
-
-		page, err := it.tableHeap.bufferPool.FetchPage(it.currPageID)
-		if err != nil {
-			return nil, RID{}, err
-		}
-		sp := NewSlottedPage(page)
-		numSlots := int(sp.GetNumSlots())
-
-		if it.currSlot < numSlots {
-			data := sp.GetTuple(it.currSlot)
-			rid := RID{PageID: it.currPageID, SlotID: uint32(it.currSlot)}
-			it.currSlot++
-
-			if data != nil {
-				it.tableHeap.bufferPool.UnpinPage(it.currPageID, false)
-				out := make([]byte, len(data))
-				copy(out, data)
-				return out, rid, nil
-			}
-			it.tableHeap.bufferPool.UnpinPage(it.currPageID, false)
-			continue
-		}
-
-		nextID := sp.GetNextPageID()
-		it.tableHeap.bufferPool.UnpinPage(it.currPageID, false)
-		it.currPageID = nextID
-		it.currSlot = 0
-	}
-}
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// GetNextPageID returns the next page ID in the linked list.
+func (sp *SlottedPage) GetNextPageID() PageID {
+	return PageID(int64(binary.BigEndian.Uint64(sp.page.Data[OffsetNextPageID:])))
+}
+
+// SetNextPageID sets the next page ID in the linked list.
+func (sp *SlottedPage) SetNextPageID(pid PageID) {
+	binary.BigEndian.PutUint64(sp.page.Data[OffsetNextPageID:], uint64(pid))
+}
+
+// TableHeap manages a collection of slotted pages as a linked list.
+type TableHeap struct {
+	bufferPool  *BufferPool
+	firstPageID PageID
+}
+
+// NewTableHeap creates a new table heap or loads an existing one.
+// Pass InvalidPageID to create a new heap.
+func NewTableHeap(bp *BufferPool, firstPageID PageID) (*TableHeap, error) {
+	th := &TableHeap{
+		bufferPool:  bp,
+		firstPageID: firstPageID,
+	}
+
+	if th.firstPageID == InvalidPageID {
+		guard, err := bp.NewPageGuarded()
+		if err != nil {
+			return nil, err
+		}
+		defer guard.Drop()
+		sp := NewSlottedPage(guard.Page())
+		sp.SetNextPageID(InvalidPageID)
+		th.firstPageID = guard.PageID()
+	}
+	return th, nil
+}
+
+// FirstPageID returns the ID of the first page in the heap.
+func (th *TableHeap) FirstPageID() PageID {
+	return th.firstPageID
+}
+
+// InsertTuple inserts a tuple into the heap and returns its RID. It
+// looks for room along the page list under read latches and only
+// write-latches the page it inserts into, appending a new page after the
+// last one if none has room. Tuples over MaxTupleSize are rejected
+// before any page is added.
+func (th *TableHeap) InsertTuple(data []byte) (RID, error) {
+	if len(data) > MaxTupleSize {
+		return RID{}, fmt.Errorf("tuple of %d bytes exceeds the maximum of %d", len(data), MaxTupleSize)
+	}
+	currPageID := th.firstPageID
+
+	for {
+		guard, err := th.bufferPool.FetchPageRead(currPageID)
+		if err != nil {
+			return RID{}, err
+		}
+		sp := NewSlottedPage(guard.Page())
+		hasRoom := sp.HasRoom(len(data))
+		nextID := sp.GetNextPageID()
+		guard.Drop()
+
+		if hasRoom || nextID == InvalidPageID {
+			return th.insertInto(currPageID, data)
+		}
+		currPageID = nextID
+	}
+}
+
+// insertInto inserts a tuple into pageID, or into a new page linked
+// after it if it has no room.
+func (th *TableHeap) insertInto(pageID PageID, data []byte) (RID, error) {
+	guard, err := th.bufferPool.FetchPageWrite(pageID)
+	if err != nil {
+		return RID{}, err
+	}
+	defer guard.Drop()
+	sp := NewSlottedPage(guard.Page())
+
+	if slotID, err := sp.InsertTuple(data); err == nil {
+		return RID{PageID: pageID, SlotID: uint32(slotID)}, nil
+	}
+
+	newGuard, err := th.bufferPool.NewPageGuarded()
+	if err != nil {
+		return RID{}, err
+	}
+	defer newGuard.Drop()
+	newSP := NewSlottedPage(newGuard.Page())
+	newSP.SetNextPageID(sp.GetNextPageID())
+	sp.SetNextPageID(newGuard.PageID())
+
+	slotID, err := newSP.InsertTuple(data)
+	if err != nil {
+		return RID{}, err
+	}
+	return RID{PageID: newGuard.PageID(), SlotID: uint32(slotID)}, nil
+}
+
+// GetTuple retrieves a tuple by its RID.
+func (th *TableHeap) GetTuple(rid RID) ([]byte, error) {
+	guard, err := th.bufferPool.FetchPageRead(rid.PageID)
+	if err != nil {
+		return nil, err
+	}
+	defer guard.Drop()
+
+	sp := NewSlottedPage(guard.Page())
+	data := sp.GetTuple(int(rid.SlotID))
+	if data == nil {
+		return nil, fmt.Errorf("tuple not found")
+	}
+
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out, nil
+}
+
+// DeleteTuple marks a tuple as deleted by its RID.
+func (th *TableHeap) DeleteTuple(rid RID) error {
+	guard, err := th.bufferPool.FetchPageWrite(rid.PageID)
+	if err != nil {
+		return err
+	}
+	defer guard.Drop()
+	if !NewSlottedPage(guard.Page()).DeleteTuple(int(rid.SlotID)) {
+		return fmt.Errorf("tuple not found")
+	}
+	return nil
+}
+
+// UpdateTuple replaces the tuple at rid and returns its RID afterwards.
+// The tuple stays in its slot when the page has room; otherwise it is
+// moved to another page and the new RID is returned.
+func (th *TableHeap) UpdateTuple(rid RID, data []byte) (RID, error) {
+	guard, err := th.bufferPool.FetchPageWrite(rid.PageID)
+	if err != nil {
+		return RID{}, err
+	}
+	sp := NewSlottedPage(guard.Page())
+	found := sp.GetTuple(int(rid.SlotID)) != nil
+	updated := found && sp.UpdateTuple(int(rid.SlotID), data)
+	guard.Drop()
+	if !found {
+		return RID{}, fmt.Errorf("tuple not found")
+	}
+	if updated {
+		return rid, nil
+	}
+
+	// Insert the new version before deleting the old one so a failed
+	// insert leaves the tuple intact.
+	newRID, err := th.InsertTuple(data)
+	if err != nil {
+		return RID{}, err
+	}
+	if err := th.DeleteTuple(rid); err != nil {
+		return RID{}, err
+	}
+	return newRID, nil
+}
+
+// TableIterator iterates over all tuples in the heap.
+type TableIterator struct {
+	tableHeap  *TableHeap
+	strategy   *AccessStrategy
+	currPageID PageID
+	currSlot   int
+}
+
+// Iterator returns a new iterator starting from the first page.
+func (th *TableHeap) Iterator() *TableIterator {
+	return &TableIterator{
+		tableHeap:  th,
+		currPageID: th.firstPageID,
+		currSlot:   0,
+	}
+}
+
+// ScanIterator returns an iterator for a full scan of the heap, which
+// reads the pages it misses through a scan strategy so that a large
+// heap does not flush the rest of the buffer pool.
+func (th *TableHeap) ScanIterator() *TableIterator {
+	it := th.Iterator()
+	it.strategy = th.bufferPool.NewScanStrategy()
+	return it
+}
+
+// Rewind restarts the iteration from the first page, keeping the
+// iterator's strategy.
+func (it *TableIterator) Rewind() {
+	it.currPageID = it.tableHeap.firstPageID
+	it.currSlot = 0
+}
+
+// Next returns the next tuple, or nil when exhausted.
+func (it *TableIterator) Next() ([]byte, RID, error) {
+	for it.currPageID != InvalidPageID {
+		data, rid, err := it.nextOnPage()
+		if data != nil || err != nil {
+			return data, rid, err
+		}
+	}
+	return nil, RID{}, nil
+}
+
+// nextOnPage returns the next tuple on the current page, or moves to the
+// next page and returns nil if there are no more.
+func (it *TableIterator) nextOnPage() ([]byte, RID, error) {
+	guard, err := it.tableHeap.bufferPool.FetchPageReadWithStrategy(it.currPageID, it.strategy)
+	if err != nil {
+		return nil, RID{}, err
+	}
+	defer guard.Drop()
+
+	sp := NewSlottedPage(guard.Page())
+	for it.currSlot < int(sp.GetNumSlots()) {
+		data := sp.GetTuple(it.currSlot)
+		rid := RID{PageID: it.currPageID, SlotID: uint32(it.currSlot)}
+		it.currSlot++
+		if data != nil {
+			out := make([]byte, len(data))
+			copy(out, data)
+			return out, rid, nil
+		}
+	}
+	it.currPageID = sp.GetNextPageID()
+	it.currSlot = 0
+	return nil, RID{}, nil
+}